@@ -2,18 +2,24 @@ package base
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"text/template"
 
+	mapset "github.com/deckarep/golang-set/v2"
 	"go.yaml.in/yaml/v4"
 
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
 	"github.com/femnad/fup/precheck"
 	"github.com/femnad/fup/remote"
+	"github.com/femnad/fup/settings"
 )
 
 type configReader struct {
@@ -117,23 +123,211 @@ func FinalizeConfig(filename string) (string, error) {
 	return string(out.content), nil
 }
 
+// decodeConfig decodes the config rejecting unknown keys and runs semantic checks on it. Positions in errors are
+// relative to the config after evaluating it as a template.
+func decodeConfig(filename string, content []byte) (config entity.Config, err error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&config)
+	if errors.Is(err, io.EOF) {
+		return config, nil
+	}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		var errs []error
+		for _, e := range typeErr.Errors {
+			errs = append(errs, entity.ConfigError{File: filename, Line: e.Line, Column: e.Column, Msg: e.Err.Error()})
+		}
+		return config, fmt.Errorf("invalid config %s:\n%w", filename, errors.Join(errs...))
+	} else if err != nil {
+		return config, fmt.Errorf("error deserializing config from %s: %v", filename, err)
+	}
+
+	var root yaml.Node
+	err = yaml.Unmarshal(content, &root)
+	if err != nil {
+		return config, fmt.Errorf("error deserializing config from %s: %v", filename, err)
+	}
+
+	var errs []error
+	for _, e := range entity.CheckConfig(filename, &root, config) {
+		errs = append(errs, e)
+	}
+	if len(errs) > 0 {
+		return config, fmt.Errorf("invalid config %s:\n%w", filename, errors.Join(errs...))
+	}
+
+	return config, nil
+}
+
 func unmarshalConfig(filename string) (config entity.Config, err error) {
 	finalConfig, err := finalizeConfig(filename)
 	if err != nil {
 		return config, err
 	}
 
-	err = yaml.Unmarshal(finalConfig.content, &config)
+	config, err = decodeConfig(filename, finalConfig.content)
 	if err != nil {
-		return config, fmt.Errorf("error deserializing config from %s: %v", filename, err)
+		return config, err
 	}
 
 	config.Filename = finalConfig.filename
 	config.Remote = finalConfig.isRemote
+
+	origin := entity.ConfigOrigin{
+		File:        config.Filename,
+		Remote:      config.Remote,
+		TemplateDir: config.Settings.TemplateDir,
+	}
+	for i := range config.Templates {
+		config.Templates[i].Origin = origin
+	}
+
+	err = resolveKeys(&config)
 	return
 }
 
+// resolvePath returns the location of a file referenced by a config, such as an include, relative to the config.
+func resolvePath(config entity.Config, file string) (string, error) {
+	parsed, err := url.Parse(file)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "" {
+		return file, nil
+	}
+
+	if config.IsRemote() {
+		base, baseErr := url.Parse(config.File())
+		if baseErr != nil {
+			return "", baseErr
+		}
+		return base.ResolveReference(parsed).String(), nil
+	}
+
+	file = internal.ExpandUser(file)
+	if path.IsAbs(file) {
+		return file, nil
+	}
+
+	dir, _ := path.Split(config.File())
+	return path.Join(dir, file), nil
+}
+
+// resolveKey returns the location of a key file relative to the config declaring it, keeping inline keys, which span
+// multiple lines, and keys starting with a setting as they are.
+func resolveKey(config entity.Config, key string) (string, error) {
+	if key == "" || strings.Contains(key, "\n") || strings.HasPrefix(key, "$") {
+		return key, nil
+	}
+
+	return resolvePath(config, key)
+}
+
+// resolveKeys resolves the key files of releases and repos relative to the config declaring them, like template
+// sources. Other paths, such as destinations on the host, are kept as they are.
+func resolveKeys(config *entity.Config) error {
+	var releases []*entity.Release
+	for i := range config.Releases {
+		releases = append(releases, &config.Releases[i])
+	}
+	for i := range config.GithubReleases {
+		releases = append(releases, &config.GithubReleases[i].Release)
+	}
+	for i := range config.GitlabReleases {
+		releases = append(releases, &config.GitlabReleases[i].Release)
+	}
+	for i := range config.GiteaReleases {
+		releases = append(releases, &config.GiteaReleases[i].Release)
+	}
+
+	for _, release := range releases {
+		key, err := resolveKey(*config, release.SigningKey)
+		if err != nil {
+			return fmt.Errorf("error resolving signing key %s in %s: %v", release.SigningKey, config.File(), err)
+		}
+		release.SigningKey = key
+	}
+	for i, repo := range config.AptRepos {
+		key, err := resolveKey(*config, repo.GPGKey)
+		if err != nil {
+			return fmt.Errorf("error resolving GPG key %s in %s: %v", repo.GPGKey, config.File(), err)
+		}
+		config.AptRepos[i].GPGKey = key
+	}
+
+	return nil
+}
+
+func readConfigWithIncludes(filename string, including []string, seen mapset.Set[string]) (entity.Config, error) {
+	if slices.Contains(including, filename) {
+		cycle := slices.Concat(including, []string{filename})
+		return entity.Config{}, fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	config, err := unmarshalConfig(filename)
+	if err != nil {
+		return config, err
+	}
+	seen.Add(filename)
+	stack := slices.Concat(including, []string{filename})
+
+	// Includes of the root config are fetched with its HTTP settings.
+	if len(including) == 0 && len(config.Include) > 0 {
+		err = remote.Configure(config.Settings.HTTP)
+		if err != nil {
+			return config, fmt.Errorf("invalid HTTP settings in %s: %v", filename, err)
+		}
+	}
+
+	var merged entity.Config
+	for _, include := range config.Include {
+		var includeFile string
+		includeFile, err = resolvePath(config, include)
+		if err != nil {
+			return config, fmt.Errorf("error resolving include %s in %s: %v", include, filename, err)
+		}
+
+		// Configs included more than once, such as a shared base, are only merged once.
+		if seen.Contains(includeFile) && !slices.Contains(stack, includeFile) {
+			internal.Logger.Debug().Str("file", includeFile).Msg("Skipping config which is already included")
+			continue
+		}
+
+		internal.Logger.Trace().Str("file", includeFile).Str("includer", filename).Msg("Reading included config")
+		var included entity.Config
+		included, err = readConfigWithIncludes(includeFile, stack, seen)
+		if err != nil {
+			return config, err
+		}
+		merged = merged.Merge(included)
+	}
+
+	// Sections of the including config come after the included ones and its settings take precedence.
+	merged = merged.Merge(config)
+	merged.Filename = config.Filename
+	merged.Remote = config.Remote
+	merged.Include = config.Include
+	return merged, nil
+}
+
+// ReadConfig reads a config with its includes, and configures the HTTP client from its settings. Template sources and
+// key files are resolved relative to the config declaring them, while paths on the host such as destinations are not.
 func ReadConfig(filename string) (entity.Config, error) {
 	filename = internal.ExpandUser(filename)
-	return unmarshalConfig(filename)
+	config, err := readConfigWithIncludes(filename, nil, mapset.NewThreadUnsafeSet[string]())
+	if err != nil {
+		return config, err
+	}
+
+	// Headers configured in the settings take precedence over the ones for the tokens of GitLab and Gitea instances.
+	tokenHeaders := settings.HTTPSettings{Headers: config.TokenHeaders()}
+	config.Settings.HTTP = tokenHeaders.Merge(config.Settings.HTTP)
+	err = remote.Configure(config.Settings.HTTP)
+	if err != nil {
+		return config, fmt.Errorf("invalid HTTP settings: %v", err)
+	}
+
+	return config, nil
 }
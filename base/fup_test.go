@@ -0,0 +1,195 @@
+package base
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/femnad/fup/entity"
+)
+
+func writeConfigs(t *testing.T, configs map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range configs {
+		file := path.Join(dir, name)
+		if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestReadConfigIncludes(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"fup.yml": `
+include:
+  - shared/base.yml
+  - extra.yml
+settings:
+  release_dir: ~/own
+  versions:
+    fzf: 2.0.0
+go:
+  - name: own
+template:
+  - src: own.tmpl
+    dest: /tmp/own
+`,
+		"shared/base.yml": `
+include:
+  - ../extra.yml
+settings:
+  release_dir: ~/base
+  template_dir: templates
+  versions:
+    fzf: 1.0.0
+    gh: 1.0.0
+go:
+  - name: base
+template:
+  - src: base.tmpl
+    dest: /tmp/base
+`,
+		"extra.yml": `
+go:
+  - name: extra
+`,
+	})
+
+	config, err := ReadConfig(path.Join(dir, "fup.yml"))
+	if err != nil {
+		t.Fatalf("ReadConfig() error = %v", err)
+	}
+
+	var goPkgs []string
+	for _, pkg := range config.Go {
+		goPkgs = append(goPkgs, pkg.Pkg)
+	}
+	wantGoPkgs := []string{"extra", "base", "own"}
+	if !reflect.DeepEqual(goPkgs, wantGoPkgs) {
+		t.Errorf("ReadConfig() go packages = %v, want %v", goPkgs, wantGoPkgs)
+	}
+
+	if config.Settings.ReleaseDir != "~/own" {
+		t.Errorf("ReadConfig() release dir = %s, want ~/own", config.Settings.ReleaseDir)
+	}
+	wantVersions := map[string]string{"fzf": "2.0.0", "gh": "1.0.0"}
+	if !reflect.DeepEqual(config.Settings.Versions, wantVersions) {
+		t.Errorf("ReadConfig() versions = %v, want %v", config.Settings.Versions, wantVersions)
+	}
+
+	wantOrigins := []entity.ConfigOrigin{
+		{File: path.Join(dir, "shared/base.yml"), TemplateDir: "templates"},
+		{File: path.Join(dir, "fup.yml")},
+	}
+	var origins []entity.ConfigOrigin
+	for _, tmpl := range config.Templates {
+		origins = append(origins, tmpl.Origin)
+	}
+	if !reflect.DeepEqual(origins, wantOrigins) {
+		t.Errorf("ReadConfig() template origins = %v, want %v", origins, wantOrigins)
+	}
+}
+
+func TestReadConfigIncludeKeys(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"fup.yml": `
+include:
+  - shared/base.yml
+release:
+  - name: own
+    url: https://example.com/own.tar.gz
+    signing_key: own.asc
+    signature_url: https://example.com/sig
+    checksum_url: https://example.com/sums
+`,
+		"shared/base.yml": `
+include:
+  - team/team.yml
+`,
+		"shared/team/team.yml": `
+apt_repo:
+  - name: team
+    repo: https://example.com/apt
+    gpg_key: ../keys/team.asc
+github-release:
+  - name: foo/bar
+    url: ${version}/bar.tar.gz
+    signing_key: bar.asc
+    signature_url: https://example.com/sig
+    checksum_url: https://example.com/sums
+release:
+  - name: baz
+    url: https://example.com/baz.tar.gz
+    signing_key: https://example.com/baz.asc
+    signature_url: https://example.com/sig
+    checksum_url: https://example.com/sums
+  - name: qux
+    url: https://example.com/qux.tar.gz
+    signing_key: /etc/keys/qux.asc
+    signature_url: https://example.com/sig
+    checksum_url: https://example.com/sums
+`,
+	})
+
+	config, err := ReadConfig(path.Join(dir, "fup.yml"))
+	if err != nil {
+		t.Fatalf("ReadConfig() error = %v", err)
+	}
+
+	var keys []string
+	for _, release := range config.Releases {
+		keys = append(keys, release.SigningKey)
+	}
+	wantKeys := []string{"https://example.com/baz.asc", "/etc/keys/qux.asc", path.Join(dir, "own.asc")}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("ReadConfig() release signing keys = %v, want %v", keys, wantKeys)
+	}
+	if got, want := config.GithubReleases[0].SigningKey, path.Join(dir, "shared/team/bar.asc"); got != want {
+		t.Errorf("ReadConfig() GitHub release signing key = %s, want %s", got, want)
+	}
+	if got, want := config.AptRepos[0].GPGKey, path.Join(dir, "shared/keys/team.asc"); got != want {
+		t.Errorf("ReadConfig() apt repo GPG key = %s, want %s", got, want)
+	}
+}
+
+func TestReadConfigIncludeCycle(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"fup.yml": "include: [a.yml]\n",
+		"a.yml":   "include: [b.yml]\n",
+		"b.yml":   "include: [a.yml]\n",
+	})
+
+	_, err := ReadConfig(path.Join(dir, "fup.yml"))
+	if err == nil {
+		t.Errorf("ReadConfig() expected include cycle error")
+	}
+}
+
+func TestReadConfigUnknownKey(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"fup.yml": `
+task:
+  - name: foo
+    unles:
+      cmd: true
+`,
+	})
+
+	file := path.Join(dir, "fup.yml")
+	_, err := ReadConfig(file)
+	if err == nil {
+		t.Fatalf("ReadConfig() expected unknown key error")
+	}
+
+	want := file + ":4:5: field unles not found in type entity.Task"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("ReadConfig() error = %v, want it to contain %s", err, want)
+	}
+}
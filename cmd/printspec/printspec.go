@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
 
 	"github.com/cli/go-gh/v2/pkg/api"
@@ -14,20 +13,67 @@ import (
 	"go.yaml.in/yaml/v4"
 )
 
-var (
-	// Negative matches.
-	appleRegex       = regexp.MustCompile("(?i)apple")
-	darwinRegex      = regexp.MustCompile("(?i)darwin")
-	freeBSRegex      = regexp.MustCompile("(?i)freebsd")
-	packageFileRegex = regexp.MustCompile("(?i)\\.(apk|deb|rpm)$")
-	windowsRegex     = regexp.MustCompile("(?i)windows")
-	// Positive matches.
-	architectureRegex = regexp.MustCompile("(?i)x86[_-]64")
-	linux64Regex      = regexp.MustCompile("(?i)linux.amd64")
-
-	negativeMatches = []*regexp.Regexp{appleRegex, darwinRegex, freeBSRegex, packageFileRegex, windowsRegex}
-	positiveMatches = []*regexp.Regexp{architectureRegex, linux64Regex}
-)
+// archPlaceholder is an architecture name and the expansion replacing it.
+type archPlaceholder struct {
+	name      string
+	expansion string
+}
+
+// archPlaceholders returns the host architecture names with their expansions, with the Rust target first as it contains
+// the machine name.
+func archPlaceholders() []archPlaceholder {
+	var placeholders []archPlaceholder
+	if target := internal.RustTarget(); target != "" {
+		placeholders = append(placeholders, archPlaceholder{name: target, expansion: "${rust_target}"})
+	}
+
+	return append(placeholders, archPlaceholder{name: internal.Arch(), expansion: "${arch}"},
+		archPlaceholder{name: internal.GoArch(), expansion: "${goarch}"})
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// isArchBoundary returns true if the byte at i separates an architecture name from the rest of the string, looking in
+// the given direction for the digit following a dot, so that 386 in foo-1.386.0.tar.gz isn't a separate token.
+func isArchBoundary(s string, i, direction int) bool {
+	if i < 0 || i >= len(s) {
+		return true
+	}
+	if isAlphanumeric(s[i]) {
+		return false
+	}
+
+	next := i + direction
+	return s[i] != '.' || next < 0 || next >= len(s) || s[next] < '0' || s[next] > '9'
+}
+
+// replaceArchNames replaces the host architecture names which are separate tokens with their expansions, so that the
+// spec works on other architectures.
+func replaceArchNames(s string) string {
+	placeholders := archPlaceholders()
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		replaced := false
+		for _, p := range placeholders {
+			end := i + len(p.name)
+			if strings.HasPrefix(s[i:], p.name) && isArchBoundary(s, i-1, -1) && isArchBoundary(s, end, 1) {
+				b.WriteString(p.expansion)
+				i = end
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+
+	return b.String()
+}
 
 type getReleasesResp struct {
 	TagName string `json:"tag_name"`
@@ -67,21 +113,11 @@ func printSpec(input Input) error {
 
 	var name string
 	var releaseURL string
-assets:
 	for _, asset := range latest.Assets {
 		name = asset.Name
-
-		for _, regex := range negativeMatches {
-			if regex.MatchString(name) {
-				continue assets
-			}
-		}
-
-		for _, regex := range positiveMatches {
-			if regex.MatchString(name) {
-				releaseURL = asset.Url
-				break assets
-			}
+		if !entity.IsOtherPlatformAsset(name) && entity.IsHostArchAsset(name) {
+			releaseURL = asset.Url
+			break
 		}
 	}
 
@@ -90,6 +126,7 @@ assets:
 	}
 	releaseURL = fmt.Sprintf("%s/%s", version, name)
 	releaseURL = strings.ReplaceAll(releaseURL, version, "${version}")
+	releaseURL = replaceArchNames(releaseURL)
 
 	release := entity.GithubRelease{Release: entity.Release{Ref: fmt.Sprintf("%s/%s", owner, repo), Url: releaseURL}}
 	releases := []entity.GithubRelease{release}
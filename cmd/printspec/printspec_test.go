@@ -0,0 +1,47 @@
+package printspec
+
+import (
+	"testing"
+
+	"github.com/femnad/fup/internal"
+)
+
+func Test_replaceArchNames(t *testing.T) {
+	arch, goArch := internal.Arch(), internal.GoArch()
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "Separate tokens",
+			url:  "${version}/foo-" + arch + "_" + goArch + ".tar.gz",
+			want: "${version}/foo-${arch}_${goarch}.tar.gz",
+		},
+		{
+			name: "Within other words",
+			url:  "${version}/f" + goArch + "oo-" + arch + "bar-" + goArch + ".tar.gz",
+			want: "${version}/f" + goArch + "oo-" + arch + "bar-${goarch}.tar.gz",
+		},
+		{
+			name: "Within a version",
+			url:  "v1/foo-1." + goArch + ".0-" + goArch + ".tar.gz",
+			want: "v1/foo-1." + goArch + ".0-${goarch}.tar.gz",
+		},
+	}
+	if target := internal.RustTarget(); target != "" {
+		tests = append(tests, struct {
+			name string
+			url  string
+			want string
+		}{name: "Rust target", url: "${version}/foo-" + target + ".tar.gz", want: "${version}/foo-${rust_target}.tar.gz"})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replaceArchNames(tt.url); got != tt.want {
+				t.Errorf("replaceArchNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,13 +11,14 @@ import (
 
 const permissions = 0o600
 
-func EnsureLinesInFile(file string, lines []string) error {
+// EnsureLinesInFile appends the given lines to the file unless they already exist, reporting if any line was added.
+func EnsureLinesInFile(file string, lines []string) (bool, error) {
 	itemSet := internal.SetFromList[string](lines)
 
 	file = internal.ExpandUser(file)
 	dir, _ := path.Split(file)
 	if err := internal.EnsureDirExists(dir); err != nil {
-		return err
+		return false, err
 	}
 
 	_, err := os.Stat(file)
@@ -25,15 +26,15 @@ func EnsureLinesInFile(file string, lines []string) error {
 	if os.IsNotExist(err) {
 		fd, err = os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, permissions)
 		if err != nil {
-			return err
+			return false, err
 		}
 	} else if err == nil {
 		fd, err = os.OpenFile(file, os.O_RDWR, permissions)
 		if err != nil {
-			return err
+			return false, err
 		}
 	} else {
-		return err
+		return false, err
 	}
 	defer fd.Close()
 
@@ -47,6 +48,7 @@ func EnsureLinesInFile(file string, lines []string) error {
 		}
 	}
 
+	changed := itemSet.Cardinality() > 0
 	itemSet.Each(func(key string) bool {
 		line := fmt.Sprintf("%s\n", key)
 		_, err = fd.WriteString(line)
@@ -56,5 +58,5 @@ func EnsureLinesInFile(file string, lines []string) error {
 		return false
 	})
 
-	return err
+	return changed, err
 }
@@ -2,9 +2,7 @@ package entity
 
 import (
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
 	"path"
 	"regexp"
 	"strings"
@@ -12,7 +10,6 @@ import (
 	"github.com/femnad/fup/internal"
 	"github.com/femnad/fup/precheck"
 	"github.com/femnad/fup/precheck/unless"
-	"github.com/femnad/fup/remote"
 	"github.com/femnad/fup/settings"
 	marecmd "github.com/femnad/mare/cmd"
 )
@@ -25,6 +22,7 @@ const (
 
 type AptRepo struct {
 	unless.BasicUnlessable
+	Meta         `yaml:",inline"`
 	Distribution string `yaml:"distribution"`
 	Components   string `yaml:"components"`
 	GPGKey       string `yaml:"gpg_key"`
@@ -56,59 +54,26 @@ func (a AptRepo) RunWhen() string {
 	return a.When
 }
 
-func (AptRepo) ensureKeyFile(keyUrl, keyRingFile string) error {
-	key, err := remote.ReadResponseBytes(keyUrl)
-	if err != nil {
-		return err
-	}
-
-	gpgCmd := exec.Command("gpg", "--dearmor")
-
-	stdin, err := gpgCmd.StdinPipe()
-	if err != nil {
-		return err
+// KeyURLs returns the URLs the repo key is downloaded from.
+func (a AptRepo) KeyURLs() []string {
+	if isURL(a.GPGKey) {
+		return []string{a.GPGKey}
 	}
-	defer stdin.Close()
 
-	stdout, err := gpgCmd.StdoutPipe()
-	if err != nil {
-		return err
-	}
-	defer stdout.Close()
-
-	stderr, err := gpgCmd.StderrPipe()
-	if err != nil {
-		return err
-	}
-	defer stderr.Close()
-
-	if err = gpgCmd.Start(); err != nil {
-		return err
-	}
+	return nil
+}
 
-	_, err = stdin.Write(key)
-	if err != nil {
-		return err
-	}
-	err = stdin.Close()
+func (AptRepo) ensureKeyFile(key, keyRingFile string) error {
+	armoredKey, err := readKey(key)
 	if err != nil {
 		return err
 	}
 
-	gpgKey, err := io.ReadAll(stdout)
+	gpgKey, err := dearmorKey(armoredKey)
 	if err != nil {
 		return err
 	}
 
-	if err = gpgCmd.Wait(); err != nil {
-		out, err := io.ReadAll(stderr)
-		if err != nil {
-			return err
-		}
-
-		return fmt.Errorf("error in gpg process, output %s: %v", out, err)
-	}
-
 	_, err = internal.WriteContent(internal.ManagedFile{
 		Content: string(gpgKey),
 		Path:    keyRingFile,
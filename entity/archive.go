@@ -1,7 +1,9 @@
 package entity
 
 type Archive struct {
-	Files  []string `yaml:"files"`
-	Target string   `yaml:"target"`
-	URL    string   `yaml:"url"`
+	Meta      `yaml:",inline"`
+	Integrity `yaml:",inline"`
+	Files     []string `yaml:"files"`
+	Target    string   `yaml:"target"`
+	URL       string   `yaml:"url"`
 }
@@ -0,0 +1,247 @@
+package entity
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/settings"
+)
+
+var (
+	// Assets for other operating systems, packages and files accompanying the release archives.
+	otherPlatformRegexes = []*regexp.Regexp{
+		regexp.MustCompile("(?i)apple"),
+		regexp.MustCompile("(?i)darwin"),
+		regexp.MustCompile("(?i)freebsd"),
+		regexp.MustCompile("(?i)\\.(apk|deb|rpm)$"),
+		regexp.MustCompile("(?i)\\.(asc|pem|sbom|sha256|sha512|sig)$"),
+		regexp.MustCompile("(?i)windows"),
+	}
+	hostArchRegex  = archRegex([]string{internal.Arch(), internal.GoArch()})
+	otherArchRegex = archRegex(internal.OtherArchNames())
+)
+
+// archRegex matches any of the architecture names as a separate token of an asset name, such as x86_64 or x86-64 in
+// foo-x86_64.tar.gz but not 386 in foo-1.386.0.tar.gz.
+func archRegex(names []string) *regexp.Regexp {
+	var alternatives []string
+	for _, name := range names {
+		alternatives = append(alternatives, strings.ReplaceAll(regexp.QuoteMeta(name), "_", "[_-]"))
+	}
+
+	return regexp.MustCompile(fmt.Sprintf("(?i)(^|[^a-z0-9])(%s)([^a-z0-9]|$)", strings.Join(alternatives, "|")))
+}
+
+// IsOtherPlatformAsset returns true if the asset name is for an operating system other than Linux, is a package or is
+// a checksum or signature file.
+func IsOtherPlatformAsset(name string) bool {
+	for _, regex := range otherPlatformRegexes {
+		if regex.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsHostArchAsset returns true if the asset name contains the machine or Go name of the host architecture.
+func IsHostArchAsset(name string) bool {
+	return hostArchRegex.MatchString(name)
+}
+
+func isOtherArchAsset(name string) bool {
+	return otherArchRegex.MatchString(name) && !IsHostArchAsset(name)
+}
+
+type githubAssetsResp struct {
+	Assets []struct {
+		BrowserDownloadURL string `json:"browser_download_url"`
+		Name               string `json:"name"`
+	} `json:"assets"`
+	TagName string `json:"tag_name"`
+}
+
+// AssetMatch selects the asset to download from the assets of a release.
+type AssetMatch struct {
+	Exclude []string
+	Include []string
+	Pattern string
+	Source  ReleaseSource
+}
+
+// ReleaseAsset selects the asset to download from a release of a forge by matching the names of its assets.
+type ReleaseAsset struct {
+	// Regex for selecting the asset, instead of giving its file name in url.
+	Asset        string   `yaml:"asset,omitempty"`
+	AssetExclude []string `yaml:"asset_exclude,omitempty"`
+	AssetInclude []string `yaml:"asset_include,omitempty"`
+}
+
+// Match returns the asset match for the source, or nil if no asset pattern is set.
+func (a ReleaseAsset) Match(source ReleaseSource) *AssetMatch {
+	if a.Asset == "" {
+		return nil
+	}
+
+	return &AssetMatch{Exclude: a.AssetExclude, Include: a.AssetInclude, Pattern: a.Asset, Source: source}
+}
+
+func (a ReleaseAsset) validateFor(r Release) []fieldError {
+	var errs []fieldError
+	if a.Asset == "" {
+		if len(a.AssetExclude) > 0 || len(a.AssetInclude) > 0 {
+			errs = append(errs, fieldError{field: "asset",
+				err: fmt.Errorf("asset_exclude and asset_include require asset")})
+		}
+		return errs
+	}
+
+	if r.Url != "" || len(r.ArchURL) > 0 {
+		errs = append(errs, fieldError{field: "asset", err: fmt.Errorf("asset can't be used with url or arch_url")})
+	}
+	if r.Checksum != "" {
+		errs = append(errs, fieldError{field: "checksum",
+			err: fmt.Errorf("checksum can't be used with asset as it differs by architecture, use checksum_url")})
+	}
+	// Expansions are resolved at download time, so only the patterns without them can be checked here.
+	if _, err := regexp.Compile(a.Asset); err != nil && !strings.Contains(a.Asset, "${") {
+		errs = append(errs, fieldError{field: "asset", err: fmt.Errorf("invalid asset pattern: %v", err)})
+	}
+	if _, err := compileAll(a.AssetExclude); err != nil {
+		errs = append(errs, fieldError{field: "asset_exclude", err: fmt.Errorf("invalid pattern: %v", err)})
+	}
+	if _, err := compileAll(a.AssetInclude); err != nil {
+		errs = append(errs, fieldError{field: "asset_include", err: fmt.Errorf("invalid pattern: %v", err)})
+	}
+
+	return errs
+}
+
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	var regexes []*regexp.Regexp
+	for _, pattern := range patterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		regexes = append(regexes, regex)
+	}
+
+	return regexes, nil
+}
+
+func matchesAny(regexes []*regexp.Regexp, name string) bool {
+	for _, regex := range regexes {
+		if regex.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func assetNames(assets []forgeAsset) string {
+	var names []string
+	for _, asset := range assets {
+		names = append(names, asset.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// selectAsset returns the only Linux asset for the host architecture with a name matching the pattern and the include
+// patterns but none of the exclude patterns, preferring assets naming the host architecture to architecture
+// independent ones.
+func (a AssetMatch) selectAsset(assets []forgeAsset, pattern string) (forgeAsset, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return forgeAsset{}, fmt.Errorf("invalid asset pattern %s: %v", pattern, err)
+	}
+	includes, err := compileAll(a.Include)
+	if err != nil {
+		return forgeAsset{}, fmt.Errorf("invalid asset include pattern: %v", err)
+	}
+	excludes, err := compileAll(a.Exclude)
+	if err != nil {
+		return forgeAsset{}, fmt.Errorf("invalid asset exclude pattern: %v", err)
+	}
+
+	var candidates []forgeAsset
+	for _, asset := range assets {
+		name := asset.Name
+		if !regex.MatchString(name) || IsOtherPlatformAsset(name) || isOtherArchAsset(name) {
+			continue
+		}
+		if matchesAny(excludes, name) || (len(includes) > 0 && !matchesAny(includes, name)) {
+			continue
+		}
+		candidates = append(candidates, asset)
+	}
+
+	if len(candidates) > 1 {
+		var hostArchCandidates []forgeAsset
+		for _, candidate := range candidates {
+			if IsHostArchAsset(candidate.Name) {
+				hostArchCandidates = append(hostArchCandidates, candidate)
+			}
+		}
+		if len(hostArchCandidates) > 0 {
+			candidates = hostArchCandidates
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return forgeAsset{}, fmt.Errorf("no asset of %s matches %s for architecture %s, assets: %s", a.Source.Repo, pattern,
+			internal.Arch(), assetNames(assets))
+	case 1:
+		return candidates[0], nil
+	default:
+		return forgeAsset{}, fmt.Errorf("several assets of %s match %s for architecture %s: %s", a.Source.Repo, pattern,
+			internal.Arch(), assetNames(candidates))
+	}
+}
+
+// release returns the release for the version, trying the version with a v prefix as the tag if there's no release
+// tagged with the version itself, or the latest release if the version is empty.
+func (a AssetMatch) release(s settings.Settings, version string) (release forgeRelease, err error) {
+	tags := []string{version}
+	if version != "" && !strings.HasPrefix(version, "v") {
+		tags = append(tags, "v"+version)
+	}
+
+	for _, tag := range tags {
+		release, err = a.Source.release(tag, s.Internal.GhAvailable)
+		if err == nil {
+			return release, nil
+		}
+	}
+
+	return release, fmt.Errorf("error getting assets of release %s of %s: %v", version, a.Source.Repo, err)
+}
+
+// URL returns the download URL of the matching asset of the release for the version.
+func (a AssetMatch) URL(s settings.Settings, version string) (string, error) {
+	release, err := a.release(s, version)
+	if err != nil {
+		return "", err
+	}
+
+	if version == "" {
+		version = release.TagName
+	}
+	pattern := a.Pattern
+	if strings.Contains(pattern, "${") {
+		pattern = settings.ExpandStringWithLookup(s, pattern, map[string]string{"version": version})
+	}
+	asset, err := a.selectAsset(release.Assets, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	internal.Logger.Trace().Str("repo", a.Source.Repo).Str("tag", release.TagName).Str("asset", asset.Name).Msg(
+		"Selected release asset")
+	return asset.DownloadURL, nil
+}
@@ -0,0 +1,148 @@
+package entity
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/settings"
+)
+
+func Test_selectAsset(t *testing.T) {
+	hostAsset := "foo-1.0.0-linux-" + internal.GoArch() + ".tar.gz"
+	otherAsset := "foo-1.0.0-linux-" + internal.OtherArchNames()[0] + ".tar.gz"
+	muslAsset := "foo-1.0.0-" + internal.Arch() + "-musl.tar.gz"
+	assets := []forgeAsset{
+		{Name: "foo-1.0.0-darwin-arm64.tar.gz"},
+		{Name: hostAsset},
+		{Name: hostAsset + ".sha256"},
+		{Name: muslAsset},
+		{Name: otherAsset},
+		{Name: "foo-1.0.0.sh"},
+		{Name: "foo_1.0.0_amd64.deb"},
+	}
+
+	tests := []struct {
+		name    string
+		match   AssetMatch
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "Several matches",
+			pattern: "^foo-",
+			wantErr: "several assets of foo/foo match ^foo-",
+		},
+		{
+			name:    "Exclude",
+			match:   AssetMatch{Exclude: []string{"musl"}},
+			pattern: "^foo-",
+			want:    hostAsset,
+		},
+		{
+			name:    "Include",
+			match:   AssetMatch{Include: []string{"musl"}},
+			pattern: "tar\\.gz$",
+			want:    muslAsset,
+		},
+		{
+			name:    "Architecture independent",
+			pattern: "\\.sh$",
+			want:    "foo-1.0.0.sh",
+		},
+		{
+			name:    "Other architecture",
+			pattern: "^" + strings.ReplaceAll(otherAsset, ".", "\\.") + "$",
+			wantErr: "no asset of foo/foo matches",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.match.Source = NewReleaseSource(forgeGithub, "", "foo/foo")
+			got, err := tt.match.selectAsset(assets, tt.pattern)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectAsset() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectAsset() error = %v", err)
+			}
+			if got.Name != tt.want {
+				t.Errorf("selectAsset() got = %v, want %v", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssetMatch_URL(t *testing.T) {
+	asset := "foo-linux-" + internal.GoArch() + ".tar.gz"
+	downloadURL := "https://example.com/" + asset
+	assetsResp := fmt.Sprintf(`{"tag_name": "v1.0.0", "assets": [{"name": %q, "browser_download_url": %q}]}`, asset,
+		downloadURL)
+
+	tests := []struct {
+		name    string
+		forge   string
+		path    string
+		resp    string
+		version string
+	}{
+		{
+			name:    "GitHub tag with v prefix",
+			forge:   forgeGithub,
+			path:    "/repos/foo/foo/releases/tags/v1.0.0",
+			resp:    assetsResp,
+			version: "1.0.0",
+		},
+		{
+			name:  "Latest Gitea release",
+			forge: forgeGitea,
+			path:  "/api/v1/repos/foo/foo/releases/latest",
+			resp:  assetsResp,
+		},
+		{
+			name:  "GitLab release",
+			forge: forgeGitlab,
+			path:  "/api/v4/projects/foo%2Ffoo/releases/1.0.0",
+			resp: fmt.Sprintf(`{"tag_name": "1.0.0", "assets": {"links": [{"name": %q, "direct_asset_url": %q}]}}`,
+				asset, downloadURL),
+			version: "1.0.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.EscapedPath() != tt.path {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprint(w, tt.resp)
+			}))
+			defer server.Close()
+
+			defaultBase := apiBase
+			apiBase = server.URL
+			defer func() { apiBase = defaultBase }()
+
+			baseURL := server.URL
+			if tt.forge == forgeGithub {
+				baseURL = ""
+			}
+			match := AssetMatch{Pattern: "^foo-linux-${goarch}\\.tar\\.gz$",
+				Source: NewReleaseSource(tt.forge, baseURL, "foo/foo")}
+			got, err := match.URL(settings.Settings{}, tt.version)
+			if err != nil {
+				t.Fatalf("URL() error = %v", err)
+			}
+			if got != downloadURL {
+				t.Errorf("URL() got = %v, want %v", got, downloadURL)
+			}
+		})
+	}
+}
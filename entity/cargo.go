@@ -9,14 +9,16 @@ import (
 
 type CargoPkg struct {
 	unless.BasicUnlessable
-	Binaries []string      `yaml:"binaries"`
-	Bins     bool          `yaml:"bins"`
-	Branch   string        `yaml:"branch"`
-	Crate    string        `yaml:"name"`
-	Tag      string        `yaml:"tag"`
-	Unless   unless.Unless `yaml:"unless"`
-	Version  string        `yaml:"version"`
-	When     string        `yaml:"when"`
+	Meta          `yaml:",inline"`
+	Binaries      []string          `yaml:"binaries"`
+	Bins          bool              `yaml:"bins"`
+	Branch        string            `yaml:"branch"`
+	Crate         string            `yaml:"name"`
+	Tag           string            `yaml:"tag"`
+	Unless        unless.Unless     `yaml:"unless"`
+	Version       string            `yaml:"version"`
+	VersionLookup VersionLookupSpec `yaml:"version_lookup"`
+	When          string            `yaml:"when"`
 }
 
 func (c CargoPkg) DefaultVersionCmd() string {
@@ -27,8 +29,33 @@ func (c CargoPkg) GetUnless() unless.Unless {
 	return c.Unless
 }
 
-func (c CargoPkg) LookupVersion(_ settings.Settings) (string, error) {
-	return c.Version, nil
+func (c CargoPkg) GetVersion() string {
+	return c.Version
+}
+
+func (c CargoPkg) GetVersionLookup() VersionLookupSpec {
+	return c.VersionLookup
+}
+
+func (c CargoPkg) GetLookupID() string {
+	return c.Name()
+}
+
+// LockID returns the ID of the Cargo package in the version lock file.
+func (c CargoPkg) LockID() string {
+	return lockID("rust", c.Name())
+}
+
+func (c CargoPkg) LookupVersion(s settings.Settings) (string, error) {
+	return getVersion(c, s)
+}
+
+func (c CargoPkg) ConfiguredVersion(s settings.Settings) string {
+	return configuredVersion(c, s)
+}
+
+func (c CargoPkg) LatestVersion(s settings.Settings) (string, error) {
+	return latestVersion(c, s)
 }
 
 func (c CargoPkg) Name() string {
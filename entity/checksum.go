@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/femnad/fup/remote"
+	"github.com/femnad/fup/settings"
+)
+
+// Integrity declares how to verify a downloaded file, either with its checksum or with a checksums file listing it.
+type Integrity struct {
+	// In the form sha256:<sum>.
+	Checksum string `yaml:"checksum,omitempty"`
+	// A checksums file in the format of sha256sum output, such as a SHA256SUMS release asset.
+	ChecksumURL string `yaml:"checksum_url,omitempty"`
+}
+
+func (i Integrity) HasChecksum() bool {
+	return i.Checksum != "" || i.ChecksumURL != ""
+}
+
+// ExpectedSHA256 returns the SHA256 sum the file downloaded from the given URL should have, or an empty string if no
+// checksum is declared.
+func (i Integrity) ExpectedSHA256(s settings.Settings, url string, lookup map[string]string) (string, error) {
+	return i.expectedSHA256(s, url, lookup, nil)
+}
+
+// expectedSHA256 returns the expected SHA256 sum, only trusting a fetched checksums file if verify accepts it.
+func (i Integrity) expectedSHA256(s settings.Settings, url string, lookup map[string]string,
+	verify func([]byte) error) (string, error) {
+	if lookup == nil {
+		lookup = map[string]string{}
+	}
+
+	if i.Checksum != "" {
+		return remote.ParseChecksum(settings.ExpandStringWithLookup(s, i.Checksum, lookup))
+	}
+
+	if i.ChecksumURL != "" {
+		checksumURL := settings.ExpandStringWithLookup(s, i.ChecksumURL, lookup)
+		return remote.LookupChecksum(checksumURL, remote.FileName(url), verify)
+	}
+
+	return "", nil
+}
+
+func (i Integrity) validate() []fieldError {
+	var errs []fieldError
+	if i.Checksum != "" && i.ChecksumURL != "" {
+		errs = append(errs, fieldError{field: "checksum_url",
+			err: fmt.Errorf("checksum and checksum_url are mutually exclusive")})
+	}
+
+	// Checksums with expansions can only be checked after expanding.
+	if i.Checksum != "" && !strings.Contains(i.Checksum, "$") {
+		if _, err := remote.ParseChecksum(i.Checksum); err != nil {
+			errs = append(errs, fieldError{field: "checksum", err: err})
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/femnad/fup/settings"
+)
+
+func TestIntegrity_ExpectedSHA256(t *testing.T) {
+	const sum = "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+	tests := []struct {
+		name      string
+		integrity Integrity
+		lookup    map[string]string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name: "No checksum",
+		},
+		{
+			name:      "Checksum without lookup",
+			integrity: Integrity{Checksum: "sha256:" + sum},
+			want:      sum,
+		},
+		{
+			name:      "Expanded checksum",
+			integrity: Integrity{Checksum: "sha256:${version}"},
+			lookup:    map[string]string{"version": sum},
+			want:      sum,
+		},
+		{
+			name:      "Invalid checksum",
+			integrity: Integrity{Checksum: "md5:" + sum},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.integrity.ExpectedSHA256(settings.Settings{}, "https://example.com/foo.tar.gz", tt.lookup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpectedSHA256() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExpectedSHA256() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,14 @@
 package entity
 
 import (
+	"slices"
+
 	"github.com/femnad/fup/settings"
 )
 
 type Config struct {
-	Filename        string
-	Remote          bool
+	Filename        string            `yaml:"-"`
+	Remote          bool              `yaml:"-"`
 	AcceptHostKeys  []string          `yaml:"host_key"`
 	AptRepos        []AptRepo         `yaml:"apt_repo"`
 	Archives        []Archive         `yaml:"archive"`
@@ -15,10 +17,13 @@ type Config struct {
 	DnfRepos        []DnfRepo         `yaml:"dnf_repo"`
 	EnsureLines     []LineInFile      `yaml:"line"`
 	Flatpak         Flatpak           `yaml:"flatpak"`
+	GiteaReleases   []GiteaRelease    `yaml:"gitea-release"`
 	GithubReleases  []GithubRelease   `yaml:"github-release"`
 	GithubUserKey   UserKey           `yaml:"github_key"`
+	GitlabReleases  []GitlabRelease   `yaml:"gitlab-release"`
 	Go              []GoPkg           `yaml:"go"`
 	Hints           []Hint            `yaml:"hint"`
+	Include         []string          `yaml:"include"`
 	Packages        PackageSpec       `yaml:"package"`
 	PostflightTasks []Task            `yaml:"postflight"`
 	PreflightTasks  []Task            `yaml:"preflight"`
@@ -36,6 +41,7 @@ type Config struct {
 }
 
 type UserGroupSpec struct {
+	Meta   `yaml:",inline"`
 	Name   string  `yaml:"name"`
 	Ensure bool    `yaml:"ensure"`
 	Groups []Group `yaml:"groups"`
@@ -52,3 +58,43 @@ func (c Config) IsRemote() bool {
 func (c Config) File() string {
 	return c.Filename
 }
+
+// Merge returns the config with the sections of other appended to the ones in c, and the settings of other taking
+// precedence. The file and includes of c are kept.
+func (c Config) Merge(other Config) Config {
+	merged := c
+	merged.AcceptHostKeys = slices.Concat(c.AcceptHostKeys, other.AcceptHostKeys)
+	merged.AptRepos = slices.Concat(c.AptRepos, other.AptRepos)
+	merged.Archives = slices.Concat(c.Archives, other.Archives)
+	merged.Cargo = slices.Concat(c.Cargo, other.Cargo)
+	merged.Dirs = slices.Concat(c.Dirs, other.Dirs)
+	merged.DnfRepos = slices.Concat(c.DnfRepos, other.DnfRepos)
+	merged.EnsureLines = slices.Concat(c.EnsureLines, other.EnsureLines)
+	merged.Flatpak.Remotes = slices.Concat(c.Flatpak.Remotes, other.Flatpak.Remotes)
+	merged.Flatpak.Packages = slices.Concat(c.Flatpak.Packages, other.Flatpak.Packages)
+	merged.GiteaReleases = slices.Concat(c.GiteaReleases, other.GiteaReleases)
+	merged.GithubReleases = slices.Concat(c.GithubReleases, other.GithubReleases)
+	merged.GitlabReleases = slices.Concat(c.GitlabReleases, other.GitlabReleases)
+	merged.Go = slices.Concat(c.Go, other.Go)
+	merged.Hints = slices.Concat(c.Hints, other.Hints)
+	merged.Packages = slices.Concat(c.Packages, other.Packages)
+	merged.PostflightTasks = slices.Concat(c.PostflightTasks, other.PostflightTasks)
+	merged.PreflightTasks = slices.Concat(c.PreflightTasks, other.PreflightTasks)
+	merged.Python = slices.Concat(c.Python, other.Python)
+	merged.Releases = slices.Concat(c.Releases, other.Releases)
+	merged.RemotePackages = slices.Concat(c.RemotePackages, other.RemotePackages)
+	merged.Repos = slices.Concat(c.Repos, other.Repos)
+	merged.Services = slices.Concat(c.Services, other.Services)
+	merged.Settings = c.Settings.Merge(other.Settings)
+	merged.SnapPackages = slices.Concat(c.SnapPackages, other.SnapPackages)
+	merged.Tasks = slices.Concat(c.Tasks, other.Tasks)
+	merged.Templates = slices.Concat(c.Templates, other.Templates)
+	merged.UserInGroup = slices.Concat(c.UserInGroup, other.UserInGroup)
+	merged.UvTools = slices.Concat(c.UvTools, other.UvTools)
+
+	if other.GithubUserKey.User != "" {
+		merged.GithubUserKey = other.GithubUserKey
+	}
+
+	return merged
+}
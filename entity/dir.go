@@ -1,6 +1,7 @@
 package entity
 
 type DirGroup struct {
+	Meta   `yaml:",inline"`
 	Absent bool     `yaml:"absent"`
 	Names  []string `yaml:"names"`
 }
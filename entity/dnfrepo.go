@@ -12,11 +12,13 @@ import (
 	"github.com/femnad/fup/internal"
 	"github.com/femnad/fup/precheck"
 	"github.com/femnad/fup/precheck/unless"
+	"github.com/femnad/fup/remote"
 	"github.com/femnad/fup/settings"
 	marecmd "github.com/femnad/mare/cmd"
 )
 
 const (
+	gpgKeyDir        = "/etc/pki/rpm-gpg"
 	pluginsCore      = "dnf-plugins-core"
 	repoFileTemplate = `[{{ .Name }}]
 name="{{ .Description }}"
@@ -49,6 +51,7 @@ type repoSpec struct {
 
 type DnfRepo struct {
 	unless.BasicUnlessable
+	Meta     `yaml:",inline"`
 	RepoName string     `yaml:"name"`
 	Packages []string   `yaml:"packages"`
 	Repo     string     `yaml:"repo"`
@@ -143,6 +146,40 @@ func checkValidity(spec repoSpec) error {
 	return nil
 }
 
+// KeyURLs returns the URLs the repo keys are downloaded from.
+func (d DnfRepo) KeyURLs() []string {
+	var urls []string
+	for _, spec := range d.RepoSpec {
+		if isURL(spec.GPGKey) {
+			urls = append(urls, spec.GPGKey)
+		}
+	}
+
+	return urls
+}
+
+// localKey saves a key downloaded from a URL, for use when rpm and dnf can't download it, and returns its file URL.
+func localKey(name, keyURL string) (string, error) {
+	key, err := remote.ReadResponseBytes(keyURL)
+	if err != nil {
+		return "", err
+	}
+
+	keyFile := path.Join(gpgKeyDir, fmt.Sprintf("RPM-GPG-KEY-%s", name))
+	_, err = internal.WriteContent(internal.ManagedFile{
+		Content: string(key),
+		Path:    keyFile,
+		Mode:    0o644,
+		User:    "root",
+		Group:   "root",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "file://" + keyFile, nil
+}
+
 func writeRepoSpec(name string, spec repoSpec) error {
 	err := checkValidity(spec)
 	if err != nil {
@@ -159,6 +196,14 @@ func writeRepoSpec(name string, spec repoSpec) error {
 		return nil
 	}
 
+	// Requests are only served from a bundle in process.
+	if remote.UsingBundle() && isURL(spec.GPGKey) {
+		spec.GPGKey, err = localKey(name, spec.GPGKey)
+		if err != nil {
+			return err
+		}
+	}
+
 	out := bytes.Buffer{}
 
 	err = tmpl.Execute(&out, spec)
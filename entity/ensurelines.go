@@ -1,5 +1,19 @@
 package entity
 
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	LineEnsure  = "ensure"
+	LineReplace = "replace"
+)
+
+var (
+	lineModes = []string{LineEnsure, LineReplace}
+)
+
 type Replacement struct {
 	Absent bool   `yaml:"absent"`
 	Ensure bool   `yaml:"ensure"`
@@ -9,6 +23,7 @@ type Replacement struct {
 }
 
 type LineInFile struct {
+	Meta     `yaml:",inline"`
 	Content  string        `yaml:"content"`
 	File     string        `yaml:"file"`
 	Lines    []string      `yaml:"lines"`
@@ -21,3 +36,16 @@ type LineInFile struct {
 func (l LineInFile) RunWhen() string {
 	return l.When
 }
+
+func (LineInFile) schemaEnums() map[string][]string {
+	return map[string][]string{"name": lineModes}
+}
+
+func (l LineInFile) validate() []fieldError {
+	if slices.Contains(lineModes, l.Name) {
+		return nil
+	}
+
+	return []fieldError{{field: "name", err: fmt.Errorf("unknown line mode %q, expected %s or %s", l.Name,
+		LineEnsure, LineReplace)}}
+}
@@ -6,6 +6,7 @@ type FlatpakRemote struct {
 }
 
 type FlatpakPkg struct {
+	Meta     `yaml:",inline"`
 	Launcher string `yaml:"launcher"`
 	Name     string `yaml:"name"`
 	Remote   string `yaml:"remote"`
@@ -0,0 +1,207 @@
+package entity
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	forgeGitea       = "gitea"
+	forgeGithub      = "github"
+	forgeGitlab      = "gitlab"
+	defaultGiteaURL  = "https://codeberg.org"
+	defaultGithubURL = "https://github.com"
+	defaultGitlabURL = "https://gitlab.com"
+	giteaTokenEnv    = "GITEA_TOKEN"
+	gitlabTokenEnv   = "GITLAB_TOKEN"
+)
+
+var defaultForgeURLs = map[string]string{
+	forgeGitea:  defaultGiteaURL,
+	forgeGithub: defaultGithubURL,
+	forgeGitlab: defaultGitlabURL,
+}
+
+type forgeAsset struct {
+	DownloadURL string
+	Name        string
+}
+
+type forgeRelease struct {
+	Assets  []forgeAsset
+	TagName string
+}
+
+type gitlabReleaseResp struct {
+	Assets struct {
+		Links []struct {
+			DirectAssetURL string `json:"direct_asset_url"`
+			Name           string `json:"name"`
+		} `json:"links"`
+	} `json:"assets"`
+	TagName string `json:"tag_name"`
+}
+
+// ReleaseSource is a repo with releases on GitHub, GitLab or a Gitea instance such as Forgejo or Codeberg.
+type ReleaseSource struct {
+	BaseURL string
+	Forge   string
+	Repo    string
+}
+
+// NewReleaseSource returns the source for the repo on the forge, on its public instance if the base URL is empty.
+func NewReleaseSource(forge, baseURL, repo string) ReleaseSource {
+	if baseURL == "" {
+		baseURL = defaultForgeURLs[forge]
+	}
+
+	return ReleaseSource{BaseURL: strings.TrimSuffix(baseURL, "/"), Forge: forge, Repo: repo}
+}
+
+// RepoURL returns the web URL of the repo.
+func (r ReleaseSource) RepoURL() string {
+	return fmt.Sprintf("%s/%s", r.BaseURL, r.Repo)
+}
+
+// DownloadURL returns the URL of the path relative to the releases of the repo, such as ${version}/foo.tar.gz for GitHub
+// and Gitea or ${version}/downloads/foo.tar.gz for GitLab.
+func (r ReleaseSource) DownloadURL(path string) string {
+	if r.Forge == forgeGitlab {
+		return fmt.Sprintf("%s/-/releases/%s", r.RepoURL(), path)
+	}
+
+	return fmt.Sprintf("%s/releases/download/%s", r.RepoURL(), path)
+}
+
+// tokenHeader returns the header for authenticating with the token in the environment variable, or in the forge's
+// default one if the variable name is empty. The header value refers to the variable, which is expanded when sending
+// requests. Returns false if the variable isn't set.
+func (r ReleaseSource) tokenHeader(tokenEnv string) (key, value string, ok bool) {
+	switch r.Forge {
+	case forgeGitea:
+		tokenEnv = cmp.Or(tokenEnv, giteaTokenEnv)
+		key, value = "Authorization", fmt.Sprintf("token ${%s}", tokenEnv)
+	case forgeGitlab:
+		tokenEnv = cmp.Or(tokenEnv, gitlabTokenEnv)
+		key, value = "PRIVATE-TOKEN", fmt.Sprintf("${%s}", tokenEnv)
+	default:
+		return "", "", false
+	}
+
+	return key, value, os.Getenv(tokenEnv) != ""
+}
+
+func (r ReleaseSource) apiURL(apiPath string) string {
+	if r.Forge == forgeGitlab {
+		return fmt.Sprintf("%s/api/v4/projects/%s/%s", r.BaseURL, url.PathEscape(r.Repo), apiPath)
+	}
+
+	return fmt.Sprintf("%s/api/v1/repos/%s/%s", r.BaseURL, r.Repo, apiPath)
+}
+
+func (r ReleaseSource) githubRelease(apiPath string, useGHClient bool) (forgeRelease, error) {
+	resp, err := githubRequest[githubAssetsResp](ghRequestSpec{
+		lookupSpec:  VersionLookupSpec{GithubRepo: r.Repo},
+		apiPathSpec: "repos/%s/" + apiPath,
+		useGHClient: useGHClient})
+	if err != nil {
+		return forgeRelease{}, err
+	}
+
+	release := forgeRelease{TagName: resp.TagName}
+	for _, asset := range resp.Assets {
+		release.Assets = append(release.Assets, forgeAsset{DownloadURL: asset.BrowserDownloadURL, Name: asset.Name})
+	}
+
+	return release, nil
+}
+
+func (r ReleaseSource) gitlabRelease(apiPath string) (forgeRelease, error) {
+	var resp gitlabReleaseResp
+	if err := readJSON(r.apiURL(apiPath), &resp); err != nil {
+		return forgeRelease{}, err
+	}
+
+	release := forgeRelease{TagName: resp.TagName}
+	for _, link := range resp.Assets.Links {
+		release.Assets = append(release.Assets, forgeAsset{DownloadURL: link.DirectAssetURL, Name: link.Name})
+	}
+
+	return release, nil
+}
+
+func (r ReleaseSource) giteaRelease(apiPath string) (forgeRelease, error) {
+	var resp githubAssetsResp
+	if err := readJSON(r.apiURL(apiPath), &resp); err != nil {
+		return forgeRelease{}, err
+	}
+
+	release := forgeRelease{TagName: resp.TagName}
+	for _, asset := range resp.Assets {
+		release.Assets = append(release.Assets, forgeAsset{DownloadURL: asset.BrowserDownloadURL, Name: asset.Name})
+	}
+
+	return release, nil
+}
+
+// release returns the release with the tag, or the latest release if the tag is empty.
+func (r ReleaseSource) release(tag string, useGHClient bool) (forgeRelease, error) {
+	switch r.Forge {
+	case forgeGithub:
+		if tag == "" {
+			return r.githubRelease("releases/latest", useGHClient)
+		}
+		return r.githubRelease("releases/tags/"+tag, useGHClient)
+	case forgeGitlab:
+		if tag == "" {
+			return r.gitlabRelease("releases/permalink/latest")
+		}
+		return r.gitlabRelease("releases/" + url.PathEscape(tag))
+	case forgeGitea:
+		if tag == "" {
+			return r.giteaRelease("releases/latest")
+		}
+		return r.giteaRelease("releases/tags/" + url.PathEscape(tag))
+	default:
+		return forgeRelease{}, fmt.Errorf("unknown forge %s", r.Forge)
+	}
+}
+
+// lookupSource returns the source of a forge version lookup, from the repo and base URL of the spec if set, or from the
+// release URL otherwise.
+func lookupSource(forge string, spec VersionLookupSpec, releaseURL string) (ReleaseSource, error) {
+	if spec.Repo != "" {
+		return NewReleaseSource(forge, spec.BaseURL, spec.Repo), nil
+	}
+	if releaseURL == "" {
+		return ReleaseSource{}, fmt.Errorf("need a release URL for determining %s repo without explicit repo config",
+			forge)
+	}
+
+	parsed, err := url.Parse(releaseURL)
+	if err != nil {
+		return ReleaseSource{}, err
+	}
+	baseURL := fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host)
+	repoPath := strings.TrimPrefix(parsed.Path, "/")
+	if spec.BaseURL != "" {
+		baseURL = strings.TrimSuffix(spec.BaseURL, "/")
+		repoPath = strings.TrimPrefix(strings.TrimPrefix(releaseURL, baseURL), "/")
+	}
+
+	var repo string
+	if forge == forgeGitlab {
+		// GitLab projects can be in subgroups, so the repo path is the part before the /-/ separator.
+		repo, _, _ = strings.Cut(repoPath, "/-/")
+	} else if fields := strings.Split(repoPath, "/"); len(fields) >= 2 {
+		repo = strings.Join(fields[:2], "/")
+	}
+	if repo == "" {
+		return ReleaseSource{}, fmt.Errorf("unable to determine %s repo from URL %s", forge, releaseURL)
+	}
+
+	return NewReleaseSource(forge, baseURL, repo), nil
+}
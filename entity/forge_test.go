@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_lookupSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		forge      string
+		spec       VersionLookupSpec
+		releaseURL string
+		want       ReleaseSource
+		wantErr    bool
+	}{
+		{
+			name:  "Explicit repo",
+			forge: forgeGitea,
+			spec:  VersionLookupSpec{Repo: "foo/bar"},
+			want:  ReleaseSource{BaseURL: defaultGiteaURL, Forge: forgeGitea, Repo: "foo/bar"},
+		},
+		{
+			name:       "GitLab project in subgroup",
+			forge:      forgeGitlab,
+			releaseURL: "https://gitlab.example.com/foo/bar/baz/-/releases/1.0.0/downloads/baz.tar.gz",
+			want:       ReleaseSource{BaseURL: "https://gitlab.example.com", Forge: forgeGitlab, Repo: "foo/bar/baz"},
+		},
+		{
+			name:       "Instance under a path",
+			forge:      forgeGitea,
+			spec:       VersionLookupSpec{BaseURL: "https://example.com/git/"},
+			releaseURL: "https://example.com/git/foo/bar/releases/download/1.0.0/bar.tar.gz",
+			want:       ReleaseSource{BaseURL: "https://example.com/git", Forge: forgeGitea, Repo: "foo/bar"},
+		},
+		{
+			name:       "No repo in URL",
+			forge:      forgeGitea,
+			releaseURL: "https://example.com/bar.tar.gz",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupSource(tt.forge, tt.spec, tt.releaseURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lookupSource() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseSource_DownloadURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		source ReleaseSource
+		want   string
+	}{
+		{
+			name:   "GitHub",
+			source: NewReleaseSource(forgeGithub, "", "foo/bar"),
+			want:   "https://github.com/foo/bar/releases/download/${version}/bar.tar.gz",
+		},
+		{
+			name:   "GitLab",
+			source: NewReleaseSource(forgeGitlab, "", "foo/bar"),
+			want:   "https://gitlab.com/foo/bar/-/releases/${version}/bar.tar.gz",
+		},
+		{
+			name:   "Self-hosted Forgejo",
+			source: NewReleaseSource(forgeGitea, "https://git.example.com/", "foo/bar"),
+			want:   "https://git.example.com/foo/bar/releases/download/${version}/bar.tar.gz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.source.DownloadURL("${version}/bar.tar.gz"); got != tt.want {
+				t.Errorf("DownloadURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_TokenHeaders(t *testing.T) {
+	t.Setenv(gitlabTokenEnv, "")
+	t.Setenv("FORGEJO_TOKEN", "secret")
+
+	config := Config{
+		GiteaReleases: []GiteaRelease{
+			{ForgeRelease{BaseURL: "https://git.example.com", TokenEnv: "FORGEJO_TOKEN", Release: Release{Ref: "foo/bar"}}},
+		},
+		GitlabReleases: []GitlabRelease{{ForgeRelease{Release: Release{Ref: "foo/baz"}}}},
+	}
+
+	want := map[string]map[string]string{"git.example.com": {"Authorization": "token ${FORGEJO_TOKEN}"}}
+	if got := config.TokenHeaders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TokenHeaders() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"net/url"
+)
+
+// ForgeRelease is a release of a repo on a self-hostable forge, with the repo given as the name.
+type ForgeRelease struct {
+	ReleaseAsset `yaml:",inline"`
+	// URL of the instance, the public instance of the forge is used if empty.
+	BaseURL  string `yaml:"base_url,omitempty"`
+	ExecName string `yaml:"exec-name,omitempty"`
+	// Environment variable with the access token, GITLAB_TOKEN or GITEA_TOKEN if empty.
+	TokenEnv string `yaml:"token_env,omitempty"`
+	Release  `yaml:",inline"`
+}
+
+func (f ForgeRelease) validate() []fieldError {
+	return f.validateFor(f.Release)
+}
+
+// GitlabRelease is a release of a project on GitLab, with its url relative to the project's releases.
+type GitlabRelease struct {
+	ForgeRelease `yaml:",inline"`
+}
+
+func (g GitlabRelease) Source() ReleaseSource {
+	return NewReleaseSource(forgeGitlab, g.BaseURL, g.Ref)
+}
+
+// GiteaRelease is a release of a repo on a Gitea instance such as Forgejo or Codeberg, with its url relative to the
+// repo's release downloads as for GitHub releases.
+type GiteaRelease struct {
+	ForgeRelease `yaml:",inline"`
+}
+
+func (g GiteaRelease) Source() ReleaseSource {
+	return NewReleaseSource(forgeGitea, g.BaseURL, g.Ref)
+}
+
+// TokenHeaders returns the headers for authenticating with the GitLab and Gitea instances by host, for the instances
+// whose token variables are set.
+func (c Config) TokenHeaders() map[string]map[string]string {
+	var headers map[string]map[string]string
+	add := func(source ReleaseSource, tokenEnv string) {
+		key, value, ok := source.tokenHeader(tokenEnv)
+		if !ok {
+			return
+		}
+		parsed, err := url.Parse(source.BaseURL)
+		if err != nil {
+			return
+		}
+		if headers == nil {
+			headers = make(map[string]map[string]string)
+		}
+		if headers[parsed.Hostname()] == nil {
+			headers[parsed.Hostname()] = make(map[string]string)
+		}
+		headers[parsed.Hostname()][key] = value
+	}
+
+	for _, release := range c.GitlabReleases {
+		add(release.Source(), release.TokenEnv)
+	}
+	for _, release := range c.GiteaReleases {
+		add(release.Source(), release.TokenEnv)
+	}
+
+	return headers
+}
@@ -171,21 +171,35 @@ func clone(repo Repo, repoUrl cloneRef, cloneDir string) error {
 	return nil
 }
 
-func CloneUnderPath(repo Repo, pathOverride string, settings settings.Settings) error {
-	cloneEnv := settings.CloneEnv
-	ref, err := processUrl(repo.Name)
-	if err != nil {
-		return err
-	}
-
+func getCloneDir(ref cloneRef, pathOverride string, settings settings.Settings) string {
 	var cloneDir string
 	if pathOverride != "" {
 		cloneDir = path.Join(pathOverride, ref.base)
 	} else {
 		cloneDir = path.Join(settings.CloneDir, ref.org, ref.base)
 	}
-	cloneDir = internal.ExpandUser(cloneDir)
 
+	return internal.ExpandUser(cloneDir)
+}
+
+// GetCloneDir returns the directory the given repo would be cloned into.
+func GetCloneDir(repo Repo, pathOverride string, settings settings.Settings) (string, error) {
+	ref, err := processUrl(repo.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return getCloneDir(ref, pathOverride, settings), nil
+}
+
+func CloneUnderPath(repo Repo, pathOverride string, settings settings.Settings) error {
+	cloneEnv := settings.CloneEnv
+	ref, err := processUrl(repo.Name)
+	if err != nil {
+		return err
+	}
+
+	cloneDir := getCloneDir(ref, pathOverride, settings)
 	_, err = os.Stat(cloneDir)
 	if err == nil {
 		return nil
@@ -1,6 +1,15 @@
 package entity
 
 type GithubRelease struct {
-	ExecName string `yaml:"exec-name,omitempty"`
-	Release  `yaml:",inline"`
+	ReleaseAsset `yaml:",inline"`
+	ExecName     string `yaml:"exec-name,omitempty"`
+	Release      `yaml:",inline"`
+}
+
+func (g GithubRelease) Source() ReleaseSource {
+	return NewReleaseSource(forgeGithub, "", g.Ref)
+}
+
+func (g GithubRelease) validate() []fieldError {
+	return g.validateFor(g.Release)
 }
@@ -9,10 +9,12 @@ import (
 
 type GoPkg struct {
 	unless.BasicUnlessable
-	Pkg     string        `yaml:"name"`
-	Unless  unless.Unless `yaml:"unless"`
-	Version string        `yaml:"version"`
-	When    string        `yaml:"when"`
+	Meta          `yaml:",inline"`
+	Pkg           string            `yaml:"name"`
+	Unless        unless.Unless     `yaml:"unless"`
+	Version       string            `yaml:"version"`
+	VersionLookup VersionLookupSpec `yaml:"version_lookup"`
+	When          string            `yaml:"when"`
 }
 
 func (g GoPkg) DefaultVersionCmd() string {
@@ -23,12 +25,33 @@ func (g GoPkg) GetUnless() unless.Unless {
 	return g.Unless
 }
 
+func (g GoPkg) GetVersion() string {
+	return g.Version
+}
+
+func (g GoPkg) GetVersionLookup() VersionLookupSpec {
+	return g.VersionLookup
+}
+
+func (g GoPkg) GetLookupID() string {
+	return g.Name()
+}
+
+// LockID returns the ID of the Go package in the version lock file.
+func (g GoPkg) LockID() string {
+	return lockID("go", g.Name())
+}
+
 func (g GoPkg) LookupVersion(s settings.Settings) (string, error) {
-	if g.Version != "" {
-		return g.Version, nil
-	}
+	return getVersion(g, s)
+}
+
+func (g GoPkg) ConfiguredVersion(s settings.Settings) string {
+	return configuredVersion(g, s)
+}
 
-	return s.Versions[g.Name()], nil
+func (g GoPkg) LatestVersion(s settings.Settings) (string, error) {
+	return latestVersion(g, s)
 }
 
 func (g GoPkg) Name() string {
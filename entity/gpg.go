@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+
+	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/remote"
+)
+
+const (
+	armorPrefix = "-----BEGIN PGP"
+)
+
+func isArmored(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte(armorPrefix))
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// readKey returns a GPG key given either inline as an armored key, as a URL or as a file path.
+func readKey(key string) ([]byte, error) {
+	switch {
+	case isArmored([]byte(key)):
+		return []byte(key), nil
+	case isURL(key):
+		return remote.ReadResponseBytes(key)
+	default:
+		return os.ReadFile(internal.ExpandUser(key))
+	}
+}
+
+// dearmorKey returns the binary form of a key, which is returned as is if it's not armored.
+func dearmorKey(key []byte) ([]byte, error) {
+	if !isArmored(key) {
+		return key, nil
+	}
+
+	block, err := armor.Decode(bytes.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding armored key: %v", err)
+	}
+
+	return io.ReadAll(block.Body)
+}
+
+// verifySignature checks that the detached signature, which can be armored, is a signature of the signed content by
+// the given key.
+func verifySignature(key, signed, signature []byte) error {
+	binaryKey, err := dearmorKey(key)
+	if err != nil {
+		return err
+	}
+
+	keyRing, err := openpgp.ReadKeyRing(bytes.NewReader(binaryKey))
+	if err != nil {
+		return fmt.Errorf("error reading key: %v", err)
+	}
+
+	check := openpgp.CheckDetachedSignature
+	if isArmored(signature) {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+
+	_, err = check(keyRing, bytes.NewReader(signed), bytes.NewReader(signature), nil)
+	return err
+}
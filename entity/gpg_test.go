@@ -0,0 +1,88 @@
+package entity
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func armoredPublicKey(t *testing.T, e *openpgp.Entity) []byte {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("error armoring key: %v", err)
+	}
+	if err = e.Serialize(w); err != nil {
+		t.Fatalf("error serializing key: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("error closing armor: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func Test_verifySignature(t *testing.T) {
+	signer, err := openpgp.NewEntity("signer", "", "signer@example.com", nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	checksums := []byte("b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  foo.tar.gz\n")
+	var armored, binary bytes.Buffer
+	if err = openpgp.ArmoredDetachSign(&armored, signer, bytes.NewReader(checksums), nil); err != nil {
+		t.Fatalf("error signing: %v", err)
+	}
+	if err = openpgp.DetachSign(&binary, signer, bytes.NewReader(checksums), nil); err != nil {
+		t.Fatalf("error signing: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		key       []byte
+		signed    []byte
+		signature []byte
+		wantErr   bool
+	}{
+		{
+			name:      "Armored signature",
+			key:       armoredPublicKey(t, signer),
+			signed:    checksums,
+			signature: armored.Bytes(),
+		},
+		{
+			name:      "Binary signature",
+			key:       armoredPublicKey(t, signer),
+			signed:    checksums,
+			signature: binary.Bytes(),
+		},
+		{
+			name:      "Tampered content",
+			key:       armoredPublicKey(t, signer),
+			signed:    append([]byte("0"), checksums[1:]...),
+			signature: armored.Bytes(),
+			wantErr:   true,
+		},
+		{
+			name:      "Untrusted signer",
+			key:       armoredPublicKey(t, other),
+			signed:    checksums,
+			signature: armored.Bytes(),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.key, tt.signed, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package entity
+
+// Meta holds the properties shared by all resources.
+type Meta struct {
+	// References to other resources in kind:name format, or kind for all resources of a kind.
+	DependsOn []string `yaml:"depends_on,omitempty"`
+	// Labels for selecting resources to run with --tags and --skip-tags.
+	Tags []string `yaml:"tags,omitempty"`
+	// Don't fail the run or skip dependents if the resource fails.
+	IgnoreErrors bool `yaml:"ignore_errors,omitempty"`
+}
+
+func (m Meta) GetMeta() Meta {
+	return m
+}
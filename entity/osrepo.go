@@ -9,5 +9,6 @@ type OSRepo interface {
 	unless.Unlessable
 	when.Whenable
 	Exists() (bool, error)
+	GetMeta() Meta
 	Install() error
 }
@@ -1,6 +1,7 @@
 package entity
 
 type RemotePackage struct {
+	Integrity `yaml:",inline"`
 	// Some packages add their repos to OS repo config, causing conflicts if a previous version is set in the config.
 	InstallOnce bool   `yaml:"install_once"`
 	Name        string `yaml:"name"`
@@ -10,6 +11,7 @@ type RemotePackage struct {
 }
 
 type RemotePackageGroup struct {
+	Meta `yaml:",inline"`
 	Pkgs []RemotePackage `yaml:"pkg"`
 	When string          `yaml:"when"`
 }
@@ -19,6 +21,7 @@ func (r RemotePackageGroup) RunWhen() string {
 }
 
 type PackageGroup struct {
+	Meta   `yaml:",inline"`
 	Absent bool     `yaml:"absent"`
 	Pkgs   []string `yaml:"pkg"`
 	When   string   `yaml:"when"`
@@ -9,6 +9,7 @@ import (
 
 type PythonPkg struct {
 	unless.BasicUnlessable
+	Meta          `yaml:",inline"`
 	BinLinks      []string          `yaml:"link"`
 	Library       bool              `yaml:"library"`
 	Pkg           string            `yaml:"name"`
@@ -30,6 +31,11 @@ func (p PythonPkg) GetLookupID() string {
 	return p.Name()
 }
 
+// LockID returns the ID of the Python package in the version lock file.
+func (p PythonPkg) LockID() string {
+	return lockID("python", p.Name())
+}
+
 func (p PythonPkg) DefaultVersionCmd() string {
 	return fmt.Sprintf("%s -V", p.Name())
 }
@@ -42,6 +48,14 @@ func (p PythonPkg) LookupVersion(s settings.Settings) (string, error) {
 	return getVersion(p, s)
 }
 
+func (p PythonPkg) ConfiguredVersion(s settings.Settings) string {
+	return configuredVersion(p, s)
+}
+
+func (p PythonPkg) LatestVersion(s settings.Settings) (string, error) {
+	return latestVersion(p, s)
+}
+
 func (p PythonPkg) Name() string {
 	return p.Pkg
 }
@@ -0,0 +1,303 @@
+package entity
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/femnad/fup/remote"
+)
+
+const (
+	defaultCratesURL   = "https://crates.io"
+	defaultGoProxyURL  = "https://proxy.golang.org"
+	defaultGoHost      = "github.com"
+	defaultNpmURL      = "https://registry.npmjs.org"
+	defaultRegistryURL = "https://registry-1.docker.io"
+	dockerHubLibrary   = "library"
+)
+
+var (
+	challengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	numberRegex         = regexp.MustCompile("[0-9]+")
+	// Tags which look like versions, such as 1.2.3 or v1.2, as opposed to latest or alpine.
+	versionTagRegex = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*$`)
+)
+
+type cratesResp struct {
+	Crate struct {
+		MaxStableVersion string `json:"max_stable_version"`
+	} `json:"crate"`
+	Versions []struct {
+		Num    string `json:"num"`
+		Yanked bool   `json:"yanked"`
+	} `json:"versions"`
+}
+
+type goProxyResp struct {
+	Version string `json:"Version"`
+}
+
+type npmResp struct {
+	Version string `json:"version"`
+}
+
+type ociTagsResp struct {
+	Tags []string `json:"tags"`
+}
+
+type ociTokenResp struct {
+	AccessToken string `json:"access_token"`
+	Token       string `json:"token"`
+}
+
+// registryName returns the name of the package or image to look up, which is the lookup ID of the resource unless the
+// spec has a repo.
+func registryName(spec VersionLookupSpec, lookupID string) (string, error) {
+	name := spec.Repo
+	if name == "" {
+		name = lookupID
+	}
+	if name == "" {
+		return "", fmt.Errorf("no package name for version lookup strategy %s", spec.Strategy)
+	}
+
+	return name, nil
+}
+
+func registryURL(spec VersionLookupSpec, defaultURL string) string {
+	if spec.BaseURL == "" {
+		return defaultURL
+	}
+
+	return strings.TrimSuffix(spec.BaseURL, "/")
+}
+
+func readJSON(url string, resp any) error {
+	content, err := remote.ReadResponseBytes(url)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, resp)
+}
+
+// cratesLatest returns the highest stable version of the crate which isn't yanked.
+func (specResolver) cratesLatest(spec VersionLookupSpec, lookupID string) (string, error) {
+	name, err := registryName(spec, lookupID)
+	if err != nil {
+		return "", err
+	}
+
+	var resp cratesResp
+	err = readJSON(fmt.Sprintf("%s/api/v1/crates/%s", registryURL(spec, defaultCratesURL), url.PathEscape(name)), &resp)
+	if err != nil {
+		return "", err
+	}
+
+	yanked := make(map[string]bool)
+	for _, version := range resp.Versions {
+		yanked[version.Num] = version.Yanked
+	}
+	if maxStable := resp.Crate.MaxStableVersion; maxStable != "" && !yanked[maxStable] {
+		return maxStable, nil
+	}
+
+	// Versions are listed newest first.
+	for _, version := range resp.Versions {
+		if !version.Yanked && !strings.Contains(version.Num, "-") {
+			return version.Num, nil
+		}
+	}
+
+	return "", fmt.Errorf("no stable version of crate %s which isn't yanked", name)
+}
+
+// escapeModulePath escapes the upper case letters of a module path as the Go module proxy expects.
+func escapeModulePath(modulePath string) string {
+	var escaped strings.Builder
+	for _, r := range modulePath {
+		if unicode.IsUpper(r) {
+			escaped.WriteRune('!')
+			r = unicode.ToLower(r)
+		}
+		escaped.WriteRune(r)
+	}
+
+	return escaped.String()
+}
+
+// goLatest returns the latest version of the module of a package, trying the package path and its parents as the
+// module path since a package doesn't have to be the root of its module.
+func (specResolver) goLatest(spec VersionLookupSpec, lookupID string) (string, error) {
+	name, err := registryName(spec, lookupID)
+	if err != nil {
+		return "", err
+	}
+	name, _, _ = strings.Cut(name, "@")
+	if host, _, _ := strings.Cut(name, "/"); !strings.Contains(host, ".") {
+		name = fmt.Sprintf("%s/%s", defaultGoHost, name)
+	}
+
+	proxyURL := registryURL(spec, defaultGoProxyURL)
+	modulePath := name
+	for {
+		var resp goProxyResp
+		err = readJSON(fmt.Sprintf("%s/%s/@latest", proxyURL, escapeModulePath(modulePath)), &resp)
+		if err == nil {
+			return resp.Version, nil
+		}
+
+		parent := path.Dir(modulePath)
+		if !strings.Contains(parent, "/") {
+			return "", fmt.Errorf("error looking up latest version of Go package %s: %v", name, err)
+		}
+		modulePath = parent
+	}
+}
+
+// npmLatest returns the version of the package with the latest dist tag.
+func (specResolver) npmLatest(spec VersionLookupSpec, lookupID string) (string, error) {
+	name, err := registryName(spec, lookupID)
+	if err != nil {
+		return "", err
+	}
+
+	var resp npmResp
+	err = readJSON(fmt.Sprintf("%s/%s/latest", registryURL(spec, defaultNpmURL), url.PathEscape(name)), &resp)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Version, nil
+}
+
+// imageRepo returns the registry and the repo of an image such as nginx, foo/bar or ghcr.io/foo/bar.
+func imageRepo(spec VersionLookupSpec, image string) (registry, repo string) {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	host, rest, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		return registryURL(spec, "https://"+host), rest
+	}
+
+	registry = registryURL(spec, defaultRegistryURL)
+	if !found && registry == defaultRegistryURL {
+		image = fmt.Sprintf("%s/%s", dockerHubLibrary, image)
+	}
+	return registry, image
+}
+
+// ociToken gets an anonymous token for the Bearer challenge of a registry.
+func ociToken(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	query := url.Values{}
+	var realm string
+	for _, match := range challengeParamRegex.FindAllStringSubmatch(params, -1) {
+		if match[1] == "realm" {
+			realm = match[2]
+			continue
+		}
+		query.Set(match[1], match[2])
+	}
+	if realm == "" {
+		return "", fmt.Errorf("no realm in authentication challenge %q", challenge)
+	}
+
+	var resp ociTokenResp
+	err := readJSON(fmt.Sprintf("%s?%s", realm, query.Encode()), &resp)
+	if err != nil {
+		return "", err
+	}
+
+	return cmp.Or(resp.Token, resp.AccessToken), nil
+}
+
+// ociTags returns the tags of the repo in the registry, authenticating with an anonymous token if the registry
+// requires one.
+func ociTags(registry, repo string) ([]string, error) {
+	tagsURL := fmt.Sprintf("%s/v2/%s/tags/list", registry, repo)
+	content, err := remote.ReadResponseBytes(tagsURL)
+	var authErr remote.AuthError
+	if errors.As(err, &authErr) {
+		var token string
+		token, err = ociToken(authErr.Challenge)
+		if err != nil {
+			return nil, err
+		}
+		content, err = remote.ReadResponseBytesWithHeaders(tagsURL, map[string]string{"Authorization": "Bearer " + token})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ociTagsResp
+	err = json.Unmarshal(content, &resp)
+	return resp.Tags, err
+}
+
+// compareVersions compares the numbers in the versions in order, such as 1, 10 and 2 in 1.10.2-alpine.
+func compareVersions(a, b string) int {
+	var aNumbers, bNumbers []int
+	for _, number := range numberRegex.FindAllString(a, -1) {
+		n, _ := strconv.Atoi(number)
+		aNumbers = append(aNumbers, n)
+	}
+	for _, number := range numberRegex.FindAllString(b, -1) {
+		n, _ := strconv.Atoi(number)
+		bNumbers = append(bNumbers, n)
+	}
+
+	if c := slices.Compare(aNumbers, bNumbers); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// dockerLatest returns the highest version among the tags of an image on Docker Hub or another OCI registry, only
+// considering the tags matching the match regex if set or the tags which look like versions otherwise.
+func (specResolver) dockerLatest(spec VersionLookupSpec, lookupID string) (string, error) {
+	image, err := registryName(spec, lookupID)
+	if err != nil {
+		return "", err
+	}
+
+	regex := versionTagRegex
+	if spec.MatchRegex != "" {
+		regex, err = regexp.Compile(spec.MatchRegex)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	registry, repo := imageRepo(spec, image)
+	tags, err := ociTags(registry, repo)
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	for _, tag := range tags {
+		if regex.MatchString(tag) && (latest == "" || compareVersions(tag, latest) > 0) {
+			latest = tag
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no tags of image %s match %s", image, regex)
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,111 @@
+package entity
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/femnad/fup/settings"
+)
+
+func Test_registryStrategies(t *testing.T) {
+	var serverURL string
+	responses := map[string]string{
+		"/api/v1/crates/foo": `{"crate": {"max_stable_version": "1.2.0"},
+			"versions": [{"num": "1.2.0", "yanked": true}, {"num": "1.1.1-rc.1"}, {"num": "1.1.0"}]}`,
+		"/github.com/!foo/bar/@latest": `{"Version": "v0.3.0"}`,
+		"/@foo%2Fbar/latest":           `{"version": "4.0.0"}`,
+		"/token":                       `{"token": "secret"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/foo/bar/tags/list" {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.Header().Set("WWW-Authenticate",
+					fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:foo/bar:pull"`, serverURL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"tags": ["1.9.0", "1.10.0", "latest", "1.10.0-alpine", "2.0.0-rc1"]}`)
+			return
+		}
+
+		resp, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, resp)
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	tests := []struct {
+		name     string
+		spec     VersionLookupSpec
+		lookupID string
+		want     string
+	}{
+		{
+			name:     "Crate with yanked max stable version",
+			spec:     VersionLookupSpec{Strategy: cratesLatestVersion},
+			lookupID: "foo",
+			want:     "1.1.0",
+		},
+		{
+			name:     "Go package in a module",
+			spec:     VersionLookupSpec{Strategy: goLatestVersion},
+			lookupID: "Foo/bar/cmd/baz",
+			want:     "v0.3.0",
+		},
+		{
+			name:     "Scoped npm package",
+			spec:     VersionLookupSpec{Strategy: npmLatestVersion},
+			lookupID: "@foo/bar",
+			want:     "4.0.0",
+		},
+		{
+			name: "Image tags with a token",
+			spec: VersionLookupSpec{Repo: "foo/bar:latest", Strategy: dockerLatestTag},
+			want: "1.10.0",
+		},
+		{
+			name: "Image tags matching a regex",
+			spec: VersionLookupSpec{MatchRegex: "-alpine$", Repo: "foo/bar", Strategy: dockerLatestTag},
+			want: "1.10.0-alpine",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.spec.BaseURL = server.URL
+			got, err := queryFromStrategy(tt.spec, tt.lookupID, settings.Settings{})
+			if err != nil {
+				t.Fatalf("queryFromStrategy() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("queryFromStrategy() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_imageRepo(t *testing.T) {
+	tests := []struct {
+		image        string
+		wantRegistry string
+		wantRepo     string
+	}{
+		{image: "nginx", wantRegistry: defaultRegistryURL, wantRepo: "library/nginx"},
+		{image: "foo/bar:1.0", wantRegistry: defaultRegistryURL, wantRepo: "foo/bar"},
+		{image: "ghcr.io/foo/bar", wantRegistry: "https://ghcr.io", wantRepo: "foo/bar"},
+		{image: "localhost:5000/bar:1.0", wantRegistry: "https://localhost:5000", wantRepo: "bar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			registry, repo := imageRepo(VersionLookupSpec{}, tt.image)
+			if registry != tt.wantRegistry || repo != tt.wantRepo {
+				t.Errorf("imageRepo() = %v, %v, want %v, %v", registry, repo, tt.wantRegistry, tt.wantRepo)
+			}
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/femnad/fup/internal"
 	"github.com/femnad/fup/precheck/unless"
+	"github.com/femnad/fup/remote"
 	"github.com/femnad/fup/settings"
 )
 
@@ -20,6 +22,10 @@ type ExecuteSpec struct {
 }
 
 type Release struct {
+	Meta          `yaml:",inline"`
+	Integrity     `yaml:",inline"`
+	ArchURL       map[string]string `yaml:"arch_url,omitempty"`
+	AssetMatch    *AssetMatch       `yaml:"-"`
 	ChromeSandbox string            `yaml:"chrome-sandbox,omitempty"`
 	Cleanup       bool              `yaml:"cleanup,omitempty"`
 	DontLink      bool              `yaml:"dont_link,omitempty"`
@@ -28,6 +34,8 @@ type Release struct {
 	ExecuteBefore ExecuteSpec       `yaml:"execute_before,omitempty"`
 	NamedLink     []NamedLink       `yaml:"named_link,omitempty"`
 	Ref           string            `yaml:"name,omitempty"`
+	SignatureURL  string            `yaml:"signature_url,omitempty"`
+	SigningKey    string            `yaml:"signing_key,omitempty"`
 	Symlink       []string          `yaml:"link,omitempty"`
 	Target        string            `yaml:"target,omitempty"`
 	Unless        unless.Unless     `yaml:"unless,omitempty"`
@@ -46,11 +54,20 @@ func (r Release) GetVersionLookup() VersionLookupSpec {
 }
 
 func (r Release) GetLookupID() string {
+	if r.AssetMatch != nil {
+		return r.AssetMatch.Source.RepoURL()
+	}
+
 	return r.Url
 }
 
+// LockID returns the ID of the release in the version lock file.
+func (r Release) LockID() string {
+	return lockID("release", r.Name())
+}
+
 func (r Release) String() string {
-	return r.Url
+	return r.GetLookupID()
 }
 
 func (r Release) expand(property string) string {
@@ -70,10 +87,107 @@ func (r Release) ExpandURL(s settings.Settings) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if r.AssetMatch != nil {
+		return r.AssetMatch.URL(s, version)
+	}
 
 	return settings.ExpandStringWithLookup(s, r.Url, map[string]string{"version": version}), nil
 }
 
+// ForArch returns the release with its URL for the host architecture if it has one in arch_url, which takes precedence
+// over url.
+func (r Release) ForArch() (Release, error) {
+	if len(r.ArchURL) == 0 {
+		return r, nil
+	}
+
+	for arch, url := range r.ArchURL {
+		if internal.IsArch(arch) {
+			r.Url = url
+			return r, nil
+		}
+	}
+	if r.Url == "" {
+		return r, fmt.Errorf("no URL for architecture %s for release %s", internal.Arch(), r.Name())
+	}
+
+	return r, nil
+}
+
+// ExpandChecksum returns the SHA256 sum the release downloaded from the given URL should have, falling back to the one
+// in the version lock file if no checksum is declared.
+func (r Release) ExpandChecksum(s settings.Settings, releaseURL string) (string, error) {
+	if !r.HasChecksum() {
+		return s.Internal.LockedChecksums[releaseURL], nil
+	}
+
+	version, err := getVersion(r, s)
+	if err != nil {
+		return "", err
+	}
+
+	lookup := map[string]string{"version": version}
+	var verify func([]byte) error
+	if r.SignatureURL != "" {
+		verify = func(checksums []byte) error {
+			return r.verifyChecksums(s, lookup, checksums)
+		}
+	}
+
+	return r.expectedSHA256(s, releaseURL, lookup, verify)
+}
+
+// verifyChecksums checks the signature of the checksums file against the signing key.
+func (r Release) verifyChecksums(s settings.Settings, lookup map[string]string, checksums []byte) error {
+	key, err := readKey(settings.ExpandStringWithLookup(s, r.SigningKey, lookup))
+	if err != nil {
+		return fmt.Errorf("error reading signing key: %v", err)
+	}
+
+	signatureURL := settings.ExpandStringWithLookup(s, r.SignatureURL, lookup)
+	signature, err := remote.ReadResponseBytes(signatureURL)
+	if err != nil {
+		return fmt.Errorf("error fetching signature from %s: %v", signatureURL, err)
+	}
+
+	err = verifySignature(key, checksums, signature)
+	if err != nil {
+		return fmt.Errorf("bad signature %s: %v", signatureURL, err)
+	}
+
+	internal.Logger.Trace().Str("signature", signatureURL).Msg("Verified checksums signature")
+	return nil
+}
+
+func (r Release) validate() []fieldError {
+	errs := r.Integrity.validate()
+	for arch := range r.ArchURL {
+		if !internal.IsKnownArch(arch) {
+			errs = append(errs, fieldError{field: "arch_url", err: fmt.Errorf("unknown architecture %s", arch)})
+		}
+	}
+	if len(r.ArchURL) > 0 && r.Checksum != "" {
+		errs = append(errs, fieldError{field: "checksum",
+			err: fmt.Errorf("checksum can't be used with arch_url as it differs by architecture, use checksum_url")})
+	}
+	if r.SignatureURL == "" {
+		if r.SigningKey != "" {
+			errs = append(errs, fieldError{field: "signing_key", err: fmt.Errorf("signing_key requires signature_url")})
+		}
+		return errs
+	}
+
+	if r.ChecksumURL == "" {
+		errs = append(errs, fieldError{field: "signature_url",
+			err: fmt.Errorf("signature_url requires checksum_url, as the signature is of the checksums file")})
+	}
+	if r.SigningKey == "" {
+		errs = append(errs, fieldError{field: "signature_url", err: fmt.Errorf("signature_url requires signing_key")})
+	}
+
+	return errs
+}
+
 func (r Release) ExpandSymlinks(execCandidate string) []NamedLink {
 	var links []NamedLink
 	var expanded []NamedLink
@@ -111,6 +225,14 @@ func (r Release) LookupVersion(s settings.Settings) (string, error) {
 	return getVersion(r, s)
 }
 
+func (r Release) ConfiguredVersion(s settings.Settings) string {
+	return configuredVersion(r, s)
+}
+
+func (r Release) LatestVersion(s settings.Settings) (string, error) {
+	return latestVersion(r, s)
+}
+
 func (r Release) KeepUpToDate() bool {
 	return !r.DontUpdate
 }
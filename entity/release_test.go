@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/femnad/fup/internal"
+)
+
+func TestRelease_ForArch(t *testing.T) {
+	tests := []struct {
+		name    string
+		release Release
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "No arch URLs",
+			release: Release{Url: "https://example.com/foo.tar.gz"},
+			want:    "https://example.com/foo.tar.gz",
+		},
+		{
+			name: "Machine name",
+			release: Release{Url: "https://example.com/foo.tar.gz", ArchURL: map[string]string{
+				internal.Arch(): "https://example.com/foo-host.tar.gz",
+				"s390x":         "https://example.com/foo-s390x.tar.gz",
+			}},
+			want: "https://example.com/foo-host.tar.gz",
+		},
+		{
+			name:    "Go name",
+			release: Release{ArchURL: map[string]string{internal.GoArch(): "https://example.com/foo-host.tar.gz"}},
+			want:    "https://example.com/foo-host.tar.gz",
+		},
+		{
+			name: "Fall back to URL",
+			release: Release{Url: "https://example.com/foo.tar.gz", ArchURL: map[string]string{
+				"unknown": "https://example.com/foo-unknown.tar.gz",
+			}},
+			want: "https://example.com/foo.tar.gz",
+		},
+		{
+			name:    "No URL for architecture",
+			release: Release{Ref: "foo", ArchURL: map[string]string{"unknown": "https://example.com/foo.tar.gz"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.release.ForArch()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForArch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Url != tt.want {
+				t.Errorf("ForArch() got = %v, want %v", got.Url, tt.want)
+			}
+		})
+	}
+}
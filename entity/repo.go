@@ -1,6 +1,7 @@
 package entity
 
 type Repo struct {
+	Meta      `yaml:",inline"`
 	Branch    string            `yaml:"branch"`
 	Name      string            `yaml:"name"`
 	Path      string            `yaml:"path"`
@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"reflect"
+)
+
+const (
+	schemaDraft = "http://json-schema.org/draft-07/schema#"
+)
+
+// enumer is implemented by types with fields which only accept a fixed set of values.
+type enumer interface {
+	schemaEnums() map[string][]string
+}
+
+type schemaGenerator struct {
+	definitions map[string]any
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.definitions[name]; !ok {
+			// Reserve the name before generating the definition in case the type refers to itself.
+			g.definitions[name] = nil
+			g.definitions[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/definitions/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *schemaGenerator) addProperties(t reflect.Type, properties map[string]any) {
+	var enums map[string][]string
+	if e, ok := reflect.Zero(t).Interface().(enumer); ok {
+		enums = e.schemaEnums()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key, inline := fieldKey(field)
+		if inline {
+			g.addProperties(field.Type, properties)
+			continue
+		}
+		if key == "-" || field.Anonymous {
+			continue
+		}
+
+		property := g.typeSchema(field.Type)
+		if values, ok := enums[key]; ok {
+			property["enum"] = values
+		}
+		properties[key] = property
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	g.addProperties(t, properties)
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// ConfigSchema returns a JSON Schema for the config format.
+func ConfigSchema() map[string]any {
+	g := schemaGenerator{definitions: make(map[string]any)}
+	schema := g.structSchema(reflect.TypeOf(Config{}))
+	schema["$schema"] = schemaDraft
+	schema["title"] = "fup config"
+	schema["definitions"] = g.definitions
+
+	return schema
+}
@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ConfigSchema(t *testing.T) {
+	schema := ConfigSchema()
+	definitions := schema["definitions"].(map[string]any)
+
+	property := func(definition, key string) map[string]any {
+		def, ok := definitions[definition].(map[string]any)
+		if !ok {
+			t.Fatalf("ConfigSchema() missing definition %s", definition)
+		}
+		prop, ok := def["properties"].(map[string]any)[key].(map[string]any)
+		if !ok {
+			t.Fatalf("ConfigSchema() missing property %s of %s", key, definition)
+		}
+		return prop
+	}
+
+	tests := []struct {
+		name       string
+		definition string
+		key        string
+		want       map[string]any
+	}{
+		{
+			name:       "Inlined field",
+			definition: "GithubRelease",
+			key:        "depends_on",
+			want:       map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+		{
+			name:       "Nested inlined field",
+			definition: "GitlabRelease",
+			key:        "asset_exclude",
+			want:       map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+		{
+			name:       "Nested type",
+			definition: "Task",
+			key:        "steps",
+			want:       map[string]any{"type": "array", "items": map[string]any{"$ref": "#/definitions/Step"}},
+		},
+		{
+			name:       "Step names",
+			definition: "Step",
+			key:        "name",
+			want: map[string]any{"type": "string", "enum": []string{"cmd", "download", "file", "git", "pip", "rename",
+				"shell", "symlink"}},
+		},
+		{
+			name:       "Line modes",
+			definition: "LineInFile",
+			key:        "name",
+			want:       map[string]any{"type": "string", "enum": []string{"ensure", "replace"}},
+		},
+		{
+			name:       "Map",
+			definition: "Unit",
+			key:        "env",
+			want:       map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := property(tt.definition, tt.key)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConfigSchema() property = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := definitions["Template"].(map[string]any)["properties"].(map[string]any)["-"]; ok {
+		t.Errorf("ConfigSchema() includes ignored field")
+	}
+	if schema["properties"].(map[string]any)["settings"] == nil {
+		t.Errorf("ConfigSchema() missing settings")
+	}
+}
@@ -1,5 +1,10 @@
 package entity
 
+var (
+	// Unit types which can be given as a service kind.
+	serviceKinds = []string{"automount", "mount", "path", "service", "socket", "swap", "target", "timer"}
+)
+
 type Unit struct {
 	After       string            `yaml:"after"`
 	Before      string            `yaml:"before"`
@@ -18,6 +23,7 @@ type Timer struct {
 }
 
 type Service struct {
+	Meta         `yaml:",inline"`
 	DontEnable   bool   `yaml:"dont_enable"`
 	DontStart    bool   `yaml:"dont_start"`
 	DontTemplate bool   `yaml:"dont_template"`
@@ -34,3 +40,7 @@ type Service struct {
 func (s Service) RunWhen() string {
 	return s.When
 }
+
+func (Service) schemaEnums() map[string][]string {
+	return map[string][]string{"kind": serviceKinds}
+}
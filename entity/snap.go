@@ -1,6 +1,7 @@
 package entity
 
 type Snap struct {
+	Meta    `yaml:",inline"`
 	Absent  bool   `yaml:"absent"`
 	Name    string `yaml:"name"`
 	Classic bool   `yaml:"classic"`
@@ -10,7 +10,7 @@ import (
 	"github.com/femnad/fup/remote"
 )
 
-const (
+var (
 	apiBase = "https://api.github.com"
 )
 
@@ -105,6 +105,28 @@ func (s specResolver) gitHubFirstMatchingTag(spec VersionLookupSpec, releaseURL
 	return "", fmt.Errorf("error finding matching tag for spec %+v", spec)
 }
 
+func (s specResolver) forgeLatest(forge string, spec VersionLookupSpec, releaseURL string) (string, error) {
+	source, err := lookupSource(forge, spec, releaseURL)
+	if err != nil {
+		return "", err
+	}
+
+	release, err := source.release("", s.useGHClient)
+	if err != nil {
+		return "", err
+	}
+
+	return release.TagName, nil
+}
+
+func (s specResolver) gitlabLatest(spec VersionLookupSpec, releaseURL string) (string, error) {
+	return s.forgeLatest(forgeGitlab, spec, releaseURL)
+}
+
+func (s specResolver) giteaLatest(spec VersionLookupSpec, releaseURL string) (string, error) {
+	return s.forgeLatest(forgeGitea, spec, releaseURL)
+}
+
 func (s specResolver) pypiLatestVersion(spec VersionLookupSpec, pkgName string) (string, error) {
 	packageURL := fmt.Sprintf("https://pypi.org/project/%s/", pkgName)
 	lookupSpec := VersionLookupSpec{
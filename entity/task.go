@@ -2,7 +2,9 @@ package entity
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 
 	"github.com/femnad/fup/common"
 	"github.com/femnad/fup/internal"
@@ -76,8 +78,13 @@ func fileCmd(step Step, cfg Config) error {
 
 func download(step Step, cfg Config) error {
 	url, path := step.Url, ExpandSettings(cfg.Settings, step.Target)
+	sum, err := step.ExpectedSHA256(cfg.Settings, url, nil)
+	if err != nil {
+		return err
+	}
+
 	internal.Logger.Trace().Str("url", url).Str("path", path).Msg("Downloading")
-	return remote.Download(url, path)
+	return remote.Download(url, path, sum)
 }
 
 func pipInstall(step Step, cfg Config) error {
@@ -96,36 +103,44 @@ func pipInstall(step Step, cfg Config) error {
 func rename(step Step, cfg Config) error {
 	src := ExpandSettings(cfg.Settings, step.Src)
 	target := ExpandSettings(cfg.Settings, step.Target)
+	err := internal.Backup(target)
+	if err != nil {
+		return err
+	}
+
 	return os.Rename(src, target)
 }
 
+var (
+	stepFns = map[string]func(Step, Config) error{
+		"cmd":      runCmd,
+		"download": download,
+		"file":     fileCmd,
+		"git":      runGitClone,
+		"pip":      pipInstall,
+		"rename":   rename,
+		"shell":    runShellCmd,
+		"symlink":  createSymlink,
+	}
+)
+
 func getStepFunction(step Step) (func(Step, Config) error, error) {
-	switch step.StepName {
-	case "cmd":
-		return runCmd, nil
-	case "download":
-		return download, nil
-	case "file":
-		return fileCmd, nil
-	case "git":
-		return runGitClone, nil
-	case "pip":
-		return pipInstall, nil
-	case "rename":
-		return rename, nil
-	case "shell":
-		return runShellCmd, nil
-	case "symlink":
-		return createSymlink, nil
-	case "":
+	if step.StepName == "" {
 		return nil, fmt.Errorf("no operation defined for step: %s", step)
-	default:
+	}
+
+	fn, ok := stepFns[step.StepName]
+	if !ok {
 		return nil, fmt.Errorf("unable to determine an operation for step: %s", step)
 	}
+
+	return fn, nil
 }
 
 type Step struct {
 	unless.BasicUnlessable
+	// For download
+	Integrity `yaml:",inline"`
 	// For cmd and shell
 	Cmd string `yaml:"cmd"`
 	// For file
@@ -157,6 +172,22 @@ func (s Step) String() string {
 	return fmt.Sprintf("operation=%s, cmd=%s, sudo=%t", s.Name(), s.Cmd, s.Sudo)
 }
 
+func (s Step) validate() []fieldError {
+	_, err := getStepFunction(s)
+	if err == nil {
+		return nil
+	}
+
+	if s.StepName == "" {
+		return []fieldError{{err: fmt.Errorf("step has no name")}}
+	}
+	return []fieldError{{field: "name", err: fmt.Errorf("unknown step name %q", s.StepName)}}
+}
+
+func (Step) schemaEnums() map[string][]string {
+	return map[string][]string{"name": slices.Sorted(maps.Keys(stepFns))}
+}
+
 func (s Step) Run(cfg Config) error {
 	fn, err := getStepFunction(s)
 	if err != nil {
@@ -187,6 +218,7 @@ func (Step) DefaultVersionCmd() string {
 
 type Task struct {
 	unless.BasicUnlessable
+	Meta   `yaml:",inline"`
 	Desc   string        `yaml:"name"`
 	Hint   string        `yaml:"hint"`
 	Steps  []Step        `yaml:"steps"`
@@ -1,16 +1,26 @@
 package entity
 
+// ConfigOrigin identifies the config file a resource is declared in.
+type ConfigOrigin struct {
+	File        string
+	Remote      bool
+	TemplateDir string
+}
+
 type Template struct {
+	Meta       `yaml:",inline"`
 	Content    string            `yaml:"content"`
 	Context    map[string]string `yaml:"context"`
 	Dest       string            `yaml:"dest"`
 	ExpandUser bool              `yaml:"expand_user"`
 	Group      string            `yaml:"group"`
 	Mode       int               `yaml:"mode"`
-	RunAfter   []Step            `yaml:"run_after"`
-	Src        string            `yaml:"src"`
-	User       string            `yaml:"owner"`
-	When       string            `yaml:"when"`
+	// Set when reading the config, for resolving the source relative to the file declaring the template.
+	Origin   ConfigOrigin `yaml:"-"`
+	RunAfter []Step       `yaml:"run_after"`
+	Src      string       `yaml:"src"`
+	User     string       `yaml:"owner"`
+	When     string       `yaml:"when"`
 }
 
 func (t Template) RunWhen() string {
@@ -1,5 +1,6 @@
 package entity
 
 type UserKey struct {
+	Meta `yaml:",inline"`
 	User string
 }
@@ -1,6 +1,43 @@
 package entity
 
+import "github.com/femnad/fup/settings"
+
 type UvTool struct {
-	Name    string `yaml:"name"`
-	Version string `yaml:"version"`
+	Meta          `yaml:",inline"`
+	Tool          string            `yaml:"name"`
+	Version       string            `yaml:"version"`
+	VersionLookup VersionLookupSpec `yaml:"version_lookup"`
+}
+
+func (u UvTool) GetVersion() string {
+	return u.Version
+}
+
+func (u UvTool) GetVersionLookup() VersionLookupSpec {
+	return u.VersionLookup
+}
+
+func (u UvTool) GetLookupID() string {
+	return u.Name()
+}
+
+// LockID returns the ID of the uv tool in the version lock file.
+func (u UvTool) LockID() string {
+	return lockID("uv", u.Name())
+}
+
+func (u UvTool) LookupVersion(s settings.Settings) (string, error) {
+	return getVersion(u, s)
+}
+
+func (u UvTool) ConfiguredVersion(s settings.Settings) string {
+	return configuredVersion(u, s)
+}
+
+func (u UvTool) LatestVersion(s settings.Settings) (string, error) {
+	return latestVersion(u, s)
+}
+
+func (u UvTool) Name() string {
+	return u.Tool
 }
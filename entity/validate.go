@@ -0,0 +1,164 @@
+package entity
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/femnad/fup/precheck/when"
+)
+
+// ConfigError is an error at a position in a config file.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// fieldError is a semantic error for the field with the given YAML key, or for the whole item if the key is empty.
+type fieldError struct {
+	field string
+	err   error
+}
+
+type validator interface {
+	validate() []fieldError
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func fieldKey(field reflect.StructField) (key string, inline bool) {
+	tag := field.Tag.Get("yaml")
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(opts, "inline") {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+
+	return name, false
+}
+
+type configChecker struct {
+	file string
+	errs []ConfigError
+	seen map[ConfigError]bool
+}
+
+func (c *configChecker) add(node *yaml.Node, msg string) {
+	e := ConfigError{File: c.file, Line: node.Line, Column: node.Column, Msg: msg}
+	// Checks of inlined structs can be reached both directly and via promoted methods.
+	if c.seen[e] {
+		return
+	}
+	c.seen[e] = true
+	c.errs = append(c.errs, e)
+}
+
+func (c *configChecker) checkItem(v reflect.Value, node *yaml.Node) {
+	item := v.Interface()
+	if w, ok := item.(when.Whenable); ok && w.RunWhen() != "" {
+		if _, err := when.ParseStatement(w.RunWhen()); err != nil {
+			pos := mappingValue(node, "when")
+			if pos == nil {
+				pos = node
+			}
+			c.add(pos, fmt.Sprintf("invalid when statement %q: %v", w.RunWhen(), err))
+		}
+	}
+
+	val, ok := item.(validator)
+	if !ok {
+		return
+	}
+	for _, fe := range val.validate() {
+		pos := node
+		if fe.field != "" {
+			if fieldNode := mappingValue(node, fe.field); fieldNode != nil {
+				pos = fieldNode
+			}
+		}
+		c.add(pos, fe.err.Error())
+	}
+}
+
+func (c *configChecker) check(v reflect.Value, node *yaml.Node) {
+	if node == nil {
+		return
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		c.checkItem(v, node)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			key, inline := fieldKey(field)
+			if inline {
+				c.check(v.Field(i), node)
+			} else if key != "-" {
+				c.check(v.Field(i), mappingValue(node, key))
+			}
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i := 0; i < v.Len() && i < len(node.Content); i++ {
+			c.check(v.Index(i), node.Content[i])
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			c.check(v.Elem(), node)
+		}
+	}
+}
+
+// CheckConfig runs semantic checks on a config decoded from the given node, such as for unknown step names or
+// unparsable when statements.
+func CheckConfig(file string, node *yaml.Node, config Config) []ConfigError {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+
+	c := configChecker{file: file, seen: make(map[ConfigError]bool)}
+	c.check(reflect.ValueOf(config), node)
+	slices.SortStableFunc(c.errs, func(a, b ConfigError) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+
+	return c.errs
+}
@@ -0,0 +1,169 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"go.yaml.in/yaml/v4"
+)
+
+func Test_CheckConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "Valid config",
+			content: `
+task:
+  - name: foo
+    when: os "fedora"
+    steps:
+      - name: cmd
+        cmd: echo foo
+line:
+  - name: ensure
+    file: /tmp/foo
+release:
+  - url: https://example.com/foo.tar.gz
+    version_lookup:
+      strategy: github-latest
+`,
+		},
+		{
+			name: "Unknown step name",
+			content: `
+task:
+  - name: foo
+    steps:
+      - name: cmd
+        cmd: echo foo
+      - name: shel
+        cmd: echo bar
+      - cmd: echo baz
+`,
+			want: []string{
+				`fup.yml:7:15: unknown step name "shel"`,
+				`fup.yml:9:9: step has no name`,
+			},
+		},
+		{
+			name: "Unknown line mode and run after step",
+			content: `
+line:
+  - name: ensur
+    file: /tmp/foo
+    run_after:
+      - name: foo
+`,
+			want: []string{
+				`fup.yml:3:11: unknown line mode "ensur", expected ensure or replace`,
+				`fup.yml:6:15: unknown step name "foo"`,
+			},
+		},
+		{
+			name: "Unknown strategy",
+			content: `
+github-release:
+  - name: foo/bar
+    version_lookup:
+      strategy: github-latests
+`,
+			want: []string{`fup.yml:5:17: unknown version lookup strategy "github-latests"`},
+		},
+		{
+			name: "Invalid checksums",
+			content: `
+release:
+  - url: https://example.com/foo-${version}.tar.gz
+    checksum: sha256:${version}
+  - url: https://example.com/bar.tar.gz
+    checksum: md5:d41d8cd98f00b204e9800998ecf8427e
+archive:
+  - url: https://example.com/baz.tar.gz
+    checksum: sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+    checksum_url: https://example.com/SHA256SUMS
+`,
+			want: []string{
+				`fup.yml:6:15: checksum md5:d41d8cd98f00b204e9800998ecf8427e should be in the form sha256:<sum>`,
+				`fup.yml:10:19: checksum and checksum_url are mutually exclusive`,
+			},
+		},
+		{
+			name: "Incomplete signature",
+			content: `
+release:
+  - url: https://example.com/foo.tar.gz
+    signature_url: https://example.com/SHA256SUMS.asc
+`,
+			want: []string{
+				`fup.yml:4:20: signature_url requires checksum_url, as the signature is of the checksums file`,
+				`fup.yml:4:20: signature_url requires signing_key`,
+			},
+		},
+		{
+			name: "Invalid arch URLs",
+			content: `
+github-release:
+  - name: foo/bar
+    arch_url:
+      x86_64: bar-x86_64.tar.gz
+      sparc: bar-sparc.tar.gz
+    checksum: sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+`,
+			want: []string{
+				`fup.yml:5:7: unknown architecture sparc`,
+				`fup.yml:7:15: checksum can't be used with arch_url as it differs by architecture, use checksum_url`,
+			},
+		},
+		{
+			name: "Invalid assets",
+			content: `
+github-release:
+  - name: foo/bar
+    asset: bar-(.tar.gz
+    url: bar.tar.gz
+  - name: foo/baz
+    asset_exclude:
+      - musl
+`,
+			want: []string{
+				"fup.yml:4:12: asset can't be used with url or arch_url",
+				"fup.yml:4:12: invalid asset pattern: error parsing regexp: missing closing ): `bar-(.tar.gz`",
+				"fup.yml:6:5: asset_exclude and asset_include require asset",
+			},
+		},
+		{
+			name: "Invalid when",
+			content: `
+go:
+  - name: foo
+    when: is-laptop
+`,
+			want: []string{
+				`fup.yml:4:11: invalid when statement "is-laptop": template: when:1: bad character U+002D '-'`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.content), &root); err != nil {
+				t.Fatal(err)
+			}
+			var config Config
+			if err := root.Decode(&config); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, e := range CheckConfig("fup.yml", &root, config) {
+				got = append(got, e.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,23 +10,51 @@ type versioned interface {
 	GetVersion() string
 	GetVersionLookup() VersionLookupSpec
 	GetLookupID() string
+	LockID() string
 	Name() string
 }
 
+// lockID returns the ID of a resource in the version lock file, which is its resource ID without the sequence suffix of
+// resources sharing a name. Resources sharing a name can't be locked, as they would share the same lock entry.
+func lockID(kind, name string) string {
+	return kind + ":" + name
+}
+
 func hasVersionLookup(v versioned) bool {
 	spec := v.GetVersionLookup()
 	return spec.URL != "" || spec.Strategy != ""
 }
 
-func getVersion(v versioned, s settings.Settings) (string, error) {
+// configuredVersion returns the version set for v in the config, the settings or the version lock, without looking it
+// up.
+func configuredVersion(v versioned, s settings.Settings) string {
 	version := v.GetVersion()
 	if version != "" {
-		return version, nil
+		return version
+	}
+
+	version = s.Versions[v.Name()]
+	if version != "" {
+		return version
+	}
+
+	return s.Internal.LockedVersions[v.LockID()]
+}
+
+// latestVersion looks up the version of v regardless of the configured one, returning an empty string if v doesn't
+// have a version lookup.
+func latestVersion(v versioned, s settings.Settings) (string, error) {
+	if !hasVersionLookup(v) {
+		return "", nil
 	}
 
-	storedVersion := s.Versions[v.Name()]
-	if storedVersion != "" {
-		return storedVersion, nil
+	return LookupVersion(v.GetVersionLookup(), v.GetLookupID(), s)
+}
+
+func getVersion(v versioned, s settings.Settings) (string, error) {
+	version := configuredVersion(v, s)
+	if version != "" {
+		return version, nil
 	}
 
 	if hasVersionLookup(v) {
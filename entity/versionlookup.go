@@ -2,7 +2,9 @@ package entity
 
 import (
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/antchfx/htmlquery"
@@ -13,12 +15,20 @@ import (
 )
 
 const (
+	cratesLatestVersion = "crates-latest"
+	dockerLatestTag     = "docker-latest"
+	giteaLatestRelease  = "gitea-latest"
 	githubLatestRelease = "github-latest"
 	githubMatchingTag   = "github-tag"
+	gitlabLatestRelease = "gitlab-latest"
+	goLatestVersion     = "go-latest"
+	npmLatestVersion    = "npm-latest"
 	pypiLatestVersion   = "pypi-latest"
 )
 
 type VersionLookupSpec struct {
+	// Base URL of the GitLab or Gitea instance, or of the registry for the registry strategies such as crates-latest.
+	BaseURL       string   `yaml:"base_url"`
 	ExcludeSuffix []string `yaml:"exclude_suffix"`
 	FollowURL     bool     `yaml:"follow_url"`
 	GetRedirect   bool     `yaml:"get_redirect"`
@@ -30,12 +40,21 @@ type VersionLookupSpec struct {
 	MatchRegex    string   `yaml:"match_regex"`
 	PostProc      string   `yaml:"post_proc"`
 	Query         string   `yaml:"query"`
-	Strategy      string   `yaml:"strategy"`
-	URL           string   `yaml:"url"`
+	// GitLab project or Gitea repo, determined from the release URL if empty, or the package or image name for the
+	// registry strategies, which is the name of the resource if empty.
+	Repo     string `yaml:"repo"`
+	Strategy string `yaml:"strategy"`
+	URL      string `yaml:"url"`
 }
 
 func resolveQuery(spec VersionLookupSpec) (string, error) {
-	doc, err := htmlquery.LoadURL(spec.URL)
+	resp, err := remote.ReadResponseBody(spec.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	doc, err := htmlquery.Parse(resp.Body)
 	if err != nil {
 		return "", err
 	}
@@ -115,20 +134,44 @@ func getRepo(spec VersionLookupSpec, releaseURL string) (string, error) {
 	return repo, nil
 }
 
-func queryFromStrategy(spec VersionLookupSpec, assetURL string, s settings.Settings) (string, error) {
-	resolver := specResolver{useGHClient: s.Internal.GhAvailable}
-	strategies := map[string]func(VersionLookupSpec, string) (string, error){
-		githubLatestRelease: resolver.githubStable,
-		githubMatchingTag:   resolver.gitHubFirstMatchingTag,
-		pypiLatestVersion:   resolver.pypiLatestVersion,
+var (
+	strategies = map[string]func(specResolver, VersionLookupSpec, string) (string, error){
+		cratesLatestVersion: specResolver.cratesLatest,
+		dockerLatestTag:     specResolver.dockerLatest,
+		giteaLatestRelease:  specResolver.giteaLatest,
+		githubLatestRelease: specResolver.githubStable,
+		githubMatchingTag:   specResolver.gitHubFirstMatchingTag,
+		gitlabLatestRelease: specResolver.gitlabLatest,
+		goLatestVersion:     specResolver.goLatest,
+		npmLatestVersion:    specResolver.npmLatest,
+		pypiLatestVersion:   specResolver.pypiLatestVersion,
+	}
+)
+
+func (spec VersionLookupSpec) validate() []fieldError {
+	if spec.Strategy == "" {
+		return nil
 	}
 
+	if _, ok := strategies[spec.Strategy]; !ok {
+		return []fieldError{{field: "strategy", err: fmt.Errorf("unknown version lookup strategy %q", spec.Strategy)}}
+	}
+
+	return nil
+}
+
+func (VersionLookupSpec) schemaEnums() map[string][]string {
+	return map[string][]string{"strategy": slices.Sorted(maps.Keys(strategies))}
+}
+
+func queryFromStrategy(spec VersionLookupSpec, assetURL string, s settings.Settings) (string, error) {
+	resolver := specResolver{useGHClient: s.Internal.GhAvailable}
 	fn, ok := strategies[spec.Strategy]
 	if !ok {
 		return "", fmt.Errorf("no such strategy %s", spec.Strategy)
 	}
 
-	return fn(spec, assetURL)
+	return fn(resolver, spec, assetURL)
 }
 
 func versionFromSpec(spec VersionLookupSpec, assetURL string, s settings.Settings) (text string, err error) {
@@ -3,6 +3,7 @@ module github.com/femnad/fup
 go 1.25.0
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.6
 	github.com/alexflint/go-arg v1.6.0
 	github.com/antchfx/htmlquery v1.3.5
 	github.com/cli/go-gh/v2 v2.13.0
@@ -19,7 +20,6 @@ require (
 require (
 	dario.cat/mergo v1.0.1 // indirect
 	github.com/Microsoft/go-winio v0.6.2 // indirect
-	github.com/ProtonMail/go-crypto v1.1.6 // indirect
 	github.com/alexflint/go-scalar v1.2.0 // indirect
 	github.com/antchfx/xpath v1.3.5 // indirect
 	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"runtime"
+	"slices"
+)
+
+// archNames are the names of an architecture as used in release artifacts.
+type archNames struct {
+	// As reported by uname -m.
+	machine    string
+	goArch     string
+	rustTarget string
+}
+
+// Architectures by GOARCH, fup always runs natively so its own architecture is the host's.
+var archs = map[string]archNames{
+	"386":     {machine: "i686", goArch: "386", rustTarget: "i686-unknown-linux-gnu"},
+	"amd64":   {machine: "x86_64", goArch: "amd64", rustTarget: "x86_64-unknown-linux-gnu"},
+	"arm":     {machine: "armv7l", goArch: "arm", rustTarget: "armv7-unknown-linux-gnueabihf"},
+	"arm64":   {machine: "aarch64", goArch: "arm64", rustTarget: "aarch64-unknown-linux-gnu"},
+	"ppc64le": {machine: "ppc64le", goArch: "ppc64le", rustTarget: "powerpc64le-unknown-linux-gnu"},
+	"riscv64": {machine: "riscv64", goArch: "riscv64", rustTarget: "riscv64gc-unknown-linux-gnu"},
+	"s390x":   {machine: "s390x", goArch: "s390x", rustTarget: "s390x-unknown-linux-gnu"},
+}
+
+func hostArch() archNames {
+	names, ok := archs[runtime.GOARCH]
+	if !ok {
+		return archNames{machine: runtime.GOARCH, goArch: runtime.GOARCH}
+	}
+
+	return names
+}
+
+// Arch returns the machine name of the host architecture, such as x86_64 or aarch64.
+func Arch() string {
+	return hostArch().machine
+}
+
+// GoArch returns the Go name of the host architecture, such as amd64 or arm64.
+func GoArch() string {
+	return hostArch().goArch
+}
+
+// RustTarget returns the Rust target triple of the host architecture, such as x86_64-unknown-linux-gnu.
+func RustTarget() string {
+	return hostArch().rustTarget
+}
+
+// IsKnownArch returns true if the name is either the machine or the Go name of a supported architecture.
+func IsKnownArch(name string) bool {
+	for _, names := range archs {
+		if name == names.machine || name == names.goArch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsArch returns true if the name is either the machine or the Go name of the host architecture.
+func IsArch(name string) bool {
+	names := hostArch()
+	return slices.Contains([]string{names.machine, names.goArch}, name)
+}
+
+// OtherArchNames returns the machine and Go names of the supported architectures other than the host's.
+func OtherArchNames() []string {
+	host := hostArch()
+	var names []string
+	for _, arch := range archs {
+		if arch.goArch == host.goArch {
+			continue
+		}
+		names = append(names, arch.machine, arch.goArch)
+	}
+
+	slices.Sort(names)
+	return names
+}
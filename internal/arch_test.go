@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestIsArch(t *testing.T) {
+	tests := []struct {
+		name string
+		arch string
+		want bool
+	}{
+		{name: "Go name", arch: runtime.GOARCH, want: true},
+		{name: "Machine name", arch: Arch(), want: true},
+		{name: "Other architecture", arch: "sparc", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsArch(tt.arch); got != tt.want {
+				t.Errorf("IsArch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsKnownArch(t *testing.T) {
+	for _, arch := range []string{"x86_64", "amd64", "aarch64", "arm64"} {
+		if !IsKnownArch(arch) {
+			t.Errorf("IsKnownArch(%s) = false, want true", arch)
+		}
+	}
+	if IsKnownArch("sparc") {
+		t.Error("IsKnownArch(sparc) = true, want false")
+	}
+}
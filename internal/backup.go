@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	marecmd "github.com/femnad/mare/cmd"
+)
+
+const (
+	backupDirName = "backups"
+	// Backups can contain secrets so they are only readable by the current user.
+	backupDirMode  = 0o700
+	backupFileMode = 0o600
+	// Sorts chronologically and doesn't contain path separators.
+	backupTimeFormat = "20060102T150405.000000000Z"
+	defaultStateHome = "~/.local/state"
+	stateDirName     = "fup"
+	StateHomeEnv     = "XDG_STATE_HOME"
+)
+
+// BackupEntry is a saved version of a file.
+type BackupEntry struct {
+	Path string
+	Time time.Time
+}
+
+// StateDir returns the directory for the state fup keeps across runs.
+func StateDir() string {
+	stateHome := os.Getenv(StateHomeEnv)
+	if stateHome == "" {
+		stateHome = defaultStateHome
+	}
+
+	return path.Join(ExpandUser(stateHome), stateDirName)
+}
+
+func absPath(target string) (string, error) {
+	return filepath.Abs(ExpandUser(target))
+}
+
+// backupDir returns the directory holding the backups of the given file.
+func backupDir(target string) (string, error) {
+	abs, err := absPath(target)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(StateDir(), backupDirName, abs), nil
+}
+
+// backupAsRoot copies a file only root can read into the backup dir as root, keeping its mode and owner so that its
+// content doesn't become readable by the current user.
+func backupAsRoot(target, backup string) error {
+	Logger.Trace().Str("path", target).Str("backup", backup).Msg("Backing up file with elevated privileges")
+	cp := fmt.Sprintf("cp --preserve=mode,ownership %s %s", target, backup)
+	return marecmd.RunErrOnly(marecmd.Input{Command: cp, Sudo: true})
+}
+
+// Backup saves the current content of the file before it's overwritten, doing nothing if it doesn't exist or is a
+// directory.
+func Backup(target string) error {
+	info, statErr := os.Lstat(target)
+	if os.IsNotExist(statErr) {
+		return nil
+	} else if statErr != nil && !os.IsPermission(statErr) {
+		return statErr
+	}
+	if statErr == nil && info.IsDir() {
+		Logger.Debug().Str("path", target).Msg("Not backing up directory")
+		return nil
+	}
+
+	dir, err := backupDir(target)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, backupDirMode)
+	if err != nil {
+		return err
+	}
+
+	backup := path.Join(dir, time.Now().UTC().Format(backupTimeFormat))
+	content, err := os.ReadFile(target)
+	if os.IsPermission(statErr) || os.IsPermission(err) {
+		return backupAsRoot(target, backup)
+	} else if err != nil {
+		return fmt.Errorf("error reading %s for backup: %v", target, err)
+	}
+
+	Logger.Trace().Str("path", target).Str("backup", backup).Msg("Backing up file")
+	return os.WriteFile(backup, content, backupFileMode)
+}
+
+// Backups lists the saved versions of the file from oldest to newest.
+func Backups(target string) ([]BackupEntry, error) {
+	dir, err := backupDir(target)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var backups []BackupEntry
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		t, parseErr := time.Parse(backupTimeFormat, entry.Name())
+		if parseErr != nil {
+			Logger.Debug().Str("file", entry.Name()).Msg("Ignoring unknown file in backup directory")
+			continue
+		}
+		backups = append(backups, BackupEntry{Path: path.Join(dir, entry.Name()), Time: t})
+	}
+
+	slices.SortFunc(backups, func(a, b BackupEntry) int {
+		return a.Time.Compare(b.Time)
+	})
+	return backups, nil
+}
+
+// Restore writes back the latest saved version of the file taken at or before the given time, or the latest one if
+// the time is zero. The current content is backed up in turn so that the restore can be undone.
+func Restore(target string, at time.Time) (BackupEntry, error) {
+	backups, err := Backups(target)
+	if err != nil {
+		return BackupEntry{}, err
+	}
+
+	var found *BackupEntry
+	for i := range backups {
+		if !at.IsZero() && backups[i].Time.After(at) {
+			break
+		}
+		found = &backups[i]
+	}
+	if found == nil {
+		return BackupEntry{}, fmt.Errorf("no backups of %s found", target)
+	}
+
+	// Backups of files only root can read are owned by root.
+	content, err := readCurrentContent(found.Path)
+	if err != nil {
+		return *found, err
+	}
+
+	abs, err := absPath(target)
+	if err != nil {
+		return *found, err
+	}
+
+	mode := defaultFileMode
+	if fi, statErr := os.Stat(abs); statErr == nil {
+		mode = int(fi.Mode().Perm())
+	}
+
+	_, err = WriteContent(ManagedFile{Path: abs, Content: content, Mode: mode})
+	return *found, err
+}
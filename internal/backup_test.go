@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(StateHomeEnv, path.Join(home, "state"))
+
+	target := path.Join(home, "foo.conf")
+	if err := os.WriteFile(target, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range []string{"second\n", "third\n"} {
+		if _, err := WriteContent(ManagedFile{Path: target, Content: content, Mode: 0o600}); err != nil {
+			t.Fatalf("WriteContent() error = %v", err)
+		}
+	}
+
+	backups, err := Backups(target)
+	if err != nil {
+		t.Fatalf("Backups() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Backups() got %d backups, want 2", len(backups))
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{
+			name: "Latest",
+			want: "second\n",
+		},
+		{
+			name: "At time",
+			at:   backups[0].Time,
+			want: "first\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Restore(target, tt.at); err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+
+			got, err := os.ReadFile(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Restore() content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Restore(target, backups[0].Time.Add(-time.Second)); err == nil {
+		t.Errorf("Restore() expected error for time before the first backup")
+	}
+}
+
+func TestBackupSkipsDirectories(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(StateHomeEnv, path.Join(home, "state"))
+
+	target := path.Join(home, "dir")
+	if err := os.Mkdir(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Backup(target); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	backups, err := Backups(target)
+	if err != nil {
+		t.Fatalf("Backups() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("Backups() got %d backups of a directory, want 0", len(backups))
+	}
+}
@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	marecmd "github.com/femnad/mare/cmd"
+)
+
+const (
+	diffContext = 3
+	// Larger diffs are only reported as differing, as the line diff needs memory proportional to their product.
+	maxDiffCells    = 4_000_000
+	maskedValue     = "********"
+	noNewlineMarker = "\\ No newline at end of file"
+)
+
+var (
+	diffOpts DiffOptions
+	// Matches assignments to secret-looking keys, capturing everything up to the value.
+	secretRegex = regexp.MustCompile(
+		`(?i)^(.*(?:password|passwd|secret|token|api[_-]?key|private[_-]?key|credential)[^=:]*[=:]\s*).+$`)
+)
+
+// DiffOptions controls printing diffs of managed file changes.
+type DiffOptions struct {
+	// Print a unified diff for each managed file change.
+	Enabled bool
+	// Mask the values of secret-looking lines in diffs.
+	MaskSecrets bool
+}
+
+func SetDiffOptions(opts DiffOptions) {
+	diffOpts = opts
+}
+
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// splitLines splits the content into lines, keeping line terminators so that a missing final newline is a change.
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func lineOps(from, to []string) []diffOp {
+	// lcs[i][j] is the length of the longest common subsequence of from[i:] and to[j:].
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	var i, j int
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			ops = append(ops, diffOp{kind: ' ', line: from[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{kind: '-', line: from[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		ops = append(ops, diffOp{kind: '-', line: from[i]})
+	}
+	for ; j < len(to); j++ {
+		ops = append(ops, diffOp{kind: '+', line: to[j]})
+	}
+
+	return ops
+}
+
+func hunkRange(start, count int) string {
+	// An empty range refers to the line before it.
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func maskLine(line string) string {
+	if !diffOpts.MaskSecrets {
+		return line
+	}
+
+	trimmed := strings.TrimSuffix(line, "\n")
+	masked := secretRegex.ReplaceAllString(trimmed, "${1}"+maskedValue)
+	return masked + line[len(trimmed):]
+}
+
+func writeOp(b *strings.Builder, op diffOp) {
+	b.WriteByte(op.kind)
+	b.WriteString(maskLine(op.line))
+	if !strings.HasSuffix(op.line, "\n") {
+		b.WriteString("\n" + noNewlineMarker + "\n")
+	}
+}
+
+// UnifiedDiff returns a unified diff of the given contents, or an empty string if they are the same.
+func UnifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	fromLines, toLines := splitLines(from), splitLines(to)
+	header := fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName)
+	if len(fromLines)*len(toLines) > maxDiffCells {
+		return header + "Files are too large to diff\n"
+	}
+
+	ops := lineOps(fromLines, toLines)
+	var b strings.Builder
+	b.WriteString(header)
+
+	// Offsets of each op in the from and to lines.
+	fromOffsets := make([]int, len(ops)+1)
+	toOffsets := make([]int, len(ops)+1)
+	for k, op := range ops {
+		fromOffsets[k+1], toOffsets[k+1] = fromOffsets[k], toOffsets[k]
+		if op.kind != '+' {
+			fromOffsets[k+1]++
+		}
+		if op.kind != '-' {
+			toOffsets[k+1]++
+		}
+	}
+
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+
+		// Extend the hunk while changes are separated by no more than twice the context.
+		start := max(k-diffContext, 0)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = next
+		}
+
+		fromCount := fromOffsets[end] - fromOffsets[start]
+		toCount := toOffsets[end] - toOffsets[start]
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(fromOffsets[start], fromCount),
+			hunkRange(toOffsets[start], toCount))
+		for _, op := range ops[start:end] {
+			writeOp(&b, op)
+		}
+		k = end
+	}
+
+	return b.String()
+}
+
+// readCurrentContent reads a file, using sudo if it's not readable by the current user. Missing files are empty.
+func readCurrentContent(target string) (string, error) {
+	content, err := os.ReadFile(target)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if !os.IsPermission(err) {
+		return string(content), err
+	}
+
+	isRoot, err := IsUserRoot()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = getStatSum(target)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	out, err := marecmd.RunFmtErr(marecmd.Input{Command: fmt.Sprintf("cat %s", target), Sudo: !isRoot})
+	if err != nil {
+		return "", err
+	}
+
+	return out.Stdout, nil
+}
+
+// PrintDiff prints a unified diff between the current content of a managed file and its new content, if diffs are
+// enabled. Failing to read the current content only results in a warning as it doesn't affect the change itself.
+func PrintDiff(target, content string) {
+	if !diffOpts.Enabled {
+		return
+	}
+
+	current, err := readCurrentContent(target)
+	if err != nil {
+		Logger.Warn().Err(err).Str("path", target).Msg("Error reading file for diff")
+		return
+	}
+
+	fmt.Print(UnifiedDiff(target, target, current, content))
+}
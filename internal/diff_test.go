@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        string
+		to          string
+		maskSecrets bool
+		want        string
+	}{
+		{
+			name: "No changes",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		},
+		{
+			name: "New file",
+			from: "",
+			to:   "x\ny\n",
+			want: "--- foo\n+++ foo\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "Separate hunks and missing newline",
+			from: "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n",
+			to:   "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\nm\nn",
+			want: "--- foo\n+++ foo\n" +
+				"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+				"@@ -9,5 +9,6 @@\n i\n j\n k\n-l\n+L\n m\n+n\n\\ No newline at end of file\n",
+		},
+		{
+			name:        "Masked secrets",
+			from:        "user = foo\npassword = bar\n",
+			to:          "user = baz\npassword = bar\n",
+			maskSecrets: true,
+			want:        "--- foo\n+++ foo\n@@ -1,2 +1,2 @@\n-user = foo\n+user = baz\n password = ********\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetDiffOptions(DiffOptions{MaskSecrets: tt.maskSecrets})
+			defer SetDiffOptions(DiffOptions{})
+
+			if got := UnifiedDiff("foo", "foo", tt.from, tt.to); got != tt.want {
+				t.Errorf("UnifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
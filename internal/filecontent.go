@@ -197,6 +197,49 @@ func IsHomePath(path string) bool {
 	return strings.HasPrefix(path, home)
 }
 
+func contentChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func contentChanged(file ManagedFile) (bool, error) {
+	var dstSum string
+	target := ExpandUser(file.Path)
+
+	_, err := os.Stat(target)
+	if os.IsPermission(err) {
+		var ss statSum
+		ss, err = getStatSum(target)
+		if os.IsNotExist(err) {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+		dstSum = ss.sha256sum
+	} else if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	} else {
+		dstSum, err = checksum(target)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return dstSum != contentChecksum(file.Content), nil
+}
+
+// ContentChanged reports whether WriteContent would modify the managed file, without writing anything.
+func ContentChanged(file ManagedFile) (bool, error) {
+	changed, err := contentChanged(file)
+	if err == nil && changed {
+		PrintDiff(ExpandUser(file.Path), file.Content)
+	}
+
+	return changed, err
+}
+
 func WriteContent(file ManagedFile) (bool, error) {
 	var changed bool
 	var dstSum string
@@ -254,6 +297,7 @@ func WriteContent(file ManagedFile) (bool, error) {
 	if dstExists && srcSum == dstSum {
 		return false, nil
 	}
+	PrintDiff(target, file.Content)
 
 	if validateCmd != "" {
 		validateCmd = fmt.Sprintf("%s %s", validateCmd, srcPath)
@@ -263,6 +307,13 @@ func WriteContent(file ManagedFile) (bool, error) {
 		}
 	}
 
+	if dstExists {
+		err = Backup(target)
+		if err != nil {
+			return changed, err
+		}
+	}
+
 	dir, _ := path.Split(target)
 	err = EnsureDirExists(dir)
 	if err != nil {
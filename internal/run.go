@@ -72,8 +72,13 @@ func MaybeRunWithSudoForPath(cmdStr, targetPath string) error {
 }
 
 func Move(src, dst string, setOwner bool) error {
+	err := Backup(dst)
+	if err != nil {
+		return err
+	}
+
 	mv := fmt.Sprintf("mv %s %s", src, dst)
-	err := MaybeRunWithSudoForPath(mv, dst)
+	err = MaybeRunWithSudoForPath(mv, dst)
 	if err != nil {
 		return err
 	}
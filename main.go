@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/alexflint/go-arg"
 
@@ -13,18 +15,67 @@ import (
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
 	"github.com/femnad/fup/provision"
+	"github.com/femnad/fup/remote"
 )
 
 const (
-	version = "0.46.0"
+	// Exit code of status when resources have drifted, to tell drift apart from errors.
+	driftExitCode = 2
+	version       = "0.46.0"
 )
 
 type ApplyCmd struct {
 	Provisioners   []string `arg:"-p,--provisioners" help:"List of provisioners to run"`
+	Bundle         string   `arg:"--bundle" help:"Serve all downloads from a bundle created with bundle create instead of the network"`
+	Report         string   `arg:"--report" help:"Write a JSON report of the resource outcomes to this file"`
+	Plan           bool     `arg:"--plan" help:"Print the changes that would be made without applying them"`
+	Prune          bool     `arg:"--prune" help:"Remove artifacts of resources no longer declared in the config"`
+	Tags           []string `arg:"--tags" help:"Only run resources with any of these tags"`
+	SkipTags       []string `arg:"--skip-tags" help:"Don't run resources with any of these tags"`
+	Diff           bool     `arg:"--diff" help:"Print a unified diff for each managed file change"`
+	DiffMask       bool     `arg:"--diff-mask" help:"Mask the values of secret-looking lines in diffs"`
+	Wait           bool     `arg:"--wait" help:"Wait for another running apply to finish instead of failing"`
+	Update         bool     `arg:"--update" help:"Look up versions instead of using the lock file, and update the lock file"`
+	OnError        string   `arg:"--on-error" default:"continue" help:"What to do after a resource fails: continue or stop"`
 	PrintConfig    bool     `arg:"-r,--print-config" help:"Print final config and exit"`
 	ValidateConfig bool     `arg:"-c,--validate-config" help:"Validate config and exit"`
 }
 
+type BundleCreateCmd struct {
+	Output string `arg:"-o,--output,required" help:"Bundle file to write"`
+}
+
+type BundleCmd struct {
+	Create *BundleCreateCmd `arg:"subcommand:create" help:"Download all artifacts of a config into a bundle for offline use"`
+}
+
+type CacheLsCmd struct{}
+
+type CacheCleanCmd struct{}
+
+type CacheCmd struct {
+	Clean *CacheCleanCmd `arg:"subcommand:clean" help:"Remove all cached downloads"`
+	Ls    *CacheLsCmd    `arg:"subcommand:ls" help:"List cached downloads, most recently used first"`
+}
+
+type LockCmd struct{}
+
+type OutdatedCmd struct{}
+
+type RestoreCmd struct {
+	At   string `arg:"--at" help:"Restore the latest backup taken at or before this time, e.g. 2024-05-01T10:00:00 or 2024-05-01"`
+	List bool   `arg:"--list" help:"List backups of the file instead of restoring"`
+	Path string `arg:"positional,required" help:"File to restore"`
+}
+
+type SchemaCmd struct{}
+
+type StatusCmd struct {
+	Provisioners []string `arg:"-p,--provisioners" help:"List of provisioners to check"`
+	Tags         []string `arg:"--tags" help:"Only check resources with any of these tags"`
+	SkipTags     []string `arg:"--skip-tags" help:"Don't check resources with any of these tags"`
+}
+
 type VersionLookupCmd struct {
 	AssetURL    string `arg:"-a,--asset-url"`
 	FollowURL   bool   `arg:"-o,--follow-redirect" help:"Follow redirects"`
@@ -43,7 +94,14 @@ type VersionPrintSpecCmd struct {
 
 type args struct {
 	Apply            *ApplyCmd            `arg:"subcommand:apply" help:"Apply a configuration"`
+	Bundle           *BundleCmd           `arg:"subcommand:bundle" help:"Create bundles for applying configs without network access"`
+	Cache            *CacheCmd            `arg:"subcommand:cache" help:"Inspect or clean the download cache"`
+	Lock             *LockCmd             `arg:"subcommand:lock" help:"Look up versions and write them into a lock file next to the config"`
 	LogLevel         string               `arg:"-l,--loglevel" default:"debug" help:"Log level: trace, debug, info, warn, error, fatal, panic"`
+	Outdated         *OutdatedCmd         `arg:"subcommand:outdated" help:"Print the installed, configured and latest versions of releases and packages"`
+	Restore          *RestoreCmd          `arg:"subcommand:restore" help:"Restore a file overwritten by fup from a backup"`
+	Schema           *SchemaCmd           `arg:"subcommand:schema" help:"Print a JSON Schema for the config format"`
+	Status           *StatusCmd           `arg:"subcommand:status" help:"Print resources which differ from the config without changing anything, exiting with 2 if any"`
 	VersionPrintSpec *VersionPrintSpecCmd `arg:"subcommand:github-spec" help:"Print a GitHub release spec based on a URL"`
 	VersionLookup    *VersionLookupCmd    `arg:"subcommand:lookup" help:"Lookup a version based on a URL and query"`
 	File             string               `arg:"-f,--file,env:FUP_CONFIG" default:"~/.config/fup/fup.yml" help:"Config file path"`
@@ -53,45 +111,161 @@ func (args) Version() string {
 	return fmt.Sprintf("fup v%s", version)
 }
 
-func printConfig(configFile string) {
+func printConfig(configFile string) error {
 	out, err := base.FinalizeConfig(configFile)
 	if err != nil {
-		internal.Logger.Error().Str("file", configFile).Err(err).Msg("Error printing config")
-		os.Exit(1)
+		return fmt.Errorf("error printing config %s: %v", configFile, err)
 	}
 
 	fmt.Println(out)
-	os.Exit(0)
+	return nil
 }
 
-func apply(parsed args) {
+// useVersionLock returns the config using the versions in its lock file if there's one. With update, versions are
+// looked up again and written into the lock file if write is set.
+func useVersionLock(config entity.Config, update, write bool) (entity.Config, error) {
+	versionLock, err := provision.ReadVersionLock(config)
+	if err != nil || versionLock == nil {
+		return config, err
+	}
+
+	if update {
+		resolved, resolveErr := provision.ResolveVersionLock(config)
+		if resolveErr != nil {
+			return config, resolveErr
+		}
+		versionLock = &resolved
+
+		if write {
+			file := provision.VersionLockFile(config)
+			err = versionLock.Write(file)
+			if err != nil {
+				return config, fmt.Errorf("error writing lock file %s: %v", file, err)
+			}
+			internal.Logger.Info().Str("file", file).Msg("Updated lock file")
+		}
+	}
+
+	return provision.UseVersionLock(config, *versionLock), nil
+}
+
+// runApply applies the config, returning errors instead of exiting so that the bundle is closed before exiting.
+func runApply(parsed args) error {
 	applyCfg := parsed.Apply
-	internal.InitLogging(parsed.LogLevel)
 
 	cfg := parsed.File
 	internal.Logger.Trace().Str("path", cfg).Msg("Reading config file")
 
+	// The bundle is opened before reading the config, which can also be served from it.
+	var bundle *remote.Bundle
+	if applyCfg.Bundle != "" {
+		var err error
+		bundle, err = remote.OpenBundle(applyCfg.Bundle)
+		if err != nil {
+			return fmt.Errorf("error opening bundle: %v", err)
+		}
+		defer bundle.Close()
+	}
+
 	if applyCfg.PrintConfig {
-		printConfig(cfg)
+		return printConfig(cfg)
 	}
 
 	config, err := base.ReadConfig(cfg)
 	if err != nil {
-		log.Fatalf("%v\n", err)
+		return err
+	}
+	opts := provision.Options{
+		Provisioners: applyCfg.Provisioners,
+		Prune:        applyCfg.Prune,
+		Tags:         applyCfg.Tags,
+		SkipTags:     applyCfg.SkipTags,
+		OnError:      applyCfg.OnError,
+		Wait:         applyCfg.Wait,
 	}
 
 	if applyCfg.ValidateConfig {
-		os.Exit(0)
+		err = provision.ValidateConfig(config, opts)
+		if err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+		return nil
+	}
+
+	// Versions are looked up when planning with update, but the lock file is only updated when applying.
+	config, err = useVersionLock(config, applyCfg.Update, !applyCfg.Plan)
+	if err != nil {
+		return fmt.Errorf("error using lock file: %v", err)
+	}
+	if bundle != nil {
+		config = provision.UseBundleVersions(config, bundle)
 	}
 
-	p, err := provision.NewProvisioner(config, applyCfg.Provisioners)
+	internal.SetDiffOptions(internal.DiffOptions{Enabled: applyCfg.Diff, MaskSecrets: applyCfg.DiffMask})
+	p, err := provision.NewProvisioner(config, opts)
+	if err != nil {
+		return fmt.Errorf("error creating provisioner: %v", err)
+	}
+
+	if applyCfg.Plan {
+		err = p.Plan()
+		if err != nil {
+			return fmt.Errorf("error planning provisioner: %v", err)
+		}
+		return nil
+	}
+
+	report, err := p.Apply()
+	if applyCfg.Report != "" {
+		reportErr := report.Write(applyCfg.Report)
+		if reportErr != nil {
+			internal.Logger.Error().Err(reportErr).Str("file", applyCfg.Report).Msg("Error writing report")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error applying provisioner: %v", err)
+	}
+
+	return nil
+}
+
+func apply(parsed args) {
+	internal.InitLogging(parsed.LogLevel)
+
+	err := runApply(parsed)
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Msg("Error running apply")
+	}
+}
+
+func status(parsed args) {
+	statusCfg := parsed.Status
+	internal.InitLogging(parsed.LogLevel)
+
+	config, err := base.ReadConfig(parsed.File)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	config, err = useVersionLock(config, false, false)
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Msg("Error using lock file")
+	}
+
+	p, err := provision.NewProvisioner(config, provision.Options{
+		Provisioners: statusCfg.Provisioners,
+		Tags:         statusCfg.Tags,
+		SkipTags:     statusCfg.SkipTags,
+	})
 	if err != nil {
 		internal.Logger.Fatal().Err(err).Msg("Error creating provisioner")
 	}
 
-	err = p.Apply()
+	drifted, err := p.Status()
 	if err != nil {
-		internal.Logger.Fatal().Err(err).Msg("Error applying provisioner")
+		internal.Logger.Fatal().Err(err).Msg("Error checking status")
+	}
+	if drifted > 0 {
+		os.Exit(driftExitCode)
 	}
 }
 
@@ -119,6 +293,150 @@ func lookup(parsed args) {
 	fmt.Println(out)
 }
 
+func createBundle(parsed args, p *arg.Parser) {
+	bundleCfg := parsed.Bundle
+	internal.InitLogging(parsed.LogLevel)
+	if bundleCfg.Create == nil {
+		p.Fail("Missing bundle subcommand")
+	}
+
+	// Recording starts before reading the config so that remote configs are included.
+	recorder, err := remote.NewBundleRecorder()
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Msg("Error starting bundle")
+	}
+
+	config, err := base.ReadConfig(parsed.File)
+	if err == nil {
+		config, err = useVersionLock(config, false, false)
+	}
+	if err == nil {
+		err = provision.WriteBundle(config, recorder, bundleCfg.Create.Output)
+	}
+
+	// Recorded downloads can be large, so they are removed before exiting on errors.
+	closeErr := recorder.Close()
+	if closeErr != nil {
+		internal.Logger.Warn().Err(closeErr).Msg("Error removing recorded downloads")
+	}
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Msg("Error creating bundle")
+	}
+}
+
+func writeVersionLock(parsed args) {
+	internal.InitLogging(parsed.LogLevel)
+	config, err := base.ReadConfig(parsed.File)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	versionLock, err := provision.ResolveVersionLock(config)
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Msg("Error looking up versions")
+	}
+
+	file := provision.VersionLockFile(config)
+	err = versionLock.Write(file)
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Str("file", file).Msg("Error writing lock file")
+	}
+	internal.Logger.Info().Str("file", file).Int("resources", len(versionLock.Resources)).Msg("Wrote lock file")
+}
+
+func outdated(parsed args) {
+	internal.InitLogging(parsed.LogLevel)
+	config, err := base.ReadConfig(parsed.File)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	config, err = useVersionLock(config, false, false)
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Msg("Error using lock file")
+	}
+
+	err = provision.WriteOutdated(os.Stdout, config)
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Msg("Error checking versions")
+	}
+}
+
+func cache(parsed args, p *arg.Parser) {
+	cacheCfg := parsed.Cache
+	internal.InitLogging(parsed.LogLevel)
+
+	switch {
+	case cacheCfg.Clean != nil:
+		err := remote.CleanCache()
+		if err != nil {
+			internal.Logger.Fatal().Err(err).Str("dir", remote.CacheDir()).Msg("Error cleaning download cache")
+		}
+		internal.Logger.Info().Str("dir", remote.CacheDir()).Msg("Cleaned download cache")
+	case cacheCfg.Ls != nil:
+		entries, err := remote.CacheEntries()
+		if err != nil {
+			internal.Logger.Fatal().Err(err).Str("dir", remote.CacheDir()).Msg("Error listing download cache")
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s %12d %s\n", entry.UsedAt.Local().Format(time.RFC3339), entry.Size, entry.URL)
+		}
+	default:
+		p.Fail("Missing cache subcommand")
+	}
+}
+
+func parseRestoreTime(at string) (time.Time, error) {
+	if at == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04",
+		time.DateOnly} {
+		t, err := time.ParseInLocation(layout, at, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse time %s", at)
+}
+
+func restore(parsed args) {
+	restoreCfg := parsed.Restore
+	internal.InitLogging(parsed.LogLevel)
+
+	if restoreCfg.List {
+		backups, err := internal.Backups(restoreCfg.Path)
+		if err != nil {
+			internal.Logger.Fatal().Err(err).Str("path", restoreCfg.Path).Msg("Error listing backups")
+		}
+		for _, backup := range backups {
+			fmt.Printf("%s %s\n", backup.Time.Local().Format(time.RFC3339), backup.Path)
+		}
+		return
+	}
+
+	at, err := parseRestoreTime(restoreCfg.At)
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Msg("Invalid restore time")
+	}
+
+	backup, err := internal.Restore(restoreCfg.Path, at)
+	if err != nil {
+		internal.Logger.Fatal().Err(err).Str("path", restoreCfg.Path).Msg("Error restoring file")
+	}
+	internal.Logger.Info().Str("path", restoreCfg.Path).Time("backup", backup.Time.Local()).Msg("Restored file")
+}
+
+func printSchema() {
+	out, err := json.MarshalIndent(entity.ConfigSchema(), "", "  ")
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	fmt.Println(string(out))
+}
+
 func printSpec(parsed args) {
 	input := printspec.Input{
 		Input: basecmd.Input{LogLevel: parsed.LogLevel},
@@ -135,6 +453,20 @@ func main() {
 	switch {
 	case parsed.Apply != nil:
 		apply(parsed)
+	case parsed.Bundle != nil:
+		createBundle(parsed, p)
+	case parsed.Cache != nil:
+		cache(parsed, p)
+	case parsed.Lock != nil:
+		writeVersionLock(parsed)
+	case parsed.Outdated != nil:
+		outdated(parsed)
+	case parsed.Restore != nil:
+		restore(parsed)
+	case parsed.Schema != nil:
+		printSchema()
+	case parsed.Status != nil:
+		status(parsed)
 	case parsed.VersionLookup != nil:
 		lookup(parsed)
 	case parsed.VersionPrintSpec != nil:
@@ -86,7 +86,11 @@ func (Apt) RemoteInstall(pkgs []entity.RemotePackage) error {
 		url := pkg.Url
 		_, file := path.Split(url)
 		target := path.Join(tmpDir, file)
-		err = remote.Download(url, target)
+		sum, sumErr := PkgSHA256(pkg)
+		if sumErr != nil {
+			return sumErr
+		}
+		err = remote.Download(url, target, sum)
 		if err != nil {
 			return err
 		}
@@ -2,10 +2,13 @@ package packages
 
 import (
 	"fmt"
+	"os"
+	"path"
 	"strings"
 
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/remote"
 )
 
 type Dnf struct {
@@ -43,8 +46,29 @@ func (Dnf) RemoteInstall(pkgs []entity.RemotePackage) error {
 	var regularUrls []string
 	var skipScriptUrls []string
 
+	tmpDir, err := os.MkdirTemp("/tmp", "fup-remote-pkg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
 	for _, pkg := range pkgs {
 		url := pkg.Url
+		sum, err := PkgSHA256(pkg)
+		if err != nil {
+			return err
+		}
+		// Packages with checksums are verified before installing, and packages from bundles aren't available to dnf, so
+		// dnf can't download them by itself.
+		if sum != "" || remote.UsingBundle() {
+			target := path.Join(tmpDir, remote.FileName(url))
+			err = remote.Download(url, target, sum)
+			if err != nil {
+				return err
+			}
+			url = target
+		}
+
 		if pkg.SkipScripts {
 			skipScriptUrls = append(skipScriptUrls, url)
 		} else {
@@ -10,7 +10,9 @@ import (
 	"github.com/femnad/fup/common"
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/remote"
 	"github.com/femnad/fup/settings"
+	"github.com/femnad/mare"
 	marecmd "github.com/femnad/mare/cmd"
 )
 
@@ -56,21 +58,41 @@ func (i Installer) maybeRunWithSudo(cmds ...string) error {
 	return err
 }
 
-func (i Installer) Install(desired mapset.Set[string]) error {
+// Missing returns the sorted list of desired packages which are not installed.
+func (i Installer) Missing(desired mapset.Set[string]) []string {
 	missing := desired.Difference(i.Installed)
 	missingPkgs := setToSlice(missing)
+	sort.Strings(missingPkgs)
+	return missingPkgs
+}
+
+// Present returns the sorted list of undesired packages which are installed.
+func (i Installer) Present(undesired mapset.Set[string]) []string {
+	present := i.Installed.Intersect(undesired)
+	presentPkgs := setToSlice(present)
+	sort.Strings(presentPkgs)
+	return presentPkgs
+}
+
+func (i Installer) Install(desired mapset.Set[string]) error {
+	missingPkgs := i.Missing(desired)
 
 	if len(missingPkgs) == 0 {
 		return nil
 	}
 
-	sort.Strings(missingPkgs)
 	internal.Logger.Debug().Strs("packages", missingPkgs).Msg("Installing")
 
 	installCmd := []string{i.Pkg.PkgExec(), "install", "-y"}
 	installCmd = append(installCmd, i.Pkg.PkgInstallArgs()...)
 	installCmd = append(installCmd, missingPkgs...)
-	return i.maybeRunWithSudo(installCmd...)
+	err := i.maybeRunWithSudo(installCmd...)
+	if err != nil {
+		return err
+	}
+
+	i.Installed.Append(missingPkgs...)
+	return nil
 }
 
 func (i Installer) Version(pkg string) (string, error) {
@@ -95,6 +117,15 @@ func (i Installer) Version(pkg string) (string, error) {
 	return "", err
 }
 
+// PkgSHA256 returns the SHA256 sum of a package returned by ExpandRemote, or an empty string if it has no checksum.
+func PkgSHA256(pkg entity.RemotePackage) (string, error) {
+	if pkg.Checksum == "" {
+		return "", nil
+	}
+
+	return remote.ParseChecksum(pkg.Checksum)
+}
+
 func desiredPkgVersion(pkg entity.RemotePackage, s settings.Settings) string {
 	version := pkg.Version
 	if version == "" {
@@ -104,7 +135,27 @@ func desiredPkgVersion(pkg entity.RemotePackage, s settings.Settings) string {
 	return version
 }
 
-func (i Installer) RemoteInstall(desired mapset.Set[entity.RemotePackage], s settings.Settings) error {
+// ExpandRemote returns the remote package with its URL expanded and its checksum resolved to a SHA256 sum.
+func ExpandRemote(pkg entity.RemotePackage, s settings.Settings) (entity.RemotePackage, error) {
+	lookup := map[string]string{"version": desiredPkgVersion(pkg, s)}
+	pkg.Url = settings.ExpandStringWithLookup(s, pkg.Url, lookup)
+	if !pkg.HasChecksum() {
+		return pkg, nil
+	}
+
+	sum, err := pkg.ExpectedSHA256(s, pkg.Url, lookup)
+	if err != nil {
+		return pkg, fmt.Errorf("error getting checksum for package %s: %v", pkg.Name, err)
+	}
+	pkg.Integrity = entity.Integrity{Checksum: remote.SHA256Checksum(sum)}
+
+	return pkg, nil
+}
+
+// MissingRemote returns the remote packages which are not installed or don't have the desired version, with their
+// URLs expanded and their checksums resolved to SHA256 sums.
+func (i Installer) MissingRemote(desired mapset.Set[entity.RemotePackage], s settings.Settings) (
+	[]entity.RemotePackage, error) {
 	missing := mapset.NewSet[entity.RemotePackage]()
 
 	var existingVersion string
@@ -131,26 +182,38 @@ func (i Installer) RemoteInstall(desired mapset.Set[entity.RemotePackage], s set
 		return false
 	})
 
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []entity.RemotePackage
+	for _, pkg := range missing.ToSlice() {
+		pkg, err = ExpandRemote(pkg, s)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	sort.Slice(pkgs, func(a, b int) bool {
+		return pkgs[a].Url < pkgs[b].Url
+	})
+	return pkgs, nil
+}
+
+func (i Installer) RemoteInstall(desired mapset.Set[entity.RemotePackage], s settings.Settings) error {
+	pkgs, err := i.MissingRemote(desired, s)
 	if err != nil {
 		return err
 	}
 
-	if missing.Equal(mapset.NewSet[entity.RemotePackage]()) {
+	if len(pkgs) == 0 {
 		return nil
 	}
 
-	var urls []string
-	var pkgs []entity.RemotePackage
-	missing.Each(func(pkg entity.RemotePackage) bool {
-		version := desiredPkgVersion(pkg, s)
-		url := settings.ExpandStringWithLookup(s, pkg.Url, map[string]string{"version": version})
-		pkg.Url = url
-		urls = append(urls, url)
-		pkgs = append(pkgs, pkg)
-		return false
+	urls := mare.Map(pkgs, func(pkg entity.RemotePackage) string {
+		return pkg.Url
 	})
-
-	sort.Strings(urls)
 	internal.Logger.Debug().Strs("packages", urls).Msg("Installing remote")
 
 	return i.Pkg.RemoteInstall(pkgs)
@@ -188,14 +251,12 @@ func (i Installer) InstalledPackages(pkg PkgManager) (mapset.Set[string], error)
 }
 
 func (i Installer) Remove(undesired mapset.Set[string]) error {
-	toRemove := i.Installed.Intersect(undesired)
-	pkgToRemove := setToSlice(toRemove)
+	pkgToRemove := i.Present(undesired)
 
 	if len(pkgToRemove) == 0 {
 		return nil
 	}
 
-	sort.Strings(pkgToRemove)
 	internal.Logger.Debug().Strs("packages", pkgToRemove).Msg("Removing")
 
 	removeCmd := []string{i.Pkg.PkgExec()}
@@ -203,5 +264,11 @@ func (i Installer) Remove(undesired mapset.Set[string]) error {
 	removeCmd = append(removeCmd, "-y")
 	removeCmd = append(removeCmd, pkgToRemove...)
 
-	return i.maybeRunWithSudo(removeCmd...)
+	err := i.maybeRunWithSudo(removeCmd...)
+	if err != nil {
+		return err
+	}
+
+	i.Installed.RemoveAll(pkgToRemove...)
+	return nil
 }
@@ -61,6 +61,14 @@ func sshReady() (bool, error) {
 	return hasOutput("ssh-add -l")
 }
 
+func isArch(arch string) (bool, error) {
+	if !internal.IsKnownArch(arch) {
+		return false, fmt.Errorf("unknown architecture: %s", arch)
+	}
+
+	return internal.IsArch(arch), nil
+}
+
 func hasEnv(env string) (bool, error) {
 	val := os.Getenv(env)
 	return val != "", nil
@@ -182,6 +190,7 @@ func statOk(path string) (bool, error) {
 }
 
 var FactFns = template.FuncMap{
+	"arch":      isArch,
 	"env":       hasEnv,
 	"hostname":  hostname,
 	"is":        isA,
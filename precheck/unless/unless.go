@@ -65,18 +65,24 @@ func postProcOutput(unless Unless, output string) (string, error) {
 	return doPostProcOutput(unless, postProc)
 }
 
-func shouldSkip(unlessable Unlessable, s settings.Settings) bool {
-	var err error
-	var out marecmd.Output
+func versionCmd(unlessable Unlessable) marecmd.Input {
 	unless := unlessable.GetUnless()
 	unlessCmd := unless.Cmd
-
 	if unlessCmd == "" {
 		unlessCmd = unlessable.DefaultVersionCmd()
 	}
 
-	pwd := internal.ExpandUser(unless.Pwd)
-	out, err = run.Cmd(s, marecmd.Input{Command: unlessCmd, Pwd: pwd, Shell: unless.Shell})
+	return marecmd.Input{Command: unlessCmd, Pwd: internal.ExpandUser(unless.Pwd), Shell: unless.Shell}
+}
+
+func shouldSkip(unlessable Unlessable, s settings.Settings) bool {
+	var err error
+	var out marecmd.Output
+	unless := unlessable.GetUnless()
+	input := versionCmd(unlessable)
+	unlessCmd := input.Command
+
+	out, err = run.Cmd(s, input)
 
 	if unless.ExitCode != 0 {
 		internal.Logger.Trace().Str("cmd", unlessCmd).Int("actual", out.Code).Int("expected",
@@ -131,6 +137,23 @@ func shouldSkip(unlessable Unlessable, s settings.Settings) bool {
 	return true
 }
 
+// InstalledVersion runs the version command of the unlessable and returns its post processed output, or an empty string
+// if the unlessable is checked with a stat or an exit code instead of a version.
+func InstalledVersion(unlessable Unlessable, s settings.Settings) (string, error) {
+	unless := unlessable.GetUnless()
+	input := versionCmd(unlessable)
+	if unless.Stat != "" || unless.ExitCode != 0 || input.Command == "" {
+		return "", nil
+	}
+
+	out, err := run.Cmd(s, input)
+	if err != nil {
+		return "", fmt.Errorf("error running %s: %v", input.Command, err)
+	}
+
+	return postProcOutput(unless, out.Stdout)
+}
+
 func resolveStat(stat string, unlessable Unlessable, s settings.Settings) string {
 	lookup := map[string]string{}
 	version, err := unlessable.LookupVersion(s)
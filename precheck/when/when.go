@@ -14,13 +14,18 @@ type Whenable interface {
 	RunWhen() string
 }
 
+// ParseStatement parses a when statement without evaluating it.
+func ParseStatement(statement string) (*template.Template, error) {
+	tmpl := template.New("when").Funcs(precheck.FactFns)
+	return tmpl.Parse(fmt.Sprintf("{{%s}}", statement))
+}
+
 func EvalStatement(statement string) (bool, error) {
 	if statement == "" {
 		return true, nil
 	}
 
-	tmpl := template.New("when").Funcs(precheck.FactFns)
-	parsed, err := tmpl.Parse(fmt.Sprintf("{{%s}}", statement))
+	parsed, err := ParseStatement(statement)
 	if err != nil {
 		return false, err
 	}
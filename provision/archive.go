@@ -14,6 +14,7 @@ import (
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
 	"github.com/femnad/fup/remote"
+	"github.com/femnad/fup/settings"
 )
 
 var (
@@ -121,34 +122,63 @@ func shouldSkip(archive entity.Archive) (bool, error) {
 	return true, nil
 }
 
-func extractArchive(archive entity.Archive) error {
+func extractArchive(archive entity.Archive, s settings.Settings) (bool, error) {
 	skip, err := shouldSkip(archive)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	archiveURL := archive.URL
 	if skip {
 		internal.Logger.Trace().Str("url", archiveURL).Msg("Skipping archive")
-		return nil
+		return false, nil
 	}
 
 	internal.Logger.Debug().Str("url", archiveURL).Msg("Extracting archive")
 
-	response, err := remote.ReadResponseBody(archiveURL)
+	sum, err := archive.ExpectedSHA256(s, archiveURL, nil)
+	if err != nil {
+		return false, err
+	}
+
+	cached, err := remote.CachedDownload(archiveURL, sum)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.Open(cached.Path)
 	if err != nil {
-		return err
+		return false, err
+	}
+	defer f.Close()
+
+	response := remote.Response{Body: f, ContentDisposition: cached.ContentDisposition, URL: archiveURL}
+	return true, extract(response, archive)
+}
+
+func planArchive(archive entity.Archive) ([]string, error) {
+	skip, err := shouldSkip(archive)
+	if err != nil || skip {
+		return nil, err
 	}
 
-	return extract(response, archive)
+	return []string{fmt.Sprintf("extract %s into %s", archive.URL, archive.Target)}, nil
 }
 
-func extractArchives(config entity.Config) error {
-	var errs []error
+func archiveResources(config entity.Config) []resource {
+	var resources []resource
 	for _, archive := range config.Archives {
-		err := extractArchive(archive)
-		errs = append(errs, err)
+		resources = append(resources, resource{
+			name: archive.URL,
+			meta: archive.Meta,
+			apply: func() (bool, error) {
+				return extractArchive(archive, config.Settings)
+			},
+			plan: func() ([]string, error) {
+				return planArchive(archive)
+			},
+		})
 	}
 
-	return errors.Join(errs...)
+	return resources
 }
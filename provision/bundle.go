@@ -0,0 +1,188 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/packages"
+	"github.com/femnad/fup/remote"
+	"github.com/femnad/fup/settings"
+)
+
+const (
+	downloadStep = "download"
+)
+
+// bundleFetch downloads an artifact for a bundle.
+type bundleFetch struct {
+	name string
+	fn   func() error
+}
+
+func bundleDownload(url, sum string) error {
+	_, err := remote.CachedDownload(url, sum)
+	return err
+}
+
+func releaseFetches(config entity.Config, s settings.Settings, versions map[string]string) ([]bundleFetch, error) {
+	releases, err := configReleases(config)
+	var fetches []bundleFetch
+	for _, release := range releases {
+		fetches = append(fetches, bundleFetch{name: release.Name(), fn: func() error {
+			locked, lockErr := lockRelease(release, s)
+			if lockErr != nil {
+				return lockErr
+			}
+			if locked.Version != "" {
+				versions[release.Name()] = locked.Version
+			}
+			return nil
+		}})
+	}
+
+	return fetches, err
+}
+
+func archiveFetches(config entity.Config) []bundleFetch {
+	var fetches []bundleFetch
+	for _, archive := range config.Archives {
+		fetches = append(fetches, bundleFetch{name: archive.URL, fn: func() error {
+			sum, err := archive.ExpectedSHA256(config.Settings, archive.URL, nil)
+			if err != nil {
+				return err
+			}
+			return bundleDownload(archive.URL, sum)
+		}})
+	}
+
+	return fetches
+}
+
+func remotePackageFetches(config entity.Config) []bundleFetch {
+	var fetches []bundleFetch
+	for _, group := range config.RemotePackages {
+		for _, pkg := range group.Pkgs {
+			fetches = append(fetches, bundleFetch{name: pkg.Name, fn: func() error {
+				expanded, err := packages.ExpandRemote(pkg, config.Settings)
+				if err != nil {
+					return err
+				}
+				sum, err := packages.PkgSHA256(expanded)
+				if err != nil {
+					return err
+				}
+				return bundleDownload(expanded.Url, sum)
+			}})
+		}
+	}
+
+	return fetches
+}
+
+func repoKeyFetches(config entity.Config) []bundleFetch {
+	var urls []string
+	for _, repo := range config.AptRepos {
+		urls = append(urls, repo.KeyURLs()...)
+	}
+	for _, repo := range config.DnfRepos {
+		urls = append(urls, repo.KeyURLs()...)
+	}
+
+	var fetches []bundleFetch
+	for _, url := range urls {
+		fetches = append(fetches, bundleFetch{name: url, fn: func() error {
+			_, err := remote.ReadResponseBytes(url)
+			return err
+		}})
+	}
+
+	return fetches
+}
+
+func templateFetches(config entity.Config) []bundleFetch {
+	var fetches []bundleFetch
+	for _, tmpl := range config.Templates {
+		if tmpl.Content != "" || !templateOrigin(config, tmpl).Remote {
+			continue
+		}
+		fetches = append(fetches, bundleFetch{name: tmpl.Dest, fn: func() error {
+			_, err := getTemplateContent(config, tmpl)
+			return err
+		}})
+	}
+
+	return fetches
+}
+
+func downloadStepFetches(config entity.Config) []bundleFetch {
+	var fetches []bundleFetch
+	for _, task := range slices.Concat(config.PreflightTasks, config.Tasks, config.PostflightTasks) {
+		for _, step := range task.Steps {
+			if step.StepName != downloadStep {
+				continue
+			}
+			fetches = append(fetches, bundleFetch{name: step.Url, fn: func() error {
+				sum, err := step.ExpectedSHA256(config.Settings, step.Url, nil)
+				if err != nil {
+					return err
+				}
+				return bundleDownload(step.Url, sum)
+			}})
+		}
+	}
+
+	return fetches
+}
+
+// WriteBundle downloads all artifacts of the config while the recorder is active, regardless of when conditions and
+// tags, and writes them into a bundle file along with the resolved versions.
+func WriteBundle(config entity.Config, recorder *remote.BundleRecorder, output string) error {
+	s := config.Settings
+	s.Internal.GhAvailable = ghCliAvailable(s)
+	// Resolved versions are stored as locked versions, which are copied to keep the config's lock unchanged.
+	s.Internal.LockedVersions = maps.Clone(s.Internal.LockedVersions)
+	if s.Internal.LockedVersions == nil {
+		s.Internal.LockedVersions = make(map[string]string)
+	}
+
+	versions := make(map[string]string)
+	fetches, err := releaseFetches(config, s, versions)
+	fetches = slices.Concat(fetches, archiveFetches(config), remotePackageFetches(config), repoKeyFetches(config),
+		templateFetches(config), downloadStepFetches(config))
+
+	for _, fetch := range fetches {
+		internal.Logger.Debug().Str("name", fetch.name).Msg("Adding to bundle")
+		fetchErr := fetch.fn()
+		if fetchErr != nil {
+			err = errors.Join(err, fmt.Errorf("error adding %s to bundle: %v", fetch.name, fetchErr))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	err = recorder.Write(output, versions)
+	if err != nil {
+		return fmt.Errorf("error writing bundle %s: %v", output, err)
+	}
+
+	internal.Logger.Info().Str("file", output).Int("artifacts", len(fetches)).Msg("Wrote bundle")
+	return nil
+}
+
+// UseBundleVersions returns the config with versions resolved when creating the bundle for resources which don't
+// have a version set in the config, so that they don't need to be looked up.
+func UseBundleVersions(config entity.Config, bundle *remote.Bundle) entity.Config {
+	versions := maps.Clone(bundle.Versions)
+	if versions == nil {
+		versions = make(map[string]string)
+	}
+	maps.Copy(versions, config.Settings.Versions)
+	config.Settings.Versions = versions
+
+	return config
+}
@@ -0,0 +1,48 @@
+package provision
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/remote"
+)
+
+func Test_WriteBundle(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("release"))
+	}))
+	defer server.Close()
+
+	// Without a lock file, the versions resolved while creating the bundle have nowhere to be stored but the bundle.
+	config := entity.Config{
+		Filename: path.Join(t.TempDir(), "fup.yml"),
+		Releases: []entity.Release{{Ref: "foo", Url: server.URL + "/${version}/foo.tar.gz", Version: "1.0.0"}},
+	}
+
+	recorder, err := remote.NewBundleRecorder()
+	if err != nil {
+		t.Fatalf("NewBundleRecorder() error = %v", err)
+	}
+	output := path.Join(t.TempDir(), "bundle.tar")
+	err = WriteBundle(config, recorder, output)
+	if closeErr := recorder.Close(); closeErr != nil {
+		t.Fatalf("Close() error = %v", closeErr)
+	}
+	if err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+
+	bundle, err := remote.OpenBundle(output)
+	if err != nil {
+		t.Fatalf("OpenBundle() error = %v", err)
+	}
+	defer bundle.Close()
+	if got := bundle.Versions["foo"]; got != "1.0.0" {
+		t.Errorf("WriteBundle() version = %q, want %q", got, "1.0.0")
+	}
+}
@@ -1,7 +1,6 @@
 package provision
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 
@@ -10,8 +9,6 @@ import (
 
 	"github.com/femnad/fup/common"
 	"github.com/femnad/fup/entity"
-	"github.com/femnad/fup/precheck/unless"
-	"github.com/femnad/fup/precheck/when"
 	"github.com/femnad/fup/run"
 	"github.com/femnad/fup/settings"
 )
@@ -20,10 +17,19 @@ const (
 	defaultProtocol = "https://"
 )
 
-func crateArgs(pkg entity.CargoPkg) ([]string, error) {
+// crateArgs returns the cargo install arguments for the crate, with the looked up version for crates from the
+// registry, as versions of crates from Git repos are determined by their branch or tag.
+func crateArgs(pkg entity.CargoPkg, s settings.Settings) ([]string, error) {
 	name := pkg.Name()
 	if !strings.Contains(name, "/") {
-		return []string{name}, nil
+		version, err := pkg.LookupVersion(s)
+		if err != nil {
+			return nil, err
+		}
+		if version == "" {
+			return []string{name}, nil
+		}
+		return []string{name, "--version", version}, nil
 	}
 	if !strings.HasPrefix(name, defaultProtocol) {
 		name = fmt.Sprintf("%s%s/%s", defaultProtocol, defaultHost, name)
@@ -56,22 +62,12 @@ func crateArgs(pkg entity.CargoPkg) ([]string, error) {
 }
 
 func cargoInstall(pkg entity.CargoPkg, s settings.Settings) error {
-	if unless.ShouldSkip(pkg, s) {
-		internal.Logger.Trace().Str("crate", pkg.Crate).Msg("Skipping Cargo install")
-		return nil
-	}
-
-	if !when.ShouldRun(pkg) {
-		internal.Logger.Trace().Str("crate", pkg.Crate).Str("when", pkg.When).Msg("Skipping Cargo install")
-		return nil
-	}
-
 	name := pkg.Crate
 	internal.Logger.Debug().Str("crate", pkg.Crate).Msg("Installing Cargo crate")
 
 	installCmd := []string{"cargo", "install"}
 
-	crate, err := crateArgs(pkg)
+	crate, err := crateArgs(pkg, s)
 	if err != nil {
 		internal.Logger.Error().Err(err).Str("crate", name).Msg("Error getting create name")
 		return err
@@ -88,12 +84,88 @@ func cargoInstall(pkg entity.CargoPkg, s settings.Settings) error {
 	return nil
 }
 
-func cargoInstallPkgs(cfg entity.Config) error {
-	var cargoErr []error
+// parseCrateList returns the versions of the installed crates from the output of cargo install --list, which lists
+// crates as "ripgrep v14.1.0:" followed by their indented binaries.
+func parseCrateList(out string) map[string]string {
+	crates := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" || strings.HasPrefix(line, " ") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimSuffix(line, ":"))
+		if len(fields) < 2 {
+			continue
+		}
+		crates[fields[0]] = strings.TrimPrefix(fields[1], "v")
+	}
+
+	return crates
+}
+
+// checkCargoPkg reports if the crates of the package aren't installed or if a crate from the registry has a version
+// other than the desired one.
+func checkCargoPkg(pkg entity.CargoPkg, s settings.Settings) ([]string, error) {
+	out, err := run.Cmd(s, marecmd.Input{Command: "cargo install --list"})
+	if err != nil {
+		return nil, err
+	}
+	installed := parseCrateList(out.Stdout)
+
+	name := pkg.Name()
+	if strings.Contains(name, "/") {
+		crates := pkg.Binaries
+		if len(crates) == 0 {
+			crate, nameErr := common.NameFromRepo(name)
+			if nameErr != nil {
+				return nil, fmt.Errorf("error getting repo name for %s: %v", name, nameErr)
+			}
+			crates = []string{crate}
+		}
+
+		var drift []string
+		for _, crate := range crates {
+			if _, ok := installed[crate]; !ok {
+				drift = append(drift, fmt.Sprintf("crate %s not installed", crate))
+			}
+		}
+		return drift, nil
+	}
+
+	version, ok := installed[name]
+	if !ok {
+		return []string{fmt.Sprintf("crate %s not installed", name)}, nil
+	}
+	desired, err := pkg.LookupVersion(s)
+	if err != nil || desired == "" || versionMatches(version, desired) {
+		return nil, err
+	}
+
+	return []string{fmt.Sprintf("installed version %s, want %s", version, desired)}, nil
+}
+
+func cargoResources(cfg entity.Config) []resource {
+	var resources []resource
 	for _, pkg := range cfg.Cargo {
-		err := cargoInstall(pkg, cfg.Settings)
-		cargoErr = append(cargoErr, err)
+		resources = append(resources, resource{
+			name:   pkg.Name(),
+			meta:   pkg.Meta,
+			when:   pkg,
+			unless: pkg,
+			apply: func() (bool, error) {
+				return true, cargoInstall(pkg, cfg.Settings)
+			},
+			plan: func() ([]string, error) {
+				crate, err := crateArgs(pkg, cfg.Settings)
+				if err != nil {
+					return nil, err
+				}
+				return []string{fmt.Sprintf("cargo install %s", strings.Join(crate, " "))}, nil
+			},
+			check: func(resourceState) ([]string, error) {
+				return checkCargoPkg(pkg, cfg.Settings)
+			},
+		})
 	}
 
-	return errors.Join(cargoErr...)
+	return resources
 }
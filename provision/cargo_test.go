@@ -0,0 +1,20 @@
+package provision
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseCrateList(t *testing.T) {
+	out := `fd-find v10.2.0:
+    fd
+ripgrep v14.1.0:
+    rg
+zellij v0.41.0 (https://github.com/zellij-org/zellij#abcdef):
+    zellij
+`
+	want := map[string]string{"fd-find": "10.2.0", "ripgrep": "14.1.0", "zellij": "0.41.0"}
+	if got := parseCrateList(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCrateList() = %v, want %v", got, want)
+	}
+}
@@ -1,8 +1,8 @@
 package provision
 
 import (
-	"errors"
 	"fmt"
+	"os"
 
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
@@ -30,38 +30,61 @@ func ensureDirExist(dir string) error {
 	return nil
 }
 
-func doEnsureDirs(dirs []string, absent bool) []error {
-	var errs []error
-
-	for _, dir := range dirs {
-		var err error
-		if absent {
-			err = ensureDirAbsent(dir)
-		} else {
-			err = ensureDirExist(dir)
-		}
-		errs = append(errs, err)
+func dirExists(dir string) (bool, error) {
+	_, err := os.Stat(internal.ExpandUser(dir))
+	if err == nil {
+		return true, nil
+	} else if os.IsNotExist(err) {
+		return false, nil
 	}
 
-	return errs
+	return false, err
 }
 
-func ensureGroups(groups []entity.DirGroup) error {
-	var groupErrs []error
-	for _, group := range groups {
-		errs := doEnsureDirs(group.Names, group.Absent)
-		groupErrs = append(groupErrs, errs...)
+func ensureDir(dir string, absent bool) (bool, error) {
+	exists, err := dirExists(dir)
+	if err != nil {
+		return false, err
 	}
 
-	return errors.Join(groupErrs...)
+	if absent {
+		return exists, ensureDirAbsent(dir)
+	}
+
+	return !exists, ensureDirExist(dir)
 }
 
-func ensureDirs(config entity.Config) error {
-	err := ensureGroups(config.Dirs)
+func planDir(dir string, absent bool) ([]string, error) {
+	exists, err := dirExists(dir)
 	if err != nil {
-		internal.Logger.Error().Err(err).Msg("Error ensuring dirs")
-		return err
+		return nil, err
 	}
 
-	return nil
+	if absent && exists {
+		return []string{"remove"}, nil
+	} else if !absent && !exists {
+		return []string{"create"}, nil
+	}
+
+	return nil, nil
+}
+
+func dirResources(config entity.Config) []resource {
+	var resources []resource
+	for _, group := range config.Dirs {
+		for _, dir := range group.Names {
+			resources = append(resources, resource{
+				name: dir,
+				meta: group.Meta,
+				apply: func() (bool, error) {
+					return ensureDir(dir, group.Absent)
+				},
+				plan: func() ([]string, error) {
+					return planDir(dir, group.Absent)
+				},
+			})
+		}
+	}
+
+	return resources
 }
@@ -13,13 +13,12 @@ import (
 
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
-	"github.com/femnad/fup/precheck/when"
 )
 
 var (
 	ensureFns = map[string]func(string, *os.File, entity.LineInFile) (ensureResult, error){
-		"ensure":  ensure,
-		"replace": replace,
+		entity.LineEnsure:  ensure,
+		entity.LineReplace: replace,
 	}
 )
 
@@ -196,49 +195,57 @@ func replace(file string, tmpFile *os.File, line entity.LineInFile) (result ensu
 	return ensureResult{changed: changed}, nil
 }
 
-func ensureLine(config entity.Config, line entity.LineInFile) error {
+func ensureTmpFile(line entity.LineInFile) (string, ensureResult, error) {
+	var result ensureResult
 	target := internal.ExpandUser(line.File)
-	if !when.ShouldRun(line) {
-		whenText := internal.PrettyLogStr(line.When)
-		internal.Logger.Trace().Str("target", target).Str("when", whenText).Str("file", line.File).Msg(
-			"Skipping line")
-		return nil
-	}
-
-	tmpFile, err := os.CreateTemp(tmpDir, "fup")
-	if err != nil {
-		return err
-	}
 
 	ensureFn, ok := ensureFns[line.Name]
 	if !ok {
-		return fmt.Errorf("no method for %s'ing a line", line.Name)
+		return "", result, fmt.Errorf("no method for %s'ing a line", line.Name)
 	}
 
-	result, err := ensureFn(target, tmpFile, line)
+	tmpFile, err := os.CreateTemp(tmpDir, "fup")
 	if err != nil {
-		return err
+		return "", result, err
 	}
+	defer tmpFile.Close()
 
 	tmpPath := tmpFile.Name()
+	result, err = ensureFn(target, tmpFile, line)
+	if err != nil {
+		return tmpPath, result, err
+	}
+
 	if !result.changed {
 		internal.Logger.Trace().Str("target", target).Msg("Not modifying file as no changes were found")
-		err = os.Remove(tmpPath)
-		if err != nil {
-			return err
-		}
-		return nil
+		return tmpPath, result, os.Remove(tmpPath)
+	}
+
+	content, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return tmpPath, result, err
+	}
+	internal.PrintDiff(target, string(content))
+
+	return tmpPath, result, nil
+}
+
+func ensureLine(config entity.Config, line entity.LineInFile) (bool, error) {
+	target := internal.ExpandUser(line.File)
+	tmpPath, result, err := ensureTmpFile(line)
+	if err != nil || !result.changed {
+		return false, err
 	}
 
 	targetDir, _ := path.Split(target)
 	err = ensureDirExist(targetDir)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	err = internal.Move(tmpPath, target, result.new)
 	if err != nil {
-		return fmt.Errorf("error renaming %s to %s: %v", tmpPath, target, err)
+		return false, fmt.Errorf("error renaming %s to %s: %v", tmpPath, target, err)
 	}
 
 	for _, runStep := range line.RunAfter {
@@ -247,21 +254,47 @@ func ensureLine(config entity.Config, line entity.LineInFile) error {
 			"Executing cmd for step")
 		err = runStep.Run(config)
 		if err != nil {
-			return err
+			return true, err
 		}
 	}
 
-	return nil
+	return true, nil
 }
 
-func ensureLines(config entity.Config) error {
+func planLine(line entity.LineInFile) ([]string, error) {
+	tmpPath, result, err := ensureTmpFile(line)
+	if err != nil || !result.changed {
+		return nil, err
+	}
+
+	err = os.Remove(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []string{fmt.Sprintf("%s lines in %s", line.Name, line.File)}
+	for _, step := range line.RunAfter {
+		changes = append(changes, "run step "+step.String())
+	}
+
+	return changes, nil
+}
+
+func lineResources(config entity.Config) []resource {
+	var resources []resource
 	for _, line := range config.EnsureLines {
-		err := ensureLine(config, line)
-		if err != nil {
-			internal.Logger.Error().Err(err).Str("file", line.File).Msg("Error ensuring line in file")
-			return err
-		}
+		resources = append(resources, resource{
+			name: line.File,
+			meta: line.Meta,
+			when: line,
+			apply: func() (bool, error) {
+				return ensureLine(config, line)
+			},
+			plan: func() ([]string, error) {
+				return planLine(line)
+			},
+		})
 	}
 
-	return nil
+	return resources
 }
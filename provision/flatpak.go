@@ -1,7 +1,6 @@
 package provision
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -78,7 +77,7 @@ func isInstalled(pkg entity.FlatpakPkg) (bool, error) {
 }
 
 func ensureInstalled(pkg entity.FlatpakPkg) error {
-	out, _ := marecmd.Run(marecmd.Input{Command: fmt.Sprintf("%s info %s", pkg, pkg.Name)})
+	out, _ := marecmd.Run(marecmd.Input{Command: fmt.Sprintf("%s info %s", flatpakExec, pkg.Name)})
 	if out.Code == 0 {
 		return nil
 	}
@@ -93,61 +92,110 @@ func ensureInstalled(pkg entity.FlatpakPkg) error {
 	return nil
 }
 
-func ensureLauncher(stg settings.Settings, flatpak entity.FlatpakPkg) error {
+func getLauncherPath(stg settings.Settings, flatpak entity.FlatpakPkg) string {
+	homeBin := internal.ExpandUser(stg.BinDir)
+	return path.Join(homeBin, flatpak.Launcher)
+}
+
+func needsLauncher(stg settings.Settings, flatpak entity.FlatpakPkg) (bool, error) {
 	if flatpak.Launcher == "" {
-		return nil
+		return false, nil
 	}
 
-	homeBin := internal.ExpandUser(stg.BinDir)
-	launcherPath := path.Join(homeBin, flatpak.Launcher)
-
-	_, err := os.Stat(launcherPath)
+	_, err := os.Stat(getLauncherPath(stg, flatpak))
 	if err == nil {
-		return nil
+		return false, nil
 	} else if !os.IsNotExist(err) {
-		return err
+		return false, err
+	}
+
+	return true, nil
+}
+
+func ensureLauncher(stg settings.Settings, flatpak entity.FlatpakPkg) (bool, error) {
+	needed, err := needsLauncher(stg, flatpak)
+	if err != nil || !needed {
+		return false, err
 	}
 
 	launcherContent := fmt.Sprintf(launcherScript, flatpak.Name)
-	return os.WriteFile(launcherPath, []byte(launcherContent), 0755)
+	return true, os.WriteFile(getLauncherPath(stg, flatpak), []byte(launcherContent), 0755)
 }
 
-func installFlatpak(stg settings.Settings, pkg entity.FlatpakPkg, remotes []entity.FlatpakRemote) error {
+func installFlatpak(stg settings.Settings, pkg entity.FlatpakPkg, remotes []entity.FlatpakRemote) (bool, error) {
 	installed, err := isInstalled(pkg)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if !installed {
 		err = ensurePkgRemote(pkg, remotes)
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		err = ensureInstalled(pkg)
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 
-	return ensureLauncher(stg, pkg)
+	launcherCreated, err := ensureLauncher(stg, pkg)
+	return !installed || launcherCreated, err
+}
+
+func planFlatpak(stg settings.Settings, pkg entity.FlatpakPkg) ([]string, error) {
+	var changes []string
+	installed, err := isInstalled(pkg)
+	if err != nil {
+		return nil, err
+	}
+	if !installed {
+		changes = append(changes, "install")
+	}
+
+	needed, err := needsLauncher(stg, pkg)
+	if err != nil {
+		return nil, err
+	}
+	if needed {
+		changes = append(changes, fmt.Sprintf("create launcher %s", getLauncherPath(stg, pkg)))
+	}
+
+	return changes, nil
 }
 
-func flatpakInstall(config entity.Config) error {
+// Flatpak availability is checked when applying as it can be installed by a preceding resource.
+func flatpakAvailable() bool {
 	_, err := common.Which(flatpakExec)
 	if err != nil {
 		internal.Logger.Warn().Msg("Flatpak not installed, skipping installation")
-		return nil
+		return false
 	}
 
-	var flatpakErr []error
+	return true
+}
+
+func flatpakResources(config entity.Config) []resource {
+	var resources []resource
 	for _, pkg := range config.Flatpak.Packages {
-		err = installFlatpak(config.Settings, pkg, config.Flatpak.Remotes)
-		if err != nil {
-			internal.Logger.Error().Err(err).Str("package", pkg.Name).Msg("Error installing Flatpak")
-		}
-		flatpakErr = append(flatpakErr, err)
+		resources = append(resources, resource{
+			name: pkg.Name,
+			meta: pkg.Meta,
+			apply: func() (bool, error) {
+				if !flatpakAvailable() {
+					return false, nil
+				}
+				return installFlatpak(config.Settings, pkg, config.Flatpak.Remotes)
+			},
+			plan: func() ([]string, error) {
+				if !flatpakAvailable() {
+					return nil, nil
+				}
+				return planFlatpak(config.Settings, pkg)
+			},
+		})
 	}
 
-	return errors.Join(flatpakErr...)
+	return resources
 }
@@ -1,15 +1,16 @@
 package provision
 
 import (
-	"errors"
 	"fmt"
+	"os"
+	"path"
+	"regexp"
 	"strings"
 
 	"github.com/femnad/fup/internal"
 	marecmd "github.com/femnad/mare/cmd"
 
 	"github.com/femnad/fup/entity"
-	precheck "github.com/femnad/fup/precheck/unless"
 	"github.com/femnad/fup/run"
 	"github.com/femnad/fup/settings"
 )
@@ -19,6 +20,8 @@ const (
 	defaultVersion = "latest"
 )
 
+var majorVersionRegex = regexp.MustCompile("^v[0-9]+$")
+
 func qualifyPkg(pkg entity.GoPkg, s settings.Settings) (string, error) {
 	name := pkg.Name()
 	tokens := strings.Split(name, "/")
@@ -45,12 +48,6 @@ func qualifyPkg(pkg entity.GoPkg, s settings.Settings) (string, error) {
 
 func goInstall(pkg entity.GoPkg, s settings.Settings) error {
 	name := pkg.Name()
-
-	if precheck.ShouldSkip(pkg, s) {
-		internal.Logger.Trace().Str("name", name).Msg("Skipping Go package")
-		return nil
-	}
-
 	internal.Logger.Debug().Str("name", name).Msg("Installing Go package")
 
 	qualifiedName, err := qualifyPkg(pkg, s)
@@ -70,12 +67,117 @@ func goInstall(pkg entity.GoPkg, s settings.Settings) error {
 	return nil
 }
 
-func goInstallPkgs(cfg entity.Config) error {
-	var goErrs []error
+// getGoBinPath returns the path of the binary installed by go install for the given package.
+func getGoBinPath(pkg entity.GoPkg, s settings.Settings) (string, error) {
+	name, _, _ := strings.Cut(pkg.Name(), "@")
+	tokens := strings.Split(name, "/")
+	binName := tokens[len(tokens)-1]
+	if majorVersionRegex.MatchString(binName) && len(tokens) > 1 {
+		binName = tokens[len(tokens)-2]
+	}
+
+	out, err := run.Cmd(s, marecmd.Input{Command: "go env GOBIN GOPATH"})
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.Stdout), "\n")
+	if len(lines) == 0 {
+		return "", fmt.Errorf("unable to determine Go binary path for %s", name)
+	}
+
+	binDir := strings.TrimSpace(lines[0])
+	if binDir == "" {
+		if len(lines) < 2 {
+			return "", fmt.Errorf("unable to determine Go binary path for %s", name)
+		}
+		// GOPATH can contain multiple entries, go install uses the first one.
+		goPath, _, _ := strings.Cut(strings.TrimSpace(lines[1]), ":")
+		binDir = path.Join(goPath, "bin")
+	}
+
+	return path.Join(binDir, binName), nil
+}
+
+func goArtifacts(pkg entity.GoPkg, s settings.Settings) []artifact {
+	binPath, err := getGoBinPath(pkg, s)
+	if err != nil {
+		internal.Logger.Warn().Err(err).Str("name", pkg.Name()).Msg("Unable to determine Go binary path")
+		return nil
+	}
+
+	return []artifact{{Type: artifactFile, Path: binPath}}
+}
+
+// parseModVersion returns the version of the main module from the build info printed by go version -m.
+func parseModVersion(buildInfo string) (string, error) {
+	for _, line := range strings.Split(buildInfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "mod" {
+			return fields[2], nil
+		}
+	}
+
+	return "", fmt.Errorf("no main module in build info")
+}
+
+// checkGoPkg reports if the binary of the package is missing or has been built from a version other than the desired
+// one. Packages installed with the latest version are only checked for their binary.
+func checkGoPkg(pkg entity.GoPkg, s settings.Settings) ([]string, error) {
+	binPath, err := getGoBinPath(pkg, s)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(binPath); err != nil {
+		return []string{fmt.Sprintf("missing binary %s", binPath)}, nil
+	}
+
+	version, err := pkg.LookupVersion(s)
+	if err != nil || version == "" || version == defaultVersion {
+		return nil, err
+	}
+
+	out, err := run.Cmd(s, marecmd.Input{Command: fmt.Sprintf("go version -m %s", binPath)})
+	if err != nil {
+		return nil, err
+	}
+	installed, err := parseModVersion(out.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("error getting version of %s: %v", binPath, err)
+	}
+	if !versionMatches(installed, version) {
+		return []string{fmt.Sprintf("installed version %s, want %s", installed, version)}, nil
+	}
+
+	return nil, nil
+}
+
+func goResources(cfg entity.Config) []resource {
+	var resources []resource
 	for _, pkg := range cfg.Go {
-		err := goInstall(pkg, cfg.Settings)
-		goErrs = append(goErrs, err)
+		resources = append(resources, resource{
+			name:   pkg.Name(),
+			meta:   pkg.Meta,
+			when:   pkg,
+			unless: pkg,
+			apply: func() (bool, error) {
+				return true, goInstall(pkg, cfg.Settings)
+			},
+			artifacts: func() []artifact {
+				return goArtifacts(pkg, cfg.Settings)
+			},
+			plan: func() ([]string, error) {
+				qualifiedName, err := qualifyPkg(pkg, cfg.Settings)
+				if err != nil {
+					return nil, err
+				}
+				return []string{fmt.Sprintf("go install %s", qualifiedName)}, nil
+			},
+			check: func(resourceState) ([]string, error) {
+				return checkGoPkg(pkg, cfg.Settings)
+			},
+		})
 	}
 
-	return errors.Join(goErrs...)
+	return resources
 }
@@ -0,0 +1,191 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/femnad/fup/internal"
+)
+
+const dependencySep = ":"
+
+// dependency references a resource by kind and name, or all resources of a kind if name is empty.
+type dependency struct {
+	kind string
+	name string
+}
+
+func parseDependency(ref string) dependency {
+	kind, name, _ := strings.Cut(ref, dependencySep)
+	return dependency{kind: kind, name: name}
+}
+
+func (d dependency) matches(r resource) bool {
+	return d.kind == r.kind && (d.name == "" || d.name == r.name)
+}
+
+// resolveDependencies returns the indices of the resources each resource depends on.
+func resolveDependencies(resources []resource, kinds mapset.Set[string]) ([][]int, error) {
+	deps := make([][]int, len(resources))
+	for i, r := range resources {
+		for _, ref := range r.meta.DependsOn {
+			dep := parseDependency(ref)
+			if !kinds.Contains(dep.kind) {
+				return nil, fmt.Errorf("%s depends on %s which has an unknown kind", r.id(), ref)
+			}
+
+			var matched bool
+			for j, other := range resources {
+				// A resource depending on its own kind depends on all the other resources of that kind.
+				if i == j && dep.name == "" {
+					continue
+				}
+				if dep.matches(other) {
+					deps[i] = append(deps[i], j)
+					matched = true
+				}
+			}
+
+			if !matched {
+				internal.Logger.Warn().Str("resource", r.id()).Str("dependency", ref).Msg(
+					"Dependency does not match any selected resource, ignoring")
+			}
+		}
+	}
+
+	return deps, nil
+}
+
+func findCycle(resources []resource, deps [][]int, remaining mapset.Set[int]) []string {
+	visiting := make(map[int]bool)
+	visited := make(map[int]bool)
+	var stack []int
+	var cycle []int
+
+	var visit func(int) bool
+	visit = func(i int) bool {
+		visiting[i] = true
+		stack = append(stack, i)
+		for _, dep := range deps[i] {
+			if !remaining.Contains(dep) || visited[dep] {
+				continue
+			}
+			if visiting[dep] {
+				for k, s := range stack {
+					if s == dep {
+						cycle = append(append(cycle, stack[k:]...), dep)
+						return true
+					}
+				}
+			}
+			if visit(dep) {
+				return true
+			}
+		}
+		stack = stack[:len(stack)-1]
+		visiting[i] = false
+		visited[i] = true
+		return false
+	}
+
+	for i := range resources {
+		if remaining.Contains(i) && !visited[i] && visit(i) {
+			break
+		}
+	}
+
+	var ids []string
+	for _, i := range cycle {
+		ids = append(ids, resources[i].id())
+	}
+	return ids
+}
+
+// numberDuplicates numbers the resources sharing a kind and name so that each resource has its own ID for tracking
+// dependencies and state.
+func numberDuplicates(resources []resource) {
+	seen := make(map[string]int)
+	for i := range resources {
+		key := resources[i].kind + dependencySep + resources[i].name
+		resources[i].seq = seen[key]
+		seen[key]++
+	}
+}
+
+// sortResources orders the resources so that each resource comes after its dependencies, keeping the original order
+// otherwise.
+func sortResources(resources []resource, kinds mapset.Set[string]) ([]resource, error) {
+	numberDuplicates(resources)
+	deps, err := resolveDependencies(resources, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := mapset.NewThreadUnsafeSet[int]()
+	for i := range resources {
+		remaining.Add(i)
+	}
+
+	var sorted []resource
+	for remaining.Cardinality() > 0 {
+		next := -1
+		for i := range resources {
+			if !remaining.Contains(i) {
+				continue
+			}
+
+			ready := true
+			for _, dep := range deps[i] {
+				if remaining.Contains(dep) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+
+		if next < 0 {
+			cycle := findCycle(resources, deps, remaining)
+			return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		r := resources[next]
+		for _, dep := range deps[next] {
+			r.deps = append(r.deps, resources[dep].id())
+		}
+		sorted = append(sorted, r)
+		remaining.Remove(next)
+	}
+
+	return sorted, nil
+}
+
+// batchResources splits sorted resources into consecutive batches of the same kind where no resource depends on
+// another resource in the same batch.
+func batchResources(resources []resource) [][]resource {
+	var batches [][]resource
+	var batch []resource
+	ids := mapset.NewThreadUnsafeSet[string]()
+
+	for _, r := range resources {
+		if len(batch) > 0 && (batch[0].kind != r.kind || ids.ContainsAny(r.deps...)) {
+			batches = append(batches, batch)
+			batch = nil
+			ids.Clear()
+		}
+
+		batch = append(batch, r)
+		ids.Add(r.id())
+	}
+
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
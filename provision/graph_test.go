@@ -0,0 +1,209 @@
+package provision
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/settings"
+)
+
+func dependentResource(kind, name string, dependsOn ...string) resource {
+	return resource{kind: kind, name: name, meta: entity.Meta{DependsOn: dependsOn}}
+}
+
+func Test_sortResources(t *testing.T) {
+	kinds := mapset.NewThreadUnsafeSet("package", "release", "repo", "task")
+	tests := []struct {
+		name      string
+		resources []resource
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name: "No dependencies",
+			resources: []resource{
+				dependentResource("repo", "foo"),
+				dependentResource("release", "bar"),
+			},
+			want: []string{"repo:foo", "release:bar"},
+		},
+		{
+			name: "Dependency across kinds",
+			resources: []resource{
+				dependentResource("task", "setup", "release:fzf"),
+				dependentResource("repo", "foo"),
+				dependentResource("release", "fzf", "package:curl"),
+				dependentResource("package", "curl"),
+			},
+			want: []string{"repo:foo", "package:curl", "release:fzf", "task:setup"},
+		},
+		{
+			name: "Dependency on kind",
+			resources: []resource{
+				dependentResource("task", "setup", "package"),
+				dependentResource("package", "curl"),
+				dependentResource("package", "git"),
+			},
+			want: []string{"package:curl", "package:git", "task:setup"},
+		},
+		{
+			name: "Same name",
+			resources: []resource{
+				dependentResource("task", "setup", "package:curl"),
+				dependentResource("package", "curl"),
+				dependentResource("package", "curl"),
+			},
+			want: []string{"package:curl", "package:curl#2", "task:setup"},
+		},
+		{
+			name: "Unknown kind",
+			resources: []resource{
+				dependentResource("task", "setup", "foo:bar"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cycle",
+			resources: []resource{
+				dependentResource("task", "setup", "release:fzf"),
+				dependentResource("release", "fzf", "task:setup"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Self dependency",
+			resources: []resource{
+				dependentResource("task", "setup", "task:setup"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sortResources(tt.resources, kinds)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sortResources() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var ids []string
+			for _, r := range got {
+				ids = append(ids, r.id())
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("sortResources() got = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  entity.Config
+		wantErr bool
+	}{
+		{
+			name: "Valid dependencies",
+			config: entity.Config{
+				Packages: []entity.PackageGroup{{Pkgs: []string{"curl"}}},
+				Templates: []entity.Template{{Meta: entity.Meta{DependsOn: []string{"package"}}, Content: "foo",
+					Dest: "/tmp/foo"}},
+			},
+		},
+		{
+			name: "Unknown dependency kind",
+			config: entity.Config{
+				Templates: []entity.Template{{Meta: entity.Meta{DependsOn: []string{"foo:bar"}}, Content: "foo",
+					Dest: "/tmp/foo"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.config, Options{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_batchResources(t *testing.T) {
+	resources := []resource{
+		{kind: "release", name: "foo"},
+		{kind: "release", name: "bar"},
+		{kind: "release", name: "baz", deps: []string{"release:foo"}},
+		{kind: "task", name: "qux"},
+	}
+	want := [][]string{{"release:foo", "release:bar"}, {"release:baz"}, {"task:qux"}}
+
+	var got [][]string
+	for _, batch := range batchResources(resources) {
+		var ids []string
+		for _, r := range batch {
+			ids = append(ids, r.id())
+		}
+		got = append(got, ids)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("batchResources() got = %v, want %v", got, want)
+	}
+}
+
+func Test_applySkipsDependents(t *testing.T) {
+	var applied []string
+	applyFn := func(name string, err error) func() (bool, error) {
+		return func() (bool, error) {
+			applied = append(applied, name)
+			return true, err
+		}
+	}
+
+	resources := map[string][]resource{
+		"package": {{name: "curl", apply: applyFn("curl", errors.New("fail"))}},
+		"release": {{name: "fzf", meta: entity.Meta{DependsOn: []string{"package:curl"}}, apply: applyFn("fzf", nil)}},
+		"task": {
+			{name: "setup", meta: entity.Meta{DependsOn: []string{"release:fzf"}}, apply: applyFn("setup", nil)},
+			{name: "other", apply: applyFn("other", nil)},
+		},
+	}
+
+	p := provisioners{provMap: map[string]provisionFn{}}
+	for _, kind := range []string{"task", "release", "package"} {
+		p.provMap[kind] = provisionFn{name: kind, fn: func() ([]resource, error) {
+			return resources[kind], nil
+		}}
+		p.order = append(p.order, kind)
+	}
+
+	report, err := p.apply(settings.Settings{}, nil, false)
+	if err == nil {
+		t.Errorf("apply() expected error")
+	}
+
+	wantApplied := []string{"other", "curl"}
+	if !reflect.DeepEqual(applied, wantApplied) {
+		t.Errorf("apply() applied = %v, want %v", applied, wantApplied)
+	}
+
+	outcomes := make(map[string]Outcome)
+	for _, r := range report.Resources {
+		outcomes[r.Provisioner+":"+r.Name] = r.Outcome
+	}
+	wantOutcomes := map[string]Outcome{
+		"task:other":   OutcomeChanged,
+		"package:curl": OutcomeFailed,
+		"release:fzf":  OutcomeDependencyFailed,
+		"task:setup":   OutcomeDependencyFailed,
+	}
+	if !reflect.DeepEqual(outcomes, wantOutcomes) {
+		t.Errorf("apply() outcomes = %v, want %v", outcomes, wantOutcomes)
+	}
+}
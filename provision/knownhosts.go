@@ -13,10 +13,10 @@ import (
 
 const knownHostsFile = "~/.ssh/known_hosts"
 
-func addKnownHost(host string) error {
+func addKnownHost(host string) (bool, error) {
 	out, err := marecmd.RunFmtErr(marecmd.Input{Command: fmt.Sprintf("ssh-keyscan %s", host)})
 	if err != nil {
-		return fmt.Errorf("error adding known host: %v", err)
+		return false, fmt.Errorf("error adding known host: %v", err)
 	}
 
 	scanner := bufio.NewScanner(bytes.NewBuffer([]byte(out.Stdout)))
@@ -31,23 +31,28 @@ func addKnownHost(host string) error {
 	return common.EnsureLinesInFile(knownHostsFile, hostKeys)
 }
 
-func addKnownHosts(hosts []string) error {
-	for _, host := range hosts {
-		err := addKnownHost(host)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+func isKnownHost(host string) bool {
+	cmd := fmt.Sprintf("ssh-keygen -F %s -f %s", host, internal.ExpandUser(knownHostsFile))
+	out, _ := marecmd.Run(marecmd.Input{Command: cmd})
+	return out.Code == 0
 }
 
-func acceptHostKeys(config entity.Config) error {
-	err := addKnownHosts(config.AcceptHostKeys)
-	if err != nil {
-		internal.Logger.Error().Stack().Err(err).Msg("Error adding known host keys")
-		return err
+func hostKeyResources(config entity.Config) []resource {
+	var resources []resource
+	for _, host := range config.AcceptHostKeys {
+		resources = append(resources, resource{
+			name: host,
+			apply: func() (bool, error) {
+				return addKnownHost(host)
+			},
+			plan: func() ([]string, error) {
+				if isKnownHost(host) {
+					return nil, nil
+				}
+				return []string{fmt.Sprintf("add host keys to %s", knownHostsFile)}, nil
+			},
+		})
 	}
 
-	return nil
+	return resources
 }
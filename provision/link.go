@@ -8,7 +8,7 @@ import (
 	"github.com/femnad/fup/internal"
 )
 
-func createSymlink(symlink entity.NamedLink, linkDir, binPath string) error {
+func getSymlinkPaths(symlink entity.NamedLink, linkDir, binPath string) (string, string) {
 	symlinkTarget := path.Join(linkDir, symlink.Target)
 	symlinkTarget = internal.ExpandUser(symlinkTarget)
 
@@ -23,5 +23,10 @@ func createSymlink(symlink entity.NamedLink, linkDir, binPath string) error {
 	symlinkName := path.Join(binPath, symlinkBasename)
 	symlinkName = internal.ExpandUser(symlinkName)
 
+	return symlinkName, symlinkTarget
+}
+
+func createSymlink(symlink entity.NamedLink, linkDir, binPath string) error {
+	symlinkName, symlinkTarget := getSymlinkPaths(symlink, linkDir, binPath)
 	return common.Symlink(symlinkName, symlinkTarget)
 }
@@ -0,0 +1,102 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/femnad/fup/internal"
+)
+
+const (
+	lockFileName = "apply.lock"
+	lockFilePerm = 0o644
+)
+
+// applyLock prevents concurrent apply runs. The lock is held with flock so that it's released when the holding process
+// exits, and the file contains the PID of the holder for reporting.
+type applyLock struct {
+	file *os.File
+}
+
+func lockFile() string {
+	return path.Join(internal.StateDir(), lockFileName)
+}
+
+func readLockPID(f *os.File) int {
+	content, err := os.ReadFile(f.Name())
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
+
+// acquireLock takes the apply lock, waiting for the current holder to release it if wait is set.
+func acquireLock(wait bool) (*applyLock, error) {
+	file := lockFile()
+	dir, _ := path.Split(file)
+	err := os.MkdirAll(dir, dirMode)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, lockFilePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		pid := readLockPID(f)
+		if !wait {
+			f.Close()
+			return nil, fmt.Errorf("another apply is running with PID %d, holding lock %s, use --wait to wait for it",
+				pid, file)
+		}
+
+		internal.Logger.Info().Int("pid", pid).Str("file", file).Msg("Waiting for lock held by another apply")
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error locking %s: %v", file, err)
+	}
+
+	// A PID left in an unlocked file is from a run which didn't release the lock, such as one which was killed.
+	if pid := readLockPID(f); pid != 0 && pid != os.Getpid() {
+		internal.Logger.Info().Int("pid", pid).Str("file", file).Msg("Taking over stale lock")
+	}
+
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error writing lock %s: %v", file, err)
+	}
+
+	return &applyLock{file: f}, nil
+}
+
+func (l *applyLock) release() error {
+	// The file is kept as waiting processes may already have it open, removing it would let a new run lock a
+	// different file.
+	err := l.file.Truncate(0)
+	if err != nil {
+		internal.Logger.Warn().Err(err).Str("file", l.file.Name()).Msg("Error clearing lock file")
+	}
+
+	err = syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	return errors.Join(err, l.file.Close())
+}
@@ -0,0 +1,69 @@
+package provision
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femnad/fup/internal"
+)
+
+func Test_acquireLock(t *testing.T) {
+	t.Setenv(internal.StateHomeEnv, t.TempDir())
+
+	// A lock file left by a process which didn't release it.
+	if err := os.MkdirAll(internal.StateDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockFile(), []byte("999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := acquireLock(false)
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v", err)
+	}
+
+	content, err := os.ReadFile(lockFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(content)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("acquireLock() lock content = %s, want current PID", content)
+	}
+
+	_, err = acquireLock(false)
+	if err == nil || !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Errorf("acquireLock() error = %v, want error naming the PID holding the lock", err)
+	}
+
+	acquired := make(chan *applyLock)
+	go func() {
+		waited, waitErr := acquireLock(true)
+		if waitErr != nil {
+			t.Errorf("acquireLock() error = %v", waitErr)
+		}
+		acquired <- waited
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("acquireLock() didn't wait for the lock to be released")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err = lock.release(); err != nil {
+		t.Fatalf("release() error = %v", err)
+	}
+
+	select {
+	case waited := <-acquired:
+		if waited != nil {
+			_ = waited.release()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("acquireLock() didn't acquire the released lock")
+	}
+}
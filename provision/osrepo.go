@@ -1,17 +1,41 @@
 package provision
 
 import (
-	"errors"
-
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
-	"github.com/femnad/fup/precheck/unless"
-	"github.com/femnad/fup/precheck/when"
 )
 
-func addRepos(config entity.Config) error {
-	var errs []error
+func addRepo(repo entity.OSRepo) (bool, error) {
+	exists, err := repo.Exists()
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	internal.Logger.Debug().Str("name", repo.Name()).Msg("Adding repo")
+
+	err = repo.Install()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func planRepo(repo entity.OSRepo) ([]string, error) {
+	exists, err := repo.Exists()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, nil
+	}
 
+	return []string{"add repo"}, nil
+}
+
+func repoResources(config entity.Config) []resource {
 	var repos []entity.OSRepo
 	for _, repo := range config.AptRepos {
 		repos = append(repos, repo)
@@ -20,32 +44,21 @@ func addRepos(config entity.Config) error {
 		repos = append(repos, repo)
 	}
 
+	var resources []resource
 	for _, repo := range repos {
-		if !when.ShouldRun(repo) {
-			continue
-		}
-
-		if unless.ShouldSkip(repo, config.Settings) {
-			continue
-		}
-
-		exists, err := repo.Exists()
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		if exists {
-			continue
-		}
-		internal.Logger.Debug().Str("name", repo.Name()).Msg("Adding repo")
-
-		err = repo.Install()
-		if err != nil {
-			internal.Logger.Error().Err(err).Str("name", repo.Name()).Msg("Error installing repo")
-			errs = append(errs, err)
-			continue
-		}
-	}
-
-	return errors.Join(errs...)
+		resources = append(resources, resource{
+			name:   repo.Name(),
+			meta:   repo.GetMeta(),
+			when:   repo,
+			unless: repo,
+			apply: func() (bool, error) {
+				return addRepo(repo)
+			},
+			plan: func() ([]string, error) {
+				return planRepo(repo)
+			},
+		})
+	}
+
+	return resources
 }
@@ -0,0 +1,158 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/precheck/unless"
+	"github.com/femnad/fup/settings"
+)
+
+const (
+	outdatedError   = "error"
+	outdatedMissing = "-"
+)
+
+// versionReporter reports the version a resource is configured with and the latest version per its version lookup.
+type versionReporter interface {
+	ConfiguredVersion(settings.Settings) string
+	LatestVersion(settings.Settings) (string, error)
+}
+
+// outdatedCheck gets the installed and desired versions of a resource.
+type outdatedCheck struct {
+	name      string
+	installed func() (string, error)
+	versions  versionReporter
+}
+
+type outdatedEntry struct {
+	name       string
+	installed  string
+	configured string
+	latest     string
+}
+
+// outdatable is a resource whose installed version is determined by its unless command.
+type outdatable interface {
+	unless.Unlessable
+	versionReporter
+}
+
+func unlessCheck(u outdatable, s settings.Settings) outdatedCheck {
+	return outdatedCheck{name: u.Name(), versions: u, installed: func() (string, error) {
+		return unless.InstalledVersion(u, s)
+	}}
+}
+
+func outdatedChecks(config entity.Config, s settings.Settings) ([]outdatedCheck, error) {
+	var checks []outdatedCheck
+	releases, err := configReleases(config)
+	for _, release := range releases {
+		checks = append(checks, unlessCheck(release, s))
+	}
+	for _, pkg := range config.Python {
+		checks = append(checks, unlessCheck(withLibraryUnless(pkg, config), s))
+	}
+	for _, pkg := range config.Go {
+		checks = append(checks, unlessCheck(pkg, s))
+	}
+	for _, pkg := range config.Cargo {
+		checks = append(checks, unlessCheck(pkg, s))
+	}
+	for _, tool := range config.UvTools {
+		checks = append(checks, outdatedCheck{name: tool.Name(), versions: tool, installed: func() (string, error) {
+			return getToolVersion(tool.Name())
+		}})
+	}
+
+	return checks, err
+}
+
+func (c outdatedCheck) run(s settings.Settings) outdatedEntry {
+	entry := outdatedEntry{name: c.name, configured: c.versions.ConfiguredVersion(s)}
+
+	installed, err := c.installed()
+	if err != nil {
+		internal.Logger.Debug().Err(err).Str("name", c.name).Msg("Unable to determine installed version")
+	}
+	entry.installed = installed
+
+	latest, err := c.versions.LatestVersion(s)
+	if err != nil {
+		internal.Logger.Error().Err(err).Str("name", c.name).Msg("Error looking up latest version")
+		latest = outdatedError
+	}
+	entry.latest = latest
+
+	return entry
+}
+
+// outdatedEntries runs the checks for all releases and packages with versions, using a pool of workers as version
+// lookups can be slow.
+func outdatedEntries(config entity.Config) ([]outdatedEntry, error) {
+	s := config.Settings
+	s.Internal.GhAvailable = ghCliAvailable(s)
+	checks, err := outdatedChecks(config, s)
+
+	results := make([]chan outdatedEntry, len(checks))
+	for i := range results {
+		results[i] = make(chan outdatedEntry, 1)
+	}
+
+	jobs := make(chan int)
+	for range s.GetReleaseWorkers() {
+		go func() {
+			for i := range jobs {
+				results[i] <- checks[i].run(s)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range checks {
+			jobs <- i
+		}
+	}()
+
+	var entries []outdatedEntry
+	for _, result := range results {
+		entries = append(entries, <-result)
+	}
+
+	return entries, err
+}
+
+func orMissing(version string) string {
+	if version == "" {
+		return outdatedMissing
+	}
+
+	return version
+}
+
+func writeOutdated(w io.Writer, entries []outdatedEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tINSTALLED\tCONFIGURED\tLATEST")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.name, orMissing(entry.installed), orMissing(entry.configured),
+			orMissing(entry.latest))
+	}
+
+	return tw.Flush()
+}
+
+// WriteOutdated writes a table of the installed, configured and latest versions of the releases and packages in the
+// config, regardless of when conditions and tags.
+func WriteOutdated(w io.Writer, config entity.Config) error {
+	entries, err := outdatedEntries(config)
+	writeErr := writeOutdated(w, entries)
+	if err != nil {
+		return err
+	}
+
+	return writeErr
+}
@@ -0,0 +1,64 @@
+package provision
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/precheck/unless"
+	"github.com/femnad/fup/settings"
+)
+
+func Test_outdatedEntries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body><a>2.0.0</a></body></html>"))
+	}))
+	defer server.Close()
+
+	config := entity.Config{
+		Cargo: []entity.CargoPkg{{Crate: "baz", Unless: unless.Unless{Cmd: "false"}}},
+		Go:    []entity.GoPkg{{Pkg: "qux", Version: "0.1.0", Unless: unless.Unless{Stat: "/tmp"}}},
+		Releases: []entity.Release{{
+			Ref:           "foo",
+			Unless:        unless.Unless{Cmd: "echo 1.0.0"},
+			VersionLookup: entity.VersionLookupSpec{GetFirst: true, Query: "//a", URL: server.URL},
+		}},
+		Settings: settings.Settings{Versions: map[string]string{"foo": "1.1.0"}},
+	}
+
+	got, err := outdatedEntries(config)
+	if err != nil {
+		t.Fatalf("outdatedEntries() error = %v", err)
+	}
+
+	want := []outdatedEntry{
+		{name: "foo", installed: "1.0.0", configured: "1.1.0", latest: "2.0.0"},
+		{name: "qux", configured: "0.1.0"},
+		{name: "baz"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("outdatedEntries() got = %v, want %v", got, want)
+	}
+}
+
+func Test_writeOutdated(t *testing.T) {
+	var out bytes.Buffer
+	err := writeOutdated(&out, []outdatedEntry{
+		{name: "foo", installed: "1.0.0", configured: "1.1.0", latest: "2.0.0"},
+		{name: "barbaz"},
+	})
+	if err != nil {
+		t.Fatalf("writeOutdated() error = %v", err)
+	}
+
+	want := `NAME    INSTALLED  CONFIGURED  LATEST
+foo     1.0.0      1.1.0       2.0.0
+barbaz  -          -           -
+`
+	if got := out.String(); got != want {
+		t.Errorf("writeOutdated() got =\n%s\nwant\n%s", got, want)
+	}
+}
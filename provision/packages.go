@@ -1,27 +1,17 @@
 package provision
 
 import (
-	"errors"
 	"fmt"
-
-	mapset "github.com/deckarep/golang-set/v2"
-	"github.com/femnad/fup/internal"
+	"strings"
 
 	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/internal"
 	"github.com/femnad/fup/packages"
 	"github.com/femnad/fup/precheck"
-	"github.com/femnad/fup/precheck/when"
 	"github.com/femnad/fup/settings"
+	"github.com/femnad/mare"
 )
 
-func matchingPackages(group entity.PackageGroup) []string {
-	if !when.ShouldRun(group) {
-		return []string{}
-	}
-
-	return group.Pkgs
-}
-
 func getInstaller(osId string) (packages.Installer, error) {
 	var installer packages.Installer
 	var pkg packages.PkgManager
@@ -70,39 +60,6 @@ func (d determiner) installer() (packages.Installer, error) {
 	return installer, nil
 }
 
-func (d determiner) matchingPkg(spec entity.PackageSpec, install bool) mapset.Set[string] {
-	pkgToInstall := mapset.NewSet[string]()
-	for _, group := range spec {
-		if group.Absent && install {
-			continue
-		} else if !group.Absent && !install {
-			continue
-		}
-		matches := matchingPackages(group)
-		for _, match := range matches {
-			pkgToInstall.Add(match)
-		}
-	}
-
-	return pkgToInstall
-}
-
-func (d determiner) matchingRemotePkg(spec entity.RemotePackageSpec) (mapset.Set[entity.RemotePackage], error) {
-	pkgToInstall := mapset.NewSet[entity.RemotePackage]()
-
-	for _, group := range spec {
-		if !when.ShouldRun(group) {
-			continue
-		}
-
-		for _, pkg := range group.Pkgs {
-			pkgToInstall.Add(pkg)
-		}
-	}
-
-	return pkgToInstall, nil
-}
-
 type packager struct {
 	determiner determiner
 	installer  packages.Installer
@@ -125,39 +82,115 @@ func newPackager() (packager, error) {
 	}, nil
 }
 
-func (p packager) ensurePackages(spec entity.PackageSpec) error {
-	pkgToRemove := p.determiner.matchingPkg(spec, false)
-	removeErr := p.installer.Remove(pkgToRemove)
+func pkgGroupName(pkgs []string) string {
+	return strings.Join(pkgs, " ")
+}
+
+func (p packager) ensureGroup(group entity.PackageGroup) (bool, error) {
+	pkgs := internal.SetFromList(group.Pkgs)
+	if group.Absent {
+		if len(p.installer.Present(pkgs)) == 0 {
+			return false, nil
+		}
+		return true, p.installer.Remove(pkgs)
+	}
 
-	pkgToInstall := p.determiner.matchingPkg(spec, true)
-	installErr := p.installer.Install(pkgToInstall)
+	if len(p.installer.Missing(pkgs)) == 0 {
+		return false, nil
+	}
+	return true, p.installer.Install(pkgs)
+}
 
-	return errors.Join(installErr, removeErr)
+func (p packager) planGroup(group entity.PackageGroup) []string {
+	pkgs := internal.SetFromList(group.Pkgs)
+	if group.Absent {
+		present := p.installer.Present(pkgs)
+		if len(present) == 0 {
+			return nil
+		}
+		return []string{fmt.Sprintf("remove %s", strings.Join(present, " "))}
+	}
+
+	missing := p.installer.Missing(pkgs)
+	if len(missing) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("install %s", strings.Join(missing, " "))}
 }
 
-func (p packager) installRemotePackages(spec entity.RemotePackageSpec, s settings.Settings) error {
-	pkgToInstall, err := p.determiner.matchingRemotePkg(spec)
+func (p packager) ensureRemoteGroup(group entity.RemotePackageGroup, s settings.Settings) (bool, error) {
+	pkgs := internal.SetFromList(group.Pkgs)
+	missing, err := p.installer.MissingRemote(pkgs, s)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if len(missing) == 0 {
+		return false, nil
 	}
 
-	return p.installer.RemoteInstall(pkgToInstall, s)
+	return true, p.installer.RemoteInstall(pkgs, s)
 }
 
-func installPackages(p Provisioner) error {
-	var pkgErrs []error
-
-	err := p.Packager.installRemotePackages(p.Config.RemotePackages, p.Config.Settings)
+func (p packager) planRemoteGroup(group entity.RemotePackageGroup, s settings.Settings) ([]string, error) {
+	missing, err := p.installer.MissingRemote(internal.SetFromList(group.Pkgs), s)
 	if err != nil {
-		internal.Logger.Error().Err(err).Msg("Error installing remote packages")
-		pkgErrs = append(pkgErrs, err)
+		return nil, err
 	}
 
-	err = p.Packager.ensurePackages(p.Config.Packages)
-	if err != nil {
-		internal.Logger.Error().Err(err).Msg("Error installing packages")
-		pkgErrs = append(pkgErrs, err)
+	var changes []string
+	for _, pkg := range missing {
+		changes = append(changes, fmt.Sprintf("install %s", pkg.Url))
+	}
+	return changes, nil
+}
+
+func packageResources(p Provisioner) []resource {
+	var resources []resource
+	s := p.Config.Settings
+
+	for _, group := range p.Config.RemotePackages {
+		names := mare.Map(group.Pkgs, func(pkg entity.RemotePackage) string {
+			return pkg.Name
+		})
+		resources = append(resources, resource{
+			name: pkgGroupName(names),
+			meta: group.Meta,
+			when: group,
+			apply: func() (bool, error) {
+				return p.Packager.ensureRemoteGroup(group, s)
+			},
+			plan: func() ([]string, error) {
+				return p.Packager.planRemoteGroup(group, s)
+			},
+		})
+	}
+
+	// Remove undesired packages before installing the desired ones.
+	var groups []entity.PackageGroup
+	for _, group := range p.Config.Packages {
+		if group.Absent {
+			groups = append(groups, group)
+		}
+	}
+	for _, group := range p.Config.Packages {
+		if !group.Absent {
+			groups = append(groups, group)
+		}
+	}
+
+	for _, group := range groups {
+		resources = append(resources, resource{
+			name: pkgGroupName(group.Pkgs),
+			meta: group.Meta,
+			when: group,
+			apply: func() (bool, error) {
+				return p.Packager.ensureGroup(group)
+			},
+			plan: func() ([]string, error) {
+				return p.Packager.planGroup(group), nil
+			},
+		})
 	}
 
-	return errors.Join(pkgErrs...)
+	return resources
 }
@@ -4,39 +4,70 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
 
-	"github.com/femnad/fup/common"
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/remote"
+	"github.com/femnad/fup/settings"
+)
+
+const (
+	// Keep applying the remaining resources after a resource fails.
+	OnErrorContinue = "continue"
+	// Don't attempt any more resources after a resource fails.
+	OnErrorStop = "stop"
 )
 
+// Options adjust how a Provisioner runs.
+type Options struct {
+	// Provisioners to run, all provisioners are run if empty.
+	Provisioners []string
+	// Remove the artifacts of resources which are no longer declared.
+	Prune bool
+	// Only run resources with any of these tags, all resources are run if empty.
+	Tags []string
+	// Don't run resources with any of these tags.
+	SkipTags []string
+	// What to do after a resource fails, either OnErrorContinue or OnErrorStop. Defaults to OnErrorContinue.
+	OnError string
+	// Wait for another apply holding the lock to finish instead of failing.
+	Wait bool
+}
+
 type Provisioner struct {
 	Config       entity.Config
 	Packager     packager
+	opts         Options
 	provisioners provisioners
+	// Whether the resources are only listed for validation, which shouldn't run any commands on the host.
+	validating bool
 }
 
 type provisionFn struct {
 	name string
-	fn   func() error
+	desc string
+	fn   func() ([]resource, error)
+	// Number of resources to fetch concurrently, resources are processed one at a time if not greater than 1.
+	workers int
+	// Whether the provisioner runs commands instead of ensuring a state, so its resources can't drift.
+	stateless bool
 }
 
 type provisioners struct {
-	provMap map[string]func() error
+	provMap map[string]provisionFn
 	order   []string
+	tags    tagFilter
+	// Don't attempt any more resources after the first failure.
+	stopOnError bool
 }
 
-func (p provisioners) apply() error {
-	var provErrs []error
-	for _, fnName := range p.order {
-		fn := p.provMap[fnName]
-		err := fn()
-		provErrs = append(provErrs, err)
-	}
-
+func uniqueErrors(errs []error) error {
 	var uniqErrs []error
 	seenErr := make(map[string]error)
-	for _, err := range provErrs {
+	for _, err := range errs {
 		if err == nil {
 			continue
 		}
@@ -51,6 +82,357 @@ func (p provisioners) apply() error {
 	return errors.Join(uniqErrs...)
 }
 
+func (p provisioners) resources(prov provisionFn) ([]resource, error) {
+	resources, err := prov.fn()
+	for i := range resources {
+		resources[i].kind = prov.name
+	}
+
+	return resources, err
+}
+
+func applyResource(r resource, fetchErr error) (Outcome, error) {
+	if fetchErr != nil {
+		return OutcomeFailed, fetchErr
+	}
+
+	changed, err := r.apply()
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	if changed {
+		return OutcomeChanged, nil
+	}
+
+	return OutcomeUnchanged, nil
+}
+
+// applyRun holds what is shared across the resource batches of an apply run.
+type applyRun struct {
+	s      settings.Settings
+	report *Report
+	// IDs of resources which failed or didn't run.
+	failed mapset.Set[string]
+	state  *state
+	// Whether to stop after the first failure, and whether a failure has caused the run to stop.
+	stopOnError bool
+	stopped     bool
+	// Closed when the run stops, so that no more resources are fetched.
+	stop chan struct{}
+}
+
+func newApplyRun(s settings.Settings, report *Report, st *state, stopOnError bool) *applyRun {
+	return &applyRun{
+		s:           s,
+		report:      report,
+		failed:      mapset.NewThreadUnsafeSet[string](),
+		state:       st,
+		stopOnError: stopOnError,
+		stop:        make(chan struct{}),
+	}
+}
+
+// fail stops the run if it should stop after the first failure.
+func (a *applyRun) fail() {
+	if !a.stopOnError || a.stopped {
+		return
+	}
+
+	a.stopped = true
+	close(a.stop)
+}
+
+// applyBatch applies a batch of resources of the same provisioner, none of which depend on each other. Resources
+// that fail or don't run are added to the failed set so that their dependents can be skipped, unless errors are
+// ignored for the resource.
+func (a *applyRun) applyBatch(prov provisionFn, resources []resource) []error {
+	check := func(r resource) (Outcome, bool) {
+		if a.failed.ContainsAny(r.deps...) {
+			internal.Logger.Warn().Str("kind", r.kind).Str("name", r.name).Msg(
+				"Skipping due to failed dependency")
+			return OutcomeDependencyFailed, true
+		}
+		return r.skipOutcome(a.s)
+	}
+
+	// Prechecks need to be evaluated up front to know which resources to fetch concurrently.
+	var skipOutcomes []Outcome
+	var fetchResults []chan error
+	if prov.workers > 1 && !a.stopped {
+		var runnable []resource
+		for _, r := range resources {
+			outcome, skip := check(r)
+			skipOutcomes = append(skipOutcomes, outcome)
+			if !skip {
+				runnable = append(runnable, r)
+			}
+		}
+		fetchResults = fetchConcurrently(runnable, prov.workers, a.stop)
+	}
+
+	var errs []error
+	var fetchIndex int
+	record := func(r resource, outcome Outcome, err error, start time.Time) {
+		a.report.add(r, outcome, err, start)
+		switch outcome {
+		case OutcomeFailed, OutcomeNotRun, OutcomeDependencyFailed:
+			a.failed.Add(r.id())
+		case OutcomeChanged, OutcomeUnchanged, OutcomeSkippedByUnless:
+			if a.state != nil {
+				a.state.record(r)
+			}
+		}
+	}
+
+	for i, r := range resources {
+		start := time.Now()
+		if a.stopped {
+			record(r, OutcomeNotRun, nil, start)
+			continue
+		}
+
+		var fetchErr error
+		if skipOutcomes == nil {
+			if outcome, skip := check(r); skip {
+				record(r, outcome, nil, start)
+				continue
+			}
+			fetchErr = r.prefetch()
+		} else if skipOutcomes[i] != "" {
+			record(r, skipOutcomes[i], nil, start)
+			continue
+		} else {
+			fetchErr = <-fetchResults[fetchIndex]
+			fetchIndex++
+		}
+
+		outcome, err := applyResource(r, fetchErr)
+		if err != nil && r.meta.IgnoreErrors {
+			internal.Logger.Warn().Err(err).Str("kind", r.kind).Str("name", r.name).Msg(
+				"Ignoring error applying resource")
+			record(r, OutcomeFailedIgnored, err, start)
+			continue
+		}
+
+		record(r, outcome, err, start)
+		if err == nil {
+			continue
+		}
+
+		internal.Logger.Error().Err(err).Str("kind", r.kind).Str("name", r.name).Msg("Error applying resource")
+		errs = append(errs, err)
+		a.fail()
+	}
+
+	return errs
+}
+
+// enumerate lists the resources of the selected provisioners, ordered so that each resource comes after its
+// dependencies. Provisioners failing to list their resources are returned along with their errors.
+func (p provisioners) enumerate() ([]resource, map[string]error, error) {
+	var all []resource
+	provErrs := make(map[string]error)
+	for _, fnName := range p.order {
+		prov := p.provMap[fnName]
+		resources, err := p.resources(prov)
+		if err != nil {
+			provErrs[prov.name] = err
+		}
+		all = append(all, resources...)
+	}
+
+	kinds := mapset.NewThreadUnsafeSet[string]()
+	for name := range p.provMap {
+		kinds.Add(name)
+	}
+
+	sorted, err := sortResources(all, kinds)
+	return sorted, provErrs, err
+}
+
+func (p provisioners) declaredKinds(enumErrs map[string]error) mapset.Set[string] {
+	kinds := mapset.NewThreadUnsafeSet[string]()
+	for _, fnName := range p.order {
+		// Resources of a provisioner which can't list them can't be told apart from undeclared ones.
+		if enumErrs[fnName] == nil {
+			kinds.Add(fnName)
+		}
+	}
+
+	return kinds
+}
+
+func declaredIDs(resources []resource) mapset.Set[string] {
+	ids := mapset.NewThreadUnsafeSet[string]()
+	for _, r := range resources {
+		ids.Add(r.id())
+	}
+
+	return ids
+}
+
+func (p provisioners) prune(st *state, resources []resource, enumErrs map[string]error, report *Report) []error {
+	var errs []error
+	for _, id := range st.undeclared(p.declaredKinds(enumErrs), declaredIDs(resources)) {
+		start := time.Now()
+		rs := st.Resources[id]
+		r := resource{kind: rs.Provisioner, name: rs.Name}
+		internal.Logger.Info().Str("kind", r.kind).Str("name", r.name).Msg("Pruning undeclared resource")
+
+		err := st.prune(id)
+		if err != nil {
+			internal.Logger.Error().Err(err).Str("kind", r.kind).Str("name", r.name).Msg("Error pruning resource")
+			report.add(r, OutcomeFailed, err, start)
+			errs = append(errs, err)
+			continue
+		}
+		report.add(r, OutcomePruned, nil, start)
+	}
+
+	return errs
+}
+
+func (p provisioners) apply(s settings.Settings, st *state, prune bool) (Report, error) {
+	report := newReport()
+	start := time.Now()
+	resources, enumErrs, err := p.enumerate()
+	if err != nil {
+		report.finish(err)
+		return report, err
+	}
+
+	run := newApplyRun(s, &report, st, p.stopOnError)
+	var provErrs []error
+	for _, fnName := range p.order {
+		err = enumErrs[fnName]
+		if err != nil {
+			report.add(resource{kind: fnName}, OutcomeFailed, err, start)
+			provErrs = append(provErrs, err)
+			// Resources are listed before any of them is applied, so stopping means not applying anything.
+			run.fail()
+		}
+	}
+
+	for _, batch := range batchResources(p.tags.filter(resources)) {
+		prov := p.provMap[batch[0].kind]
+		if !run.stopped {
+			internal.Logger.Info().Msg(prov.desc)
+		}
+		provErrs = append(provErrs, run.applyBatch(prov, batch)...)
+	}
+
+	if prune && run.stopped {
+		internal.Logger.Warn().Msg("Not pruning as the run was stopped after a failure")
+	} else if prune {
+		provErrs = append(provErrs, p.prune(st, resources, enumErrs, &report)...)
+	}
+
+	err = uniqueErrors(provErrs)
+	report.finish(err)
+	report.logSummary()
+	return report, err
+}
+
+func (p provisioners) plan(s settings.Settings, st *state, prune bool) error {
+	resources, enumErrs, err := p.enumerate()
+	if err != nil {
+		return err
+	}
+
+	var planErrs []error
+	for _, fnName := range p.order {
+		planErrs = append(planErrs, enumErrs[fnName])
+	}
+
+	var numChanges int
+	for _, r := range p.tags.filter(resources) {
+		changes, planErr := r.pendingChanges(s)
+		if planErr != nil {
+			internal.Logger.Error().Err(planErr).Str("kind", r.kind).Str("name", r.name).Msg(
+				"Error planning resource")
+			planErrs = append(planErrs, fmt.Errorf("error planning %s: %v", r, planErr))
+			continue
+		}
+
+		printChanges(r, changes)
+		if len(changes) > 0 {
+			numChanges++
+		}
+	}
+
+	if prune {
+		for _, id := range st.undeclared(p.declaredKinds(enumErrs), declaredIDs(resources)) {
+			rs := st.Resources[id]
+			printChanges(resource{kind: rs.Provisioner, name: rs.Name}, planPrune(rs))
+			numChanges++
+		}
+	}
+
+	fmt.Printf("%d resource(s) to change\n", numChanges)
+	return uniqueErrors(planErrs)
+}
+
+// status prints the differences between the desired state of the resources and the machine, and returns the number of
+// resources which have drifted.
+func (p provisioners) status(s settings.Settings, st *state) (int, error) {
+	resources, enumErrs, err := p.enumerate()
+	if err != nil {
+		return 0, err
+	}
+
+	var statusErrs []error
+	for _, fnName := range p.order {
+		statusErrs = append(statusErrs, enumErrs[fnName])
+	}
+
+	var numDrifted int
+	for _, r := range p.tags.filter(resources) {
+		if p.provMap[r.kind].stateless {
+			continue
+		}
+		outcome, skip := r.skipOutcome(s)
+		if outcome == OutcomeSkippedByWhen {
+			continue
+		}
+
+		var drift []string
+		if !skip {
+			var statusErr error
+			drift, statusErr = r.drift(st.Resources[r.id()])
+			if statusErr != nil {
+				internal.Logger.Error().Err(statusErr).Str("kind", r.kind).Str("name", r.name).Msg(
+					"Error checking resource")
+				statusErrs = append(statusErrs, fmt.Errorf("error checking %s: %v", r, statusErr))
+				continue
+			}
+		}
+		// Resources skipped due to their unless checks can still have lost their links.
+		drift = append(drift, brokenLinks(st.Resources[r.id()])...)
+
+		printChanges(r, drift)
+		if len(drift) > 0 {
+			numDrifted++
+		}
+	}
+
+	fmt.Printf("%d resource(s) drifted\n", numDrifted)
+	return numDrifted, uniqueErrors(statusErrs)
+}
+
+func (p provisioners) validate() error {
+	_, enumErrs, err := p.enumerate()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, fnName := range p.order {
+		errs = append(errs, enumErrs[fnName])
+	}
+
+	return errors.Join(errs...)
+}
+
 func getOrderedProvisioners(provFns []provisionFn) []string {
 	var names []string
 	for _, fn := range provFns {
@@ -60,12 +442,12 @@ func getOrderedProvisioners(provFns []provisionFn) []string {
 }
 
 func newProvisioners(allProvisioners []provisionFn, filter []string) (provisioners, error) {
-	provMap := make(map[string]func() error)
+	provMap := make(map[string]provisionFn)
 	var order []string
 	var hasFilter = len(filter) > 0
 
 	for _, prov := range allProvisioners {
-		provMap[prov.name] = prov.fn
+		provMap[prov.name] = prov
 		if !hasFilter {
 			order = append(order, prov.name)
 		}
@@ -91,188 +473,234 @@ func newProvisioners(allProvisioners []provisionFn, filter []string) (provisione
 	}, nil
 }
 
-func NewProvisioner(cfg entity.Config, filter []string) (Provisioner, error) {
+func NewProvisioner(cfg entity.Config, opts Options) (Provisioner, error) {
 	pkgr, err := newPackager()
 	if err != nil {
 		return Provisioner{}, err
 	}
 
-	p := Provisioner{Config: cfg, Packager: pkgr}
+	remote.SetCacheLimit(cfg.Settings.GetCacheSize())
+	return newProvisioner(Provisioner{Config: cfg, Packager: pkgr, opts: opts})
+}
+
+// ValidateConfig checks that the resources of the selected provisioners can be listed and that their dependencies are
+// valid and acyclic, without detecting the packager of the OS or running any commands on the host.
+func ValidateConfig(cfg entity.Config, opts Options) error {
+	p, err := newProvisioner(Provisioner{Config: cfg, opts: opts, validating: true})
+	if err != nil {
+		return err
+	}
+
+	return p.provisioners.validate()
+}
+
+func newProvisioner(p Provisioner) (Provisioner, error) {
+	cfg, opts := p.Config, p.opts
 
 	all := []provisionFn{
-		{"pre", p.runPreflightTasks},
-		{"repo", p.AddOSRepos},
-		{"release", p.ensureReleases},
-		{"package", p.installPackages},
-		{"host", p.acceptHostKeys},
-		{"github", p.githubUserKey},
-		{"go", p.goInstall},
-		{"python", p.pythonInstall},
-		{"rust", p.rustInstall},
-		{"uv", p.uvTools},
-		{"clone", p.sshClone},
-		{"task", p.runTasks},
-		{"template", p.applyTemplates},
-		{"service", p.initServices},
-		{"dir", p.ensureDirs},
-		{"line", p.ensureLines},
-		{"archive", p.extractArchive},
-		{"flatpak", p.flatpakInstall},
-		{"snap", p.snapInstall},
-		{"group", p.userInGroup},
-		{"post", p.runPostFlightTasks},
-	}
-
-	provs, err := newProvisioners(all, filter)
+		{name: "pre", desc: "Running preflight tasks", fn: p.runPreflightTasks, stateless: true},
+		{name: "repo", desc: "Adding OS repos", fn: p.AddOSRepos},
+		{name: "release", desc: "Downloading releases", fn: p.ensureReleases,
+			workers: cfg.Settings.GetReleaseWorkers()},
+		{name: "package", desc: "Installing/removing packages", fn: p.installPackages},
+		{name: "host", desc: "Adding known hosts", fn: p.acceptHostKeys},
+		{name: "github", desc: "Adding GitHub user keys", fn: p.githubUserKey},
+		{name: "go", desc: "Installing Go packages", fn: p.goInstall},
+		{name: "python", desc: "Installing Python packages", fn: p.pythonInstall},
+		{name: "rust", desc: "Installing Rust packages", fn: p.rustInstall},
+		{name: "uv", desc: "Installing uv tools", fn: p.uvTools},
+		{name: "clone", desc: "Cloning repos via SSH", fn: p.sshClone},
+		{name: "task", desc: "Running tasks", fn: p.runTasks, stateless: true},
+		{name: "template", desc: "Applying templates", fn: p.applyTemplates},
+		{name: "service", desc: "Initializing services", fn: p.initServices},
+		{name: "dir", desc: "Creating desired dirs", fn: p.ensureDirs},
+		{name: "line", desc: "Ensuring lines in files", fn: p.ensureLines},
+		{name: "archive", desc: "Extracting archives", fn: p.extractArchive},
+		{name: "flatpak", desc: "Installing Flatpak packages", fn: p.flatpakInstall},
+		{name: "snap", desc: "Installing snap packages", fn: p.snapInstall},
+		{name: "group", desc: "Ensuring user is in desired groups", fn: p.userInGroup},
+		{name: "post", desc: "Running postflight tasks", fn: p.runPostFlightTasks, stateless: true},
+	}
+
+	provs, err := newProvisioners(all, opts.Provisioners)
 	if err != nil {
 		return p, err
 	}
 
+	switch opts.OnError {
+	case "", OnErrorContinue:
+	case OnErrorStop:
+		provs.stopOnError = true
+	default:
+		return p, fmt.Errorf("unknown on error policy %s, expected %s or %s", opts.OnError, OnErrorContinue,
+			OnErrorStop)
+	}
+
+	provs.tags = newTagFilter(opts.Tags, opts.SkipTags)
 	p.provisioners = provs
 	return p, nil
 }
 
-func (p Provisioner) Apply() error {
-	err := evalFacts(p.Config)
+// Apply ensures the desired state of all selected provisioners and returns a report of the outcome of each resource.
+func (p Provisioner) Apply() (Report, error) {
+	report := newReport()
+	lock, err := acquireLock(p.opts.Wait)
 	if err != nil {
-		return err
+		report.finish(err)
+		return report, err
 	}
+	defer func() {
+		releaseErr := lock.release()
+		if releaseErr != nil {
+			internal.Logger.Error().Err(releaseErr).Msg("Error releasing lock")
+		}
+	}()
 
-	return p.provisioners.apply()
-}
+	err = evalFacts(p.Config)
+	if err != nil {
+		report.finish(err)
+		return report, err
+	}
+
+	st, err := loadState()
+	if err != nil {
+		report.finish(err)
+		return report, err
+	}
 
-func (p Provisioner) AddOSRepos() error {
-	internal.Logger.Info().Msg("Adding OS repos")
+	report, err = p.provisioners.apply(p.Config.Settings, st, p.opts.Prune)
+	saveErr := st.save()
+	if saveErr != nil {
+		internal.Logger.Error().Err(saveErr).Str("file", st.file).Msg("Error saving state")
+	}
 
-	return addRepos(p.Config)
+	return report, errors.Join(err, saveErr)
 }
 
-func (p Provisioner) ensureReleases() error {
-	internal.Logger.Info().Msg("Downloading releases")
+// Plan prints the changes Apply would make without modifying anything.
+func (p Provisioner) Plan() error {
+	err := evalFacts(p.Config)
+	if err != nil {
+		return err
+	}
 
-	if p.Config.Settings.ReleaseDir == "" {
-		return errors.New("empty release directory")
+	st, err := loadState()
+	if err != nil {
+		return err
 	}
 
-	return ensureReleases(p.Config)
+	return p.provisioners.plan(p.Config.Settings, st, p.opts.Prune)
 }
 
-func (p Provisioner) runPreflightTasks() error {
-	internal.Logger.Info().Msg("Running preflight tasks")
-
-	return runTasks(p.Config, p.Config.PreflightTasks)
-}
+// Status prints how the machine differs from the desired state of the selected resources without modifying anything,
+// and returns the number of resources which have drifted.
+func (p Provisioner) Status() (int, error) {
+	err := evalFacts(p.Config)
+	if err != nil {
+		return 0, err
+	}
 
-func (p Provisioner) runPostFlightTasks() error {
-	internal.Logger.Info().Msg("Running postflight tasks")
+	st, err := loadState()
+	if err != nil {
+		return 0, err
+	}
 
-	return runTasks(p.Config, p.Config.PostflightTasks)
+	return p.provisioners.status(p.Config.Settings, st)
 }
 
-func (p Provisioner) installPackages() error {
-	internal.Logger.Info().Msg("Installing/removing packages")
-
-	return installPackages(p)
+func (p Provisioner) AddOSRepos() ([]resource, error) {
+	return repoResources(p.Config), nil
 }
 
-func (p Provisioner) rustInstall() error {
-	internal.Logger.Info().Msg("Installing Rust packages")
+func (p Provisioner) ensureReleases() ([]resource, error) {
+	if len(p.Config.Releases) == 0 && len(p.Config.GithubReleases) == 0 && len(p.Config.GitlabReleases) == 0 &&
+		len(p.Config.GiteaReleases) == 0 {
+		return nil, nil
+	}
+	if p.Config.Settings.ReleaseDir == "" {
+		return nil, errors.New("empty release directory")
+	}
 
-	return cargoInstallPkgs(p.Config)
+	s := p.Config.Settings
+	// The gh CLI is only used for fetching releases and looking up versions, which don't happen during validation.
+	if !p.validating {
+		s.Internal.GhAvailable = ghCliAvailable(s)
+	}
+	return releaseResources(p.Config, s)
 }
 
-func (p Provisioner) githubUserKey() error {
-	internal.Logger.Info().Msg("Adding GitHub user keys")
-
-	return addGithubUserKeys(p.Config)
+func (p Provisioner) runPreflightTasks() ([]resource, error) {
+	return taskResources(p.Config, p.Config.PreflightTasks), nil
 }
 
-func (p Provisioner) goInstall() error {
-	internal.Logger.Info().Msg("Installing Go packages")
-
-	return goInstallPkgs(p.Config)
+func (p Provisioner) runPostFlightTasks() ([]resource, error) {
+	return taskResources(p.Config, p.Config.PostflightTasks), nil
 }
 
-func (p Provisioner) acceptHostKeys() error {
-	internal.Logger.Info().Msg("Adding known hosts")
-
-	return acceptHostKeys(p.Config)
+func (p Provisioner) installPackages() ([]resource, error) {
+	return packageResources(p), nil
 }
 
-func (p Provisioner) initServices() error {
-	internal.Logger.Info().Msg("Initializing services")
-
-	return initServices(p.Config)
+func (p Provisioner) rustInstall() ([]resource, error) {
+	return cargoResources(p.Config), nil
 }
 
-func (p Provisioner) pythonInstall() error {
-	internal.Logger.Info().Msg("Installing Python packages")
-
-	return pythonInstallPkgs(p.Config)
+func (p Provisioner) githubUserKey() ([]resource, error) {
+	return githubUserKeyResources(p.Config), nil
 }
 
-func (p Provisioner) runTasks() error {
-	internal.Logger.Info().Msg("Running tasks")
-
-	return runTasks(p.Config, p.Config.Tasks)
+func (p Provisioner) goInstall() ([]resource, error) {
+	return goResources(p.Config), nil
 }
 
-func (p Provisioner) applyTemplates() error {
-	internal.Logger.Info().Msg("Applying templates")
-
-	return applyTemplates(p.Config)
+func (p Provisioner) acceptHostKeys() ([]resource, error) {
+	return hostKeyResources(p.Config), nil
 }
 
-func (p Provisioner) ensureDirs() error {
-	internal.Logger.Info().Msg("Creating desired dirs")
-
-	return ensureDirs(p.Config)
+func (p Provisioner) initServices() ([]resource, error) {
+	return serviceResources(p.Config), nil
 }
 
-func (p Provisioner) ensureLines() error {
-	internal.Logger.Info().Msg("Ensuring lines in files")
-
-	return ensureLines(p.Config)
+func (p Provisioner) pythonInstall() ([]resource, error) {
+	return pythonResources(p.Config), nil
 }
 
-func (p Provisioner) extractArchive() error {
-	internal.Logger.Info().Msg("Extracting archives")
-
-	return extractArchives(p.Config)
+func (p Provisioner) runTasks() ([]resource, error) {
+	return taskResources(p.Config, p.Config.Tasks), nil
 }
 
-func (p Provisioner) flatpakInstall() error {
-	internal.Logger.Info().Msg("Installing Flatpak packages")
-
-	_, err := common.Which("flatpak")
-	if err != nil {
-		internal.Logger.Trace().Msg("Flatpak is not installed")
-		return nil
-	}
-
-	return flatpakInstall(p.Config)
+func (p Provisioner) applyTemplates() ([]resource, error) {
+	return templateResources(p.Config), nil
 }
 
-func (p Provisioner) snapInstall() error {
-	internal.Logger.Info().Msg("Installing snap packages")
+func (p Provisioner) ensureDirs() ([]resource, error) {
+	return dirResources(p.Config), nil
+}
 
-	return snapInstall(p.Config)
+func (p Provisioner) ensureLines() ([]resource, error) {
+	return lineResources(p.Config), nil
 }
 
-func (p Provisioner) sshClone() error {
-	internal.Logger.Info().Msg("Cloning repos via SSH")
+func (p Provisioner) extractArchive() ([]resource, error) {
+	return archiveResources(p.Config), nil
+}
 
-	return sshClone(p.Config)
+func (p Provisioner) flatpakInstall() ([]resource, error) {
+	return flatpakResources(p.Config), nil
 }
 
-func (p Provisioner) uvTools() error {
-	internal.Logger.Info().Msg("Installing uv tools")
+func (p Provisioner) snapInstall() ([]resource, error) {
+	return snapResources(p.Config), nil
+}
 
-	return uvInstallTools(p.Config)
+func (p Provisioner) sshClone() ([]resource, error) {
+	return cloneResources(p.Config), nil
 }
 
-func (p Provisioner) userInGroup() error {
-	internal.Logger.Info().Msg("Ensuring user is in desired groups")
+func (p Provisioner) uvTools() ([]resource, error) {
+	return uvResources(p.Config), nil
+}
 
-	return userInGroup(p.Config)
+func (p Provisioner) userInGroup() ([]resource, error) {
+	return userInGroupResources(p.Config), nil
 }
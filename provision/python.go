@@ -1,9 +1,10 @@
 package provision
 
 import (
-	"errors"
 	"fmt"
+	"os"
 	"path"
+	"strings"
 
 	marecmd "github.com/femnad/mare/cmd"
 
@@ -13,25 +14,31 @@ import (
 	"github.com/femnad/fup/precheck/unless"
 )
 
-func pythonInstall(pkg entity.PythonPkg, cfg entity.Config) error {
-	name := pkg.Name()
+func getVenvDir(pkg entity.PythonPkg, cfg entity.Config) string {
 	baseDir := internal.ExpandUser(cfg.Settings.VirtualEnvDir)
-	venvDir := path.Join(baseDir, name)
-	venvPip := path.Join(venvDir, "bin", "pip")
+	return path.Join(baseDir, pkg.Name())
+}
 
-	if pkg.Library {
-		pkg.Unless = unless.Unless{
-			Cmd: fmt.Sprintf("%s show %s", venvPip, name),
-		}
-		if pkg.GetVersion() != "" {
-			pkg.Unless.Post = `head 1 | splitBy ": " -1`
-		}
+func withLibraryUnless(pkg entity.PythonPkg, cfg entity.Config) entity.PythonPkg {
+	if !pkg.Library {
+		return pkg
 	}
 
-	if unless.ShouldSkip(pkg, cfg.Settings) {
-		internal.Logger.Trace().Str("package", name).Msg("Skipping pip install")
-		return nil
+	venvPip := path.Join(getVenvDir(pkg, cfg), "bin", "pip")
+	pkg.Unless = unless.Unless{
+		Cmd: fmt.Sprintf("%s show %s", venvPip, pkg.Name()),
 	}
+	if pkg.GetVersion() != "" {
+		pkg.Unless.Post = `head 1 | splitBy ": " -1`
+	}
+
+	return pkg
+}
+
+func pythonInstall(pkg entity.PythonPkg, cfg entity.Config) error {
+	name := pkg.Name()
+	venvDir := getVenvDir(pkg, cfg)
+	venvPip := path.Join(venvDir, "bin", "pip")
 
 	internal.Logger.Debug().Str("package", name).Msg("Installing Python package")
 
@@ -63,10 +70,7 @@ func pythonInstall(pkg entity.PythonPkg, cfg entity.Config) error {
 	}
 
 	homeBin := internal.ExpandUser(cfg.Settings.BinDir)
-	if len(pkg.BinLinks) == 0 && !pkg.Library {
-		pkg.BinLinks = []string{pkg.Name()}
-	}
-	for _, link := range pkg.BinLinks {
+	for _, link := range pythonBinLinks(pkg) {
 		linkName := path.Join(homeBin, link)
 		linkTarget := path.Join(venvDir, "bin", link)
 		err = common.Symlink(linkName, linkTarget)
@@ -80,12 +84,86 @@ func pythonInstall(pkg entity.PythonPkg, cfg entity.Config) error {
 	return nil
 }
 
-func pythonInstallPkgs(cfg entity.Config) error {
-	var pyErr []error
+// pythonBinLinks returns the binaries of the package to link, which default to the package name unless it's a library.
+func pythonBinLinks(pkg entity.PythonPkg) []string {
+	if len(pkg.BinLinks) == 0 && !pkg.Library {
+		return []string{pkg.Name()}
+	}
+
+	return pkg.BinLinks
+}
+
+func pythonArtifacts(pkg entity.PythonPkg, cfg entity.Config) []artifact {
+	homeBin := internal.ExpandUser(cfg.Settings.BinDir)
+	var artifacts []artifact
+	for _, link := range pythonBinLinks(pkg) {
+		artifacts = append(artifacts, artifact{Type: artifactSymlink, Path: path.Join(homeBin, link)})
+	}
+
+	return append(artifacts, artifact{Type: artifactDir, Path: getVenvDir(pkg, cfg)})
+}
+
+// checkPythonPkg reports if the virtualenv of the package is missing, if its binaries aren't linked into the virtualenv
+// and if the installed version of the package isn't the desired one.
+func checkPythonPkg(pkg entity.PythonPkg, cfg entity.Config) ([]string, error) {
+	venvDir := getVenvDir(pkg, cfg)
+	if _, err := os.Stat(venvDir); err != nil {
+		return []string{fmt.Sprintf("missing virtualenv %s", venvDir)}, nil
+	}
+
+	var drift []string
+	homeBin := internal.ExpandUser(cfg.Settings.BinDir)
+	for _, link := range pythonBinLinks(pkg) {
+		linkName := path.Join(homeBin, link)
+		linkTarget := path.Join(venvDir, "bin", link)
+		target, err := os.Readlink(linkName)
+		if err != nil || target != linkTarget {
+			drift = append(drift, fmt.Sprintf("symlink %s doesn't point to %s", linkName, linkTarget))
+		}
+	}
+
+	desired, err := pkg.LookupVersion(cfg.Settings)
+	if err != nil || desired == "" {
+		return drift, err
+	}
+
+	venvPip := path.Join(venvDir, "bin", "pip")
+	out, err := marecmd.Run(marecmd.Input{Command: fmt.Sprintf("%s show %s", venvPip, pkg.Name())})
+	if err != nil {
+		return append(drift, fmt.Sprintf("package %s not installed in %s", pkg.Name(), venvDir)), nil
+	}
+	for _, line := range strings.Split(out.Stdout, "\n") {
+		installed, found := strings.CutPrefix(line, "Version: ")
+		if found && installed != desired {
+			drift = append(drift, fmt.Sprintf("installed version %s, want %s", installed, desired))
+		}
+	}
+
+	return drift, nil
+}
+
+func pythonResources(cfg entity.Config) []resource {
+	var resources []resource
 	for _, pkg := range cfg.Python {
-		err := pythonInstall(pkg, cfg)
-		pyErr = append(pyErr, err)
+		pkg = withLibraryUnless(pkg, cfg)
+		resources = append(resources, resource{
+			name:   pkg.Name(),
+			meta:   pkg.Meta,
+			unless: pkg,
+			apply: func() (bool, error) {
+				return true, pythonInstall(pkg, cfg)
+			},
+			artifacts: func() []artifact {
+				return pythonArtifacts(pkg, cfg)
+			},
+			plan: func() ([]string, error) {
+				return []string{fmt.Sprintf("pip install into %s", getVenvDir(pkg, cfg))}, nil
+			},
+			check: func(resourceState) ([]string, error) {
+				return checkPythonPkg(pkg, cfg)
+			},
+		})
 	}
 
-	return errors.Join(pyErr...)
+	return resources
 }
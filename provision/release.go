@@ -13,7 +13,9 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/gabriel-vasile/mimetype"
@@ -22,8 +24,6 @@ import (
 	"github.com/femnad/fup/common"
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
-	"github.com/femnad/fup/precheck/unless"
-	"github.com/femnad/fup/precheck/when"
 	"github.com/femnad/fup/remote"
 	"github.com/femnad/fup/settings"
 	"github.com/femnad/mare"
@@ -31,11 +31,10 @@ import (
 )
 
 const (
-	bufferSize         = 8192
 	bzipMimeType       = "application/x-bzip2"
 	dirMode            = 0755
 	executableMimeType = "application/x-executable"
-	githubReleaseRegex = "https://github.com/[a-zA-Z_-]+/[a-zA-Z_-]+/releases/download/[v0-9.]+/[a-zA-Z0-9_.-]+"
+	githubReleaseRegex = "^https://github.com/[a-zA-Z0-9_.-]+/[a-zA-Z0-9_.-]+/releases/download/"
 	gzipMimeType       = "application/gzip"
 	rootUser           = "root"
 	setuidExecutable   = 0o4755
@@ -82,32 +81,60 @@ func (r ReleaseInfo) GetTarget() string {
 	return r.relTarget
 }
 
-func downloadRelease(release entity.Release, s settings.Settings) (string, error) {
+func downloadRelease(release entity.Release, s settings.Settings) (remote.CachedFile, error) {
 	releaseURL, err := release.ExpandURL(s)
 	if err != nil {
-		return "", err
+		return remote.CachedFile{}, err
 	}
 
 	if releaseURL == "" {
-		return "", fmt.Errorf("no URL given for release %v", release)
+		return remote.CachedFile{}, fmt.Errorf("no URL given for release %v", release)
 	}
 	internal.Logger.Debug().Str("name", release.Name()).Str("url", releaseURL).Msg("Downloading release")
 
-	response, err := remote.ReadResponseBody(releaseURL)
+	sum, err := release.ExpandChecksum(s, releaseURL)
 	if err != nil {
-		return "", err
+		return remote.CachedFile{}, err
 	}
 
-	return downloadTempFile(response)
+	return remote.CachedDownload(releaseURL, sum)
 }
 
 func processDownload(release entity.Release, s settings.Settings) (info ReleaseInfo, err error) {
-	tempFile, err := downloadRelease(release, s)
+	cached, err := downloadRelease(release, s)
 	if err != nil {
 		return
 	}
 
-	fileType, err := mimetype.DetectFile(tempFile)
+	return extractDownload(release, s, cached)
+}
+
+// targetLocks serializes concurrent extractions into the same target dir, such as releases which can't be extracted
+// into their own dir.
+var targetLocks = struct {
+	sync.Mutex
+	dirs map[string]*sync.Mutex
+}{dirs: make(map[string]*sync.Mutex)}
+
+func lockTarget(target string) func() {
+	targetLocks.Lock()
+	mu, ok := targetLocks.dirs[target]
+	if !ok {
+		mu = &sync.Mutex{}
+		targetLocks.dirs[target] = mu
+	}
+	targetLocks.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// extractDownload extracts the downloaded file of the release into the release dir.
+func extractDownload(release entity.Release, s settings.Settings, cached remote.CachedFile) (info ReleaseInfo,
+	err error) {
+	downloaded := cached.Path
+
+	fileType, err := mimetype.DetectFile(downloaded)
 	if err != nil {
 		return
 	}
@@ -119,7 +146,7 @@ func processDownload(release entity.Release, s settings.Settings) (info ReleaseI
 
 	}
 	hint := extractionHint{
-		file:     tempFile,
+		file:     downloaded,
 		fileType: fileType.String(),
 		target:   dirName,
 	}
@@ -132,6 +159,8 @@ func processDownload(release entity.Release, s settings.Settings) (info ReleaseI
 	if err != nil {
 		return
 	}
+	unlock := lockTarget(absTarget)
+	defer unlock()
 
 	if release.Cleanup {
 		internal.Logger.Trace().Str("name", release.Name()).Str("target", absTarget).Msg(
@@ -172,11 +201,6 @@ func processDownload(release entity.Release, s settings.Settings) (info ReleaseI
 		}
 	}
 
-	err = os.Remove(tempFile)
-	if err != nil {
-		return
-	}
-
 	info.absTarget = absTarget
 	return
 }
@@ -269,26 +293,6 @@ func unzipFile(f *zip.File, outputPath string) error {
 	return nil
 }
 
-func downloadTempFile(response remote.Response) (string, error) {
-	tempFile, err := os.CreateTemp("/tmp", "*")
-	if err != nil {
-		return "", err
-	}
-	err = tempFile.Close()
-	if err != nil {
-		return "", err
-	}
-
-	tempFilePath := tempFile.Name()
-
-	err = download(response.Body, tempFilePath)
-	if err != nil {
-		return "", err
-	}
-
-	return tempFilePath, nil
-}
-
 func commonPrefix(names []string) string {
 	if len(names) == 0 {
 		return ""
@@ -622,43 +626,6 @@ func Extract(archive entity.Release, s settings.Settings) (ReleaseInfo, error) {
 	return processDownload(archive, s)
 }
 
-func download(closer io.ReadCloser, target string) error {
-	f, err := os.Create(target)
-	if err != nil {
-		return fmt.Errorf("error creating target %s: %w", target, err)
-	}
-
-	for {
-		buf := make([]byte, bufferSize)
-
-		readBytes, readErr := closer.Read(buf)
-		if readErr != nil && !errors.Is(readErr, io.EOF) {
-			return readErr
-		}
-
-		_, writeErr := f.Write(buf[:readBytes])
-		if writeErr != nil {
-			return err
-		}
-		if errors.Is(readErr, io.EOF) {
-			break
-		}
-	}
-
-	err = closer.Close()
-	if err != nil {
-		return err
-	}
-
-	err = f.Close()
-	if err != nil {
-		return err
-	}
-
-	return nil
-
-}
-
 func guessArchiveName(releaseUrl string) (string, error) {
 	pattern := regexp.MustCompile(githubReleaseRegex)
 	if !pattern.MatchString(releaseUrl) {
@@ -697,109 +664,268 @@ func performExecutions(eCtx executionCtx, spec entity.ExecuteSpec) error {
 	return nil
 }
 
-func ensureRelease(release entity.Release, s settings.Settings) error {
-	releaseURL, err := release.ExpandURL(s)
-	if err != nil {
-		return err
-	}
+// releaseRun holds the state of a release across the fetch and apply steps of its resource.
+type releaseRun struct {
+	release   entity.Release
+	s         settings.Settings
+	eCtx      executionCtx
+	cached    remote.CachedFile
+	extracted bool
+	info      ReleaseInfo
+	created   []artifact
+}
 
-	if !when.ShouldRun(release) {
-		internal.Logger.Trace().Str("url", releaseURL).Str("when", release.When).Msg("Skipping download")
-		return nil
+func newReleaseRun(release entity.Release, s settings.Settings) *releaseRun {
+	version := release.ConfiguredVersion(s)
+	return &releaseRun{release: release, s: s, eCtx: executionCtx{s: s, version: version}}
+}
+
+// artifacts returns the dir and symlinks of the release, only known if the release was extracted in this run.
+func (r *releaseRun) artifacts() []artifact {
+	// Remove symlinks before the dir they point into.
+	var artifacts []artifact
+	for i := len(r.created) - 1; i >= 0; i-- {
+		artifacts = append(artifacts, r.created[i])
 	}
 
-	if release.Name() == "" {
-		var name string
-		name, err = guessArchiveName(releaseURL)
-		if err != nil {
-			return err
-		}
-		release.Ref = name
+	return artifacts
+}
+
+// fetch downloads and extracts the release, concurrently with other releases. Releases with commands to execute
+// before them are only downloaded, so that they are extracted after running their commands.
+func (r *releaseRun) fetch() error {
+	releaseURL, err := r.release.ExpandURL(r.s)
+	if err != nil {
+		return err
 	}
 
-	if unless.ShouldSkip(release, s) {
-		internal.Logger.Trace().Str("url", releaseURL).Str("unless", release.Unless.String()).Msg("Skipping download")
-		return nil
+	r.cached, err = downloadRelease(r.release, r.s)
+	if err != nil {
+		internal.Logger.Error().Err(err).Str("name", r.release.Name()).Str("url", releaseURL).Msg(
+			"Error downloading release")
+		return err
 	}
 
-	version := release.Version
-	if version == "" {
-		version = s.Versions[release.Name()]
+	if len(r.release.ExecuteBefore.Cmd) > 0 {
+		return nil
 	}
+	return r.extract()
+}
 
-	eCtx := executionCtx{s: s, version: version}
-	err = performExecutions(eCtx, release.ExecuteBefore)
+func (r *releaseRun) extract() error {
+	var err error
+	r.info, err = extractDownload(r.release, r.s, r.cached)
 	if err != nil {
+		internal.Logger.Error().Err(err).Str("name", r.release.Name()).Str("file", r.cached.Path).Msg(
+			"Error extracting release")
 		return err
 	}
 
-	info, err := Extract(release, s)
+	r.extracted = true
+	internal.Logger.Trace().Str("name", r.release.Name()).Str("target", r.info.absTarget).Msg("Extracted release")
+	return nil
+}
+
+// install runs the commands to execute before the release, extracts the release if it's not already extracted and
+// links it, in the order of the releases.
+func (r *releaseRun) install() error {
+	err := performExecutions(r.eCtx, r.release.ExecuteBefore)
 	if err != nil {
-		internal.Logger.Error().Err(err).Str("url", releaseURL).Msg("Error downloading release")
 		return err
 	}
 
+	if !r.extracted {
+		err = r.extract()
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.link()
+}
+
+func (r *releaseRun) link() error {
+	info := r.info
 	target := info.absTarget
 	if info.targetOverride != "" {
 		target, _ = path.Split(target)
 		target = path.Join(target, info.targetOverride)
 	}
-	for _, symlink := range release.ExpandSymlinks(info.execCandidate) {
-		err = createSymlink(symlink, target, s.GetBinPath())
+	// Never record the release dir itself, in case the release couldn't be extracted into its own dir.
+	if target != internal.ExpandUser(r.s.ReleaseDir) {
+		r.created = append(r.created, artifact{Type: artifactDir, Path: target})
+	}
+
+	for _, symlink := range r.release.ExpandSymlinks(info.execCandidate) {
+		err := createSymlink(symlink, target, r.s.GetBinPath())
 		if err != nil {
-			internal.Logger.Error().Err(err).Str("url", releaseURL).Msg("Error creating symlink")
+			internal.Logger.Error().Err(err).Str("name", r.release.Name()).Msg("Error creating symlink")
 			return err
 		}
+
+		symlinkName, _ := getSymlinkPaths(symlink, target, r.s.GetBinPath())
+		r.created = append(r.created, artifact{Type: artifactSymlink, Path: symlinkName})
 	}
 
-	eCtx.releaseTarget = info.GetTarget()
-	return performExecutions(eCtx, release.ExecuteAfter)
+	r.eCtx.releaseTarget = info.GetTarget()
+	return performExecutions(r.eCtx, r.release.ExecuteAfter)
 }
 
-func processGithubReleases(githubReleases []entity.GithubRelease) ([]entity.Release, error) {
-	var releases []entity.Release
-	for _, githubRelease := range githubReleases {
-		if githubRelease.Ref == "" {
-			return releases, fmt.Errorf("no ref specified for GitHub release: %+v", githubRelease)
+func planRelease(release entity.Release, s settings.Settings) ([]string, error) {
+	releaseURL, err := release.ExpandURL(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for _, cmd := range release.ExecuteBefore.Cmd {
+		changes = append(changes, fmt.Sprintf("run %s", cmd))
+	}
+	changes = append(changes, fmt.Sprintf("download %s into %s", releaseURL, s.ReleaseDir))
+	for _, cmd := range release.ExecuteAfter.Cmd {
+		changes = append(changes, fmt.Sprintf("run %s", cmd))
+	}
+
+	return changes, nil
+}
+
+// checkRelease reports if the release hasn't been installed, if its dir is missing, if its symlinks point outside of its
+// dir and if the installed version isn't the desired one.
+func checkRelease(release entity.Release, s settings.Settings, recorded resourceState) ([]string, error) {
+	if len(recorded.Artifacts) == 0 {
+		return []string{fmt.Sprintf("not installed into %s", s.ReleaseDir)}, nil
+	}
+
+	var dir string
+	for _, a := range recorded.Artifacts {
+		if a.Type == artifactDir {
+			dir = a.Path
 		}
+	}
 
-		githubRef := githubRelease.Ref
-		releaseUrl := fmt.Sprintf("https://github.com/%s/releases/download/%s", githubRef,
-			githubRelease.Url)
+	var drift []string
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			drift = append(drift, fmt.Sprintf("missing dir %s", dir))
+		}
+	}
+	for _, a := range recorded.Artifacts {
+		if a.Type != artifactSymlink || dir == "" {
+			continue
+		}
+		// Missing symlinks are reported as broken links.
+		target, err := os.Readlink(a.Path)
+		if err != nil {
+			continue
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(a.Path), target)
+		}
+		if target != dir && !strings.HasPrefix(target, dir+"/") {
+			drift = append(drift, fmt.Sprintf("symlink %s points to %s outside of %s", a.Path, target, dir))
+		}
+	}
 
-		ref := githubRelease.ExecName
-		if ref == "" {
-			refTokens := strings.Split(githubRef, "/")
-			if len(refTokens) != 2 {
-				return releases, fmt.Errorf("unexpected release name %s", githubRef)
-			}
-			ref = refTokens[1]
-		}
-
-		release := entity.Release{
-			Cleanup:       githubRelease.Cleanup,
-			DontLink:      githubRelease.DontLink,
-			DontUpdate:    githubRelease.DontUpdate,
-			ExecuteAfter:  githubRelease.ExecuteAfter,
-			ExecuteBefore: githubRelease.ExecuteBefore,
-			NamedLink:     githubRelease.NamedLink,
-			Ref:           ref,
-			Symlink:       githubRelease.Symlink,
-			Target:        githubRelease.Target,
-			Unless:        githubRelease.Unless,
-			Url:           releaseUrl,
-			Version:       githubRelease.Version,
-			VersionLookup: githubRelease.VersionLookup,
-			When:          githubRelease.When,
+	versionDiff, err := versionDrift(release, s)
+	return append(drift, versionDiff...), err
+}
+
+// processForgeRelease converts a release of a repo on a forge to a release with the URLs for the forge, named after the
+// repo if there's no exec name.
+func processForgeRelease(source entity.ReleaseSource, asset entity.ReleaseAsset, execName string,
+	forgeRelease entity.Release) (entity.Release, error) {
+	if source.Repo == "" {
+		return forgeRelease, fmt.Errorf("no ref specified for %s release: %+v", source.Forge, forgeRelease)
+	}
+
+	var releaseUrl string
+	if forgeRelease.Url != "" {
+		releaseUrl = source.DownloadURL(forgeRelease.Url)
+	}
+	var archURL map[string]string
+	for arch, url := range forgeRelease.ArchURL {
+		if archURL == nil {
+			archURL = make(map[string]string)
+		}
+		archURL[arch] = source.DownloadURL(url)
+	}
+
+	ref := execName
+	if ref == "" {
+		refTokens := strings.Split(source.Repo, "/")
+		if len(refTokens) < 2 {
+			return forgeRelease, fmt.Errorf("unexpected release name %s", source.Repo)
+		}
+		ref = refTokens[len(refTokens)-1]
+	}
+
+	// The version lookup strategies of GitLab and Gitea need the instance URL, which can't always be determined from
+	// the release URL.
+	versionLookup := forgeRelease.VersionLookup
+	if versionLookup.Repo == "" {
+		versionLookup.BaseURL = source.BaseURL
+		versionLookup.Repo = source.Repo
+	}
+
+	release := entity.Release{
+		Meta:          forgeRelease.Meta,
+		Integrity:     forgeRelease.Integrity,
+		ArchURL:       archURL,
+		AssetMatch:    asset.Match(source),
+		Cleanup:       forgeRelease.Cleanup,
+		DontLink:      forgeRelease.DontLink,
+		DontUpdate:    forgeRelease.DontUpdate,
+		ExecuteAfter:  forgeRelease.ExecuteAfter,
+		ExecuteBefore: forgeRelease.ExecuteBefore,
+		NamedLink:     forgeRelease.NamedLink,
+		Ref:           ref,
+		SignatureURL:  forgeRelease.SignatureURL,
+		SigningKey:    forgeRelease.SigningKey,
+		Symlink:       forgeRelease.Symlink,
+		Target:        forgeRelease.Target,
+		Unless:        forgeRelease.Unless,
+		Url:           releaseUrl,
+		Version:       forgeRelease.Version,
+		VersionLookup: versionLookup,
+		When:          forgeRelease.When,
+	}
+
+	return release, nil
+}
+
+// processForgeReleases converts the GitHub, GitLab and Gitea releases of the config.
+func processForgeReleases(config entity.Config) ([]entity.Release, error) {
+	var releases []entity.Release
+	add := func(source entity.ReleaseSource, asset entity.ReleaseAsset, execName string, r entity.Release) error {
+		release, err := processForgeRelease(source, asset, execName, r)
+		if err != nil {
+			return err
 		}
 		releases = append(releases, release)
+		return nil
+	}
+
+	for _, release := range config.GithubReleases {
+		if err := add(release.Source(), release.ReleaseAsset, release.ExecName, release.Release); err != nil {
+			return releases, err
+		}
+	}
+	for _, release := range config.GitlabReleases {
+		if err := add(release.Source(), release.ReleaseAsset, release.ExecName, release.Release); err != nil {
+			return releases, err
+		}
+	}
+	for _, release := range config.GiteaReleases {
+		if err := add(release.Source(), release.ReleaseAsset, release.ExecName, release.Release); err != nil {
+			return releases, err
+		}
 	}
 
 	return releases, nil
 }
 
 func ghCliAvailable(s settings.Settings) bool {
-	if !s.UseGHClient {
+	if !s.GetUseGHClient() {
 		return false
 	}
 
@@ -807,25 +933,64 @@ func ghCliAvailable(s settings.Settings) bool {
 	return err == nil
 }
 
-func ensureReleases(config entity.Config) error {
-	var releaseErrs []error
-	config.Settings.Internal = settings.InternalSettings{GhAvailable: ghCliAvailable(config.Settings)}
-
-	releases := config.Releases
-	processedReleases, err := processGithubReleases(config.GithubReleases)
+// configReleases returns the releases and forge releases of the config, with names guessed for unnamed releases.
+func configReleases(config entity.Config) ([]entity.Release, error) {
+	releases := slices.Clone(config.Releases)
+	processedReleases, err := processForgeReleases(config)
 	if err == nil {
 		releases = append(releases, processedReleases...)
-	} else {
-		releaseErrs = append(releaseErrs, err)
 	}
 
+	var named []entity.Release
 	for _, release := range releases {
-		err = ensureRelease(release, config.Settings)
-		if err != nil {
-			internal.Logger.Error().Err(err).Str("name", release.Name()).Msg("Error ensuring release")
+		release, archErr := release.ForArch()
+		if archErr != nil {
+			err = errors.Join(err, archErr)
+			continue
+		}
+		if release.Name() == "" {
+			name, guessErr := guessArchiveName(release.Url)
+			if guessErr != nil {
+				err = errors.Join(err, guessErr)
+				continue
+			}
+			release.Ref = name
+		}
+		named = append(named, release)
+	}
+
+	return named, err
+}
+
+func releaseResources(config entity.Config, s settings.Settings) ([]resource, error) {
+
+	releases, err := configReleases(config)
+	var resources []resource
+	for _, release := range releases {
+		name := release.Name()
+		if name == "" {
+			name = release.Url
 		}
-		releaseErrs = append(releaseErrs, err)
+
+		run := newReleaseRun(release, s)
+		resources = append(resources, resource{
+			name:      name,
+			meta:      release.Meta,
+			when:      release,
+			unless:    release,
+			fetch:     run.fetch,
+			artifacts: run.artifacts,
+			apply: func() (bool, error) {
+				return true, run.install()
+			},
+			plan: func() ([]string, error) {
+				return planRelease(release, s)
+			},
+			check: func(recorded resourceState) ([]string, error) {
+				return checkRelease(release, s, recorded)
+			},
+		})
 	}
 
-	return errors.Join(releaseErrs...)
+	return resources, err
 }
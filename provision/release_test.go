@@ -1,12 +1,20 @@
 package provision
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/settings"
 )
 
 type mockFileInfo struct {
@@ -225,3 +233,84 @@ func Test_getReleaseInfo(t *testing.T) {
 		})
 	}
 }
+
+func releaseArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	content := []byte("#!/bin/sh\n")
+	for _, hdr := range []*tar.Header{
+		{Name: "foo-1.0.0/", Mode: 0o755, Typeflag: tar.TypeDir},
+		{Name: "foo-1.0.0/foo", Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write(content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func Test_releaseRunFetch(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	archive := releaseArchive(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	binDir := t.TempDir()
+	marker := path.Join(t.TempDir(), "before")
+	tests := []struct {
+		name          string
+		executeBefore entity.ExecuteSpec
+		wantExtracted bool
+	}{
+		{name: "Extracted when fetched", wantExtracted: true},
+		{name: "Extracted after execute before", executeBefore: entity.ExecuteSpec{Cmd: []string{"touch " + marker}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			releaseDir := t.TempDir()
+			s := settings.Settings{BinDir: binDir, ReleaseDir: releaseDir}
+			release := entity.Release{
+				Ref:           "foo",
+				Url:           server.URL + "/foo.tar.gz",
+				Version:       "1.0.0",
+				ExecuteBefore: tt.executeBefore,
+			}
+
+			run := newReleaseRun(release, s)
+			if err := run.fetch(); err != nil {
+				t.Fatalf("fetch() error = %v", err)
+			}
+			target := path.Join(releaseDir, "foo-1.0.0", "foo")
+			if _, err := os.Stat(target); (err == nil) != tt.wantExtracted {
+				t.Errorf("fetch() extracted = %v, want %v", err == nil, tt.wantExtracted)
+			}
+
+			if err := run.install(); err != nil {
+				t.Fatalf("install() error = %v", err)
+			}
+			if _, err := os.Stat(target); err != nil {
+				t.Errorf("install() error extracting release: %v", err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("install() error running commands before release: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package provision
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/femnad/fup/internal"
+)
+
+const (
+	reportFilePerm = 0o644
+	// Bump when fields are renamed or removed from the report.
+	reportVersion = 1
+)
+
+type Outcome string
+
+const (
+	OutcomeChanged         Outcome = "changed"
+	OutcomeUnchanged       Outcome = "unchanged"
+	OutcomeSkippedByWhen   Outcome = "skipped-by-when"
+	OutcomeSkippedByUnless Outcome = "skipped-by-unless"
+	OutcomeFailed          Outcome = "failed"
+	// The resource failed but its errors are ignored.
+	OutcomeFailedIgnored Outcome = "failed-ignored"
+	// The resource was not attempted because one of its dependencies failed or was not run.
+	OutcomeDependencyFailed Outcome = "dependency-failed"
+	// The resource is no longer declared and its artifacts were removed.
+	OutcomePruned Outcome = "pruned"
+	// The resource was not attempted because the run was stopped after an earlier failure.
+	OutcomeNotRun Outcome = "not-run"
+)
+
+type ResourceReport struct {
+	Provisioner string  `json:"provisioner"`
+	Name        string  `json:"name"`
+	Outcome     Outcome `json:"outcome"`
+	Error       string  `json:"error,omitempty"`
+	DurationMs  int64   `json:"duration_ms"`
+}
+
+// Report is the result of an apply run, listing the outcome of each resource in the order they were processed.
+type Report struct {
+	Version    int              `json:"version"`
+	StartedAt  time.Time        `json:"started_at"`
+	DurationMs int64            `json:"duration_ms"`
+	Success    bool             `json:"success"`
+	Resources  []ResourceReport `json:"resources"`
+}
+
+func newReport() Report {
+	return Report{Version: reportVersion, StartedAt: time.Now().UTC(), Resources: []ResourceReport{}}
+}
+
+func (r *Report) add(res resource, outcome Outcome, err error, start time.Time) {
+	entry := ResourceReport{
+		Provisioner: res.kind,
+		Name:        res.name,
+		Outcome:     outcome,
+		DurationMs:  time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	r.Resources = append(r.Resources, entry)
+}
+
+func (r *Report) finish(err error) {
+	r.DurationMs = time.Since(r.StartedAt).Milliseconds()
+	r.Success = err == nil
+}
+
+// logSummary logs every failed resource and every resource which was never attempted.
+func (r Report) logSummary() {
+	var notAttempted []string
+	for _, res := range r.Resources {
+		id := res.Provisioner + dependencySep + res.Name
+		switch res.Outcome {
+		case OutcomeFailed:
+			internal.Logger.Error().Str("resource", id).Str("error", res.Error).Msg("Failed")
+		case OutcomeFailedIgnored:
+			internal.Logger.Warn().Str("resource", id).Str("error", res.Error).Msg("Failed with ignored errors")
+		case OutcomeDependencyFailed, OutcomeNotRun:
+			notAttempted = append(notAttempted, id)
+		}
+	}
+
+	if len(notAttempted) > 0 {
+		internal.Logger.Warn().Strs("resources", notAttempted).Msg("Not attempted")
+	}
+}
+
+// Write saves the report as indented JSON to the given path.
+func (r Report) Write(file string) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+
+	return os.WriteFile(internal.ExpandUser(file), out, reportFilePerm)
+}
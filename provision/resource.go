@@ -0,0 +1,192 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/precheck/unless"
+	"github.com/femnad/fup/precheck/when"
+	"github.com/femnad/fup/settings"
+)
+
+const (
+	hintBase = "Hint for running task"
+)
+
+// resource is a single item managed by a provisioner, such as a release, a package group or a template.
+type resource struct {
+	kind string
+	name string
+	// Number of earlier resources with the same kind and name, such as lines in the same file, to tell them apart.
+	seq  int
+	hint string
+	meta entity.Meta
+	// IDs of the resources this resource depends on, resolved from the depends_on references in meta.
+	deps   []string
+	when   when.Whenable
+	unless unless.Unlessable
+	// fetch is an optional step to run before apply, such as a download. If the provisioner allows multiple workers,
+	// it runs concurrently with the fetch steps of other resources while apply still runs in resource order.
+	fetch func() error
+	// apply ensures the desired state and reports whether anything has changed.
+	apply func() (bool, error)
+	// plan reports the changes apply would make without mutating anything.
+	plan func() ([]string, error)
+	// check reports how the machine differs from the desired state, given what was recorded for the resource on
+	// earlier runs. Resources without a check are compared using their plan, which has to reflect the machine state.
+	check func(recorded resourceState) ([]string, error)
+	// artifacts lists what the resource has created on disk, if known, so that it can be pruned once the resource is
+	// no longer declared.
+	artifacts func() []artifact
+}
+
+func (r resource) id() string {
+	id := r.kind + dependencySep + r.name
+	if r.seq > 0 {
+		id = fmt.Sprintf("%s#%d", id, r.seq+1)
+	}
+
+	return id
+}
+
+func (r resource) String() string {
+	return fmt.Sprintf("%s %s", r.kind, r.name)
+}
+
+// skipOutcome evaluates the when and unless prechecks of the resource and returns the outcome if it should be skipped.
+func (r resource) skipOutcome(s settings.Settings) (Outcome, bool) {
+	if r.when != nil && !when.ShouldRun(r.when) {
+		if r.hint != "" && (r.unless == nil || !unless.ShouldSkip(r.unless, s)) {
+			internal.Logger.Warn().Str("name", r.name).Str("task", r.hint).Msg(hintBase)
+		}
+		whenText := internal.PrettyLogStr(r.when.RunWhen())
+		internal.Logger.Trace().Str("kind", r.kind).Str("name", r.name).Str("when", whenText).Msg("Skipping")
+		return OutcomeSkippedByWhen, true
+	}
+
+	if r.unless != nil && unless.ShouldSkip(r.unless, s) {
+		internal.Logger.Trace().Str("kind", r.kind).Str("name", r.name).Msg("Skipping due to precheck")
+		return OutcomeSkippedByUnless, true
+	}
+
+	return "", false
+}
+
+// pendingChanges returns the changes apply would make for the resource, or nothing if it would be skipped.
+func (r resource) pendingChanges(s settings.Settings) ([]string, error) {
+	if _, skip := r.skipOutcome(s); skip {
+		return nil, nil
+	}
+
+	return r.plan()
+}
+
+// drift returns the differences between the desired state of the resource and the machine.
+func (r resource) drift(recorded resourceState) ([]string, error) {
+	if r.check != nil {
+		return r.check(recorded)
+	}
+
+	return r.plan()
+}
+
+// versionMatches returns true if the output of a version command contains the version, with or without a v prefix, as
+// version commands usually print more than the version such as foo 1.2.3.
+func versionMatches(output, version string) bool {
+	version = strings.TrimPrefix(version, "v")
+	for _, field := range strings.Fields(output) {
+		if strings.TrimPrefix(field, "v") == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionDrift reports the installed version of the resource per its version command if it's not the desired version.
+// Failures of default version commands aren't reported, as they only guess the name of the binary.
+func versionDrift(u unless.Unlessable, s settings.Settings) ([]string, error) {
+	if !u.KeepUpToDate() {
+		return nil, nil
+	}
+
+	desired, err := u.LookupVersion(s)
+	if err != nil || desired == "" {
+		return nil, err
+	}
+	if output := u.GetUnless().VersionOutput; output != "" {
+		desired = output
+	}
+
+	installed, err := unless.InstalledVersion(u, s)
+	if err != nil {
+		if u.GetUnless().Cmd == "" {
+			internal.Logger.Debug().Err(err).Str("name", u.Name()).Msg("Unable to determine installed version")
+			return nil, nil
+		}
+		return []string{fmt.Sprintf("unable to determine installed version: %v", err)}, nil
+	}
+	if installed == "" || versionMatches(installed, desired) {
+		return nil, nil
+	}
+
+	return []string{fmt.Sprintf("installed version %s, want %s", installed, desired)}, nil
+}
+
+func (r resource) prefetch() error {
+	if r.fetch == nil {
+		return nil
+	}
+
+	return r.fetch()
+}
+
+// fetchConcurrently runs the fetch steps of the given resources using a pool of workers. The result of the fetch step
+// of each resource is sent to the channel with the same index. No more fetch steps are started once stop is closed.
+func fetchConcurrently(resources []resource, workers int, stop <-chan struct{}) []chan error {
+	results := make([]chan error, len(resources))
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+
+	jobs := make(chan int)
+	for range workers {
+		go func() {
+			for i := range jobs {
+				results[i] <- resources[i].fetch()
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i, r := range resources {
+			if r.fetch == nil {
+				results[i] <- nil
+				continue
+			}
+
+			// Prefer stopping to starting another job when both are possible.
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			select {
+			case <-stop:
+				return
+			case jobs <- i:
+			}
+		}
+	}()
+
+	return results
+}
+
+func printChanges(r resource, changes []string) {
+	for _, change := range changes {
+		fmt.Printf("%s: %s\n", r, change)
+	}
+}
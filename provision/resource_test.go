@@ -0,0 +1,352 @@
+package provision
+
+import (
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/settings"
+)
+
+func Test_provisionersPlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []resource
+		wantErr   bool
+	}{
+		{
+			name: "No changes",
+			resources: []resource{{
+				name: "foo",
+				plan: func() ([]string, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "Changes are not applied",
+			resources: []resource{{
+				name: "foo",
+				plan: func() ([]string, error) {
+					return []string{"create"}, nil
+				},
+			}},
+		},
+		{
+			name: "Plan error",
+			resources: []resource{
+				{
+					name: "foo",
+					plan: func() ([]string, error) {
+						return nil, errors.New("foo")
+					},
+				},
+				{
+					name: "bar",
+					plan: func() ([]string, error) {
+						return []string{"create"}, nil
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var applied bool
+			for i := range tt.resources {
+				tt.resources[i].apply = func() (bool, error) {
+					applied = true
+					return true, nil
+				}
+			}
+
+			p := provisioners{
+				provMap: map[string]provisionFn{"test": {name: "test", fn: func() ([]resource, error) {
+					return tt.resources, nil
+				}}},
+				order: []string{"test"},
+			}
+
+			err := p.plan(settings.Settings{}, nil, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("plan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if applied {
+				t.Errorf("plan() applied a resource")
+			}
+		})
+	}
+}
+
+func Test_provisionersStatus(t *testing.T) {
+	noDrift := func() ([]string, error) { return nil, nil }
+	drift := func() ([]string, error) { return []string{"create"}, nil }
+	brokenLink := path.Join(t.TempDir(), "link")
+	if err := os.Symlink("missing", brokenLink); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		resources   []resource
+		stateless   bool
+		artifacts   []artifact
+		wantDrifted int
+		wantErr     bool
+	}{
+		{
+			name:      "No drift",
+			resources: []resource{{name: "foo", plan: noDrift}},
+		},
+		{
+			name:        "Drift",
+			resources:   []resource{{name: "foo", plan: drift}, {name: "bar", plan: noDrift}},
+			wantDrifted: 1,
+		},
+		{
+			name:      "Stateless provisioners are not checked",
+			resources: []resource{{name: "foo", plan: drift}},
+			stateless: true,
+		},
+		{
+			name:        "Broken link",
+			resources:   []resource{{name: "foo", plan: noDrift}},
+			artifacts:   []artifact{{Type: artifactSymlink, Path: brokenLink}},
+			wantDrifted: 1,
+		},
+		{
+			name: "Check takes precedence over plan",
+			resources: []resource{{name: "foo", plan: drift, check: func(recorded resourceState) ([]string, error) {
+				if recorded.Name != "foo" {
+					return []string{"not recorded"}, nil
+				}
+				return nil, nil
+			}}},
+		},
+		{
+			name: "Check error",
+			resources: []resource{{name: "foo", plan: func() ([]string, error) {
+				return nil, errors.New("foo")
+			}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var applied bool
+			for i := range tt.resources {
+				tt.resources[i].apply = func() (bool, error) {
+					applied = true
+					return true, nil
+				}
+			}
+
+			p := provisioners{
+				provMap: map[string]provisionFn{"test": {name: "test", stateless: tt.stateless,
+					fn: func() ([]resource, error) {
+						return tt.resources, nil
+					}}},
+				order: []string{"test"},
+			}
+			st := &state{Resources: map[string]resourceState{
+				"test:foo": {Provisioner: "test", Name: "foo", Artifacts: tt.artifacts},
+			}}
+
+			drifted, err := p.status(settings.Settings{}, st)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("status() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if drifted != tt.wantDrifted {
+				t.Errorf("status() drifted = %d, want %d", drifted, tt.wantDrifted)
+			}
+			if applied {
+				t.Errorf("status() applied a resource")
+			}
+		})
+	}
+}
+
+func Test_versionMatches(t *testing.T) {
+	tests := []struct {
+		output  string
+		version string
+		want    bool
+	}{
+		{output: "1.2.3", version: "1.2.3", want: true},
+		{output: "foo 1.2.3", version: "v1.2.3", want: true},
+		{output: "foo version v1.2.3 (abc)", version: "1.2.3", want: true},
+		{output: "foo 1.2.30", version: "1.2.3"},
+		{output: "", version: "1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			if got := versionMatches(tt.output, tt.version); got != tt.want {
+				t.Errorf("versionMatches(%q, %q) = %v, want %v", tt.output, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_provisionersApply(t *testing.T) {
+	unchanged := func() (bool, error) { return false, nil }
+	changed := func() (bool, error) { return true, nil }
+	failed := func() (bool, error) { return false, errors.New("fail") }
+
+	tests := []struct {
+		name        string
+		resources   []resource
+		enumErr     error
+		stopOnError bool
+		want        []Outcome
+		wantErr     bool
+	}{
+		{
+			name:      "Changed and unchanged",
+			resources: []resource{{name: "foo", apply: changed}, {name: "bar", apply: unchanged}},
+			want:      []Outcome{OutcomeChanged, OutcomeUnchanged},
+		},
+		{
+			name:      "Continue after failure",
+			resources: []resource{{name: "foo", apply: failed}, {name: "bar", apply: changed}},
+			want:      []Outcome{OutcomeFailed, OutcomeChanged},
+			wantErr:   true,
+		},
+		{
+			name:        "Stop after failure",
+			resources:   []resource{{name: "foo", apply: failed}, {name: "bar", apply: changed}},
+			stopOnError: true,
+			want:        []Outcome{OutcomeFailed, OutcomeNotRun},
+			wantErr:     true,
+		},
+		{
+			name: "Ignored failure",
+			resources: []resource{
+				{name: "foo", apply: failed, meta: entity.Meta{IgnoreErrors: true}},
+				{name: "bar", apply: changed, meta: entity.Meta{DependsOn: []string{"test:foo"}}},
+			},
+			stopOnError: true,
+			want:        []Outcome{OutcomeFailedIgnored, OutcomeChanged},
+		},
+		{
+			name:      "Continue after listing failure",
+			resources: []resource{{name: "foo", apply: changed}},
+			enumErr:   errors.New("fail"),
+			want:      []Outcome{OutcomeFailed, OutcomeChanged},
+			wantErr:   true,
+		},
+		{
+			name:        "Stop after listing failure",
+			resources:   []resource{{name: "foo", apply: changed}},
+			enumErr:     errors.New("fail"),
+			stopOnError: true,
+			want:        []Outcome{OutcomeFailed, OutcomeNotRun},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := provisioners{
+				provMap: map[string]provisionFn{
+					"fail": {name: "fail", fn: func() ([]resource, error) {
+						return nil, tt.enumErr
+					}},
+					"test": {name: "test", fn: func() ([]resource, error) {
+						return tt.resources, nil
+					}},
+				},
+				order:       []string{"fail", "test"},
+				stopOnError: tt.stopOnError,
+			}
+
+			report, err := p.apply(settings.Settings{}, nil, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if report.Success == tt.wantErr {
+				t.Errorf("apply() success = %v, wantErr %v", report.Success, tt.wantErr)
+			}
+
+			var got []Outcome
+			for _, r := range report.Resources {
+				got = append(got, r.Outcome)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apply() outcomes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fetchConcurrently(t *testing.T) {
+	const numResources = 10
+	const workers = 3
+
+	var mu sync.Mutex
+	var running, maxRunning int
+
+	var resources []resource
+	for i := range numResources {
+		resources = append(resources, resource{
+			fetch: func() error {
+				mu.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				if i == 3 {
+					return errors.New("fail")
+				}
+				return nil
+			},
+		})
+	}
+	resources = append(resources, resource{})
+
+	results := fetchConcurrently(resources, workers, make(chan struct{}))
+	for i, result := range results {
+		err := <-result
+		if (err != nil) != (i == 3) {
+			t.Errorf("fetchConcurrently() resource %d error = %v", i, err)
+		}
+	}
+
+	if maxRunning > workers {
+		t.Errorf("fetchConcurrently() ran %d fetches concurrently, want at most %d", maxRunning, workers)
+	}
+}
+
+func Test_fetchConcurrentlyStop(t *testing.T) {
+	var mu sync.Mutex
+	var fetched int
+	var resources []resource
+	for range 5 {
+		resources = append(resources, resource{fetch: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			fetched++
+			return nil
+		}})
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	fetchConcurrently(resources, 2, stop)
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fetched != 0 {
+		t.Errorf("fetchConcurrently() fetched %d resources after stopping, want 0", fetched)
+	}
+}
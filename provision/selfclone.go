@@ -1,22 +1,55 @@
 package provision
 
 import (
-	"errors"
+	"fmt"
+	"os"
 
 	"github.com/femnad/fup/entity"
-	"github.com/femnad/fup/settings"
 )
 
-func cloneRepos(repos []entity.Repo, s settings.Settings) error {
-	var errs []error
-	for _, repo := range repos {
-		err := entity.CloneUnderPath(repo, repo.Path, s)
-		errs = append(errs, err)
+func cloneRepo(repo entity.Repo, cfg entity.Config) (bool, error) {
+	cloneDir, err := entity.GetCloneDir(repo, repo.Path, cfg.Settings)
+	if err != nil {
+		return false, err
 	}
 
-	return errors.Join(errs...)
+	_, err = os.Stat(cloneDir)
+	exists := err == nil
+
+	err = entity.CloneUnderPath(repo, repo.Path, cfg.Settings)
+	return !exists && err == nil, err
 }
 
-func sshClone(config entity.Config) error {
-	return cloneRepos(config.Repos, config.Settings)
+func planClone(repo entity.Repo, cfg entity.Config) ([]string, error) {
+	cloneDir, err := entity.GetCloneDir(repo, repo.Path, cfg.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(cloneDir)
+	if err == nil {
+		return nil, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return []string{fmt.Sprintf("clone into %s", cloneDir)}, nil
+}
+
+func cloneResources(cfg entity.Config) []resource {
+	var resources []resource
+	for _, repo := range cfg.Repos {
+		resources = append(resources, resource{
+			name: repo.Name,
+			meta: repo.Meta,
+			apply: func() (bool, error) {
+				return cloneRepo(repo, cfg)
+			},
+			plan: func() ([]string, error) {
+				return planClone(repo, cfg)
+			},
+		})
+	}
+
+	return resources
 }
@@ -3,7 +3,6 @@ package provision
 import (
 	"bufio"
 	"bytes"
-	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -16,7 +15,6 @@ import (
 	"github.com/femnad/fup/common"
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
-	"github.com/femnad/fup/precheck/when"
 	"github.com/femnad/fup/settings"
 	marecmd "github.com/femnad/mare/cmd"
 )
@@ -280,32 +278,32 @@ func reload(s entity.Service, unitType string) error {
 	return runSystemctlCmd(c, s)
 }
 
-func persist(s entity.Service) error {
+func persist(s entity.Service) (bool, error) {
 	if s.DontTemplate {
-		return nil
+		return false, nil
 	}
 
 	restartService, err := persistUnit(s)
 	if err != nil {
-		return err
+		return restartService, err
 	}
 
 	restartTimer, err := maybePersistTimer(s)
 	if err != nil {
-		return err
+		return restartService || restartTimer, err
 	}
 
 	if s.Timer == nil {
 		if !restartService {
-			return nil
+			return false, nil
 		}
 	} else if !restartService && !restartTimer {
-		return nil
+		return false, nil
 	}
 
 	err = reload(s, "service")
 	if err != nil {
-		return err
+		return true, err
 	}
 	if s.Timer != nil {
 		err = reload(s, "timer")
@@ -313,16 +311,16 @@ func persist(s entity.Service) error {
 
 	err = maybeRestart(s, "service")
 	if err != nil {
-		return err
+		return true, err
 	}
 	if s.Timer != nil {
 		err = maybeRestart(s, "timer")
 		if err != nil {
-			return err
+			return true, err
 		}
 	}
 
-	return nil
+	return true, nil
 }
 
 func systemctlCmd(action, target, unitKind string, user bool) string {
@@ -355,33 +353,45 @@ func actuate(s entity.Service, action systemdAction, unitType string) (string, e
 	return systemctlCmd(action.actuateCmd, s.Name, unitType, !s.System), nil
 }
 
-func ensureServiceState(s entity.Service, actionStr string) error {
-	action, ok := actions[actionStr]
-	if !ok {
-		return fmt.Errorf("no such action: %s", actionStr)
-	}
-
-	serviceType := s.Type
-	if serviceType == "" {
-		serviceType = "service"
-	}
-
-	checkCmd, negated, err := check(s, action, serviceType)
+func needsAction(s entity.Service, action systemdAction) (bool, error) {
+	checkCmd, negated, err := check(s, action, getServiceType(s))
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Don't need sudo for check actions, so don't use runSystemctlCmd
 	resp, _ := marecmd.RunFmtErr(marecmd.Input{Command: checkCmd})
 	if negated && resp.Code != 0 {
-		return nil
+		return false, nil
 	} else if !negated && resp.Code == 0 {
-		return nil
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func getServiceType(s entity.Service) string {
+	if s.Type == "" {
+		return "service"
 	}
 
-	actuateCmd, err := actuate(s, action, serviceType)
+	return s.Type
+}
+
+func ensureServiceState(s entity.Service, actionStr string) (bool, error) {
+	action, ok := actions[actionStr]
+	if !ok {
+		return false, fmt.Errorf("no such action: %s", actionStr)
+	}
+
+	needed, err := needsAction(s, action)
+	if err != nil || !needed {
+		return false, err
+	}
+
+	actuateCmd, err := actuate(s, action, getServiceType(s))
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	caser := cases.Title(language.Und)
@@ -389,7 +399,7 @@ func ensureServiceState(s entity.Service, actionStr string) error {
 	internal.Logger.Debug().Str("name", s.Name).Str("state", verb).Str("type", s.Type).Msg(
 		"Ensuring service state")
 
-	return runSystemctlCmd(actuateCmd, s)
+	return true, runSystemctlCmd(actuateCmd, s)
 }
 
 func shouldEnsureState(s entity.Service) bool {
@@ -404,24 +414,28 @@ func shouldEnsureState(s entity.Service) bool {
 	return true
 }
 
-func enable(s entity.Service) error {
-	if s.DontEnable || s.Stop {
-		return nil
-	}
+func shouldEnable(s entity.Service) bool {
+	return !s.DontEnable && !s.Stop
+}
 
-	return ensureServiceState(s, "enable")
+func shouldStart(s entity.Service) bool {
+	return !s.DontStart && !s.Stop && shouldEnsureState(s)
 }
 
-func start(s entity.Service) error {
-	if s.DontStart || s.Stop {
-		return nil
+func enable(s entity.Service) (bool, error) {
+	if !shouldEnable(s) {
+		return false, nil
 	}
 
-	if shouldEnsureState(s) {
-		return ensureServiceState(s, "start")
+	return ensureServiceState(s, "enable")
+}
+
+func start(s entity.Service) (bool, error) {
+	if !shouldStart(s) {
+		return false, nil
 	}
 
-	return nil
+	return ensureServiceState(s, "start")
 }
 
 func expandService(s entity.Service, cfg entity.Config) (entity.Service, error) {
@@ -455,86 +469,180 @@ func expandService(s entity.Service, cfg entity.Config) (entity.Service, error)
 	return s, nil
 }
 
-func maybeStop(s entity.Service) error {
+func maybeStop(s entity.Service) (bool, error) {
 	if !s.Stop {
-		return nil
+		return false, nil
 	}
 
-	err := ensureServiceState(s, "stop")
+	changed, err := ensureServiceState(s, "stop")
 	if err != nil {
 		internal.Logger.Error().Err(err).Str("name", s.Name).Msg("Error ensuring service state")
-		return err
+		return changed, err
 	}
 
-	return nil
+	return changed, nil
 }
 
-func maybeDisable(s entity.Service) error {
+func maybeDisable(s entity.Service) (bool, error) {
 	if !s.Disable {
-		return nil
+		return false, nil
 	}
 
-	err := ensureServiceState(s, "disable")
+	changed, err := ensureServiceState(s, "disable")
 	if err != nil {
 		internal.Logger.Error().Err(err).Str("name", s.Name).Msg("Error disabling service")
-		return err
+		return changed, err
 	}
 
-	return nil
+	return changed, nil
 }
 
-func initService(s entity.Service, cfg entity.Config) error {
-	if !when.ShouldRun(s) {
-		whenText := internal.PrettyLogStr(s.When)
-		internal.Logger.Trace().Str("name", s.Name).Str("when", whenText).Msg(
-			"Skipping service initialization")
-		return nil
-	}
-
-	err := maybeStop(s)
+func initService(s entity.Service, cfg entity.Config) (bool, error) {
+	stopped, err := maybeStop(s)
 	if err != nil {
-		return err
+		return stopped, err
 	}
 
-	err = maybeDisable(s)
+	disabled, err := maybeDisable(s)
+	changed := stopped || disabled
 	if err != nil {
-		return err
+		return changed, err
 	}
 
 	name := s.Name
 	s, err = expandService(s, cfg)
 	if err != nil {
 		internal.Logger.Error().Str("name", name).Err(err).Msg("Error expanding service")
-		return err
+		return changed, err
 	}
 
-	err = persist(s)
+	persisted, err := persist(s)
+	changed = changed || persisted
 	if err != nil {
 		internal.Logger.Error().Str("name", name).Err(err).Msg("Error persisting service")
-		return err
+		return changed, err
 	}
 
-	err = enable(s)
+	enabled, err := enable(s)
+	changed = changed || enabled
 	if err != nil {
 		internal.Logger.Error().Str("name", name).Err(err).Msg("Error enabling service")
-		return err
+		return changed, err
 	}
 
-	err = start(s)
+	started, err := start(s)
+	changed = changed || started
 	if err != nil {
 		internal.Logger.Error().Str("name", name).Err(err).Msg("Error starting service")
-		return err
+		return changed, err
 	}
 
-	return nil
+	return changed, nil
 }
 
-func initServices(config entity.Config) error {
-	var svcErr []error
-	for _, svc := range config.Services {
-		err := initService(svc, config)
-		svcErr = append(svcErr, err)
+func planUnitFile(file string, render func(entity.Service) (string, error), s entity.Service) ([]string, error) {
+	content, err := render(s)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := internal.ContentChanged(internal.ManagedFile{Path: file, Content: content})
+	if err != nil || !changed {
+		return nil, err
+	}
+
+	return []string{fmt.Sprintf("write %s", file)}, nil
+}
+
+func planService(s entity.Service, cfg entity.Config) ([]string, error) {
+	var changes []string
+	var stateActions []string
+
+	if s.Stop {
+		stateActions = append(stateActions, "stop")
+	}
+	if s.Disable {
+		stateActions = append(stateActions, "disable")
+	}
+
+	s, err := expandService(s, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.DontTemplate && s.Unit != nil {
+		var unitChanges []string
+		unitChanges, err = planUnitFile(getServiceFilePath(s), writeTmpl, s)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, unitChanges...)
+	}
+	if !s.DontTemplate && s.Timer != nil {
+		var timerChanges []string
+		timerChanges, err = planUnitFile(getTimerFilePath(s), writeTimerTmpl, s)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, timerChanges...)
+	}
+
+	if shouldEnable(s) {
+		stateActions = append(stateActions, "enable")
+	}
+	if shouldStart(s) {
+		stateActions = append(stateActions, "start")
+	}
+
+	for _, actionStr := range stateActions {
+		var needed bool
+		needed, err = needsAction(s, actions[actionStr])
+		if err != nil {
+			return nil, err
+		}
+		if needed {
+			changes = append(changes, actionStr)
+		}
 	}
 
-	return errors.Join(svcErr...)
+	return changes, nil
+}
+
+func serviceArtifacts(s entity.Service) []artifact {
+	if s.DontTemplate || (s.Unit == nil && s.Timer == nil) {
+		return nil
+	}
+
+	unitType := getServiceType(s)
+	artifacts := []artifact{{Type: artifactUnit, Path: fmt.Sprintf("%s.%s", s.Name, unitType), System: s.System}}
+	if s.Unit != nil {
+		artifacts = append(artifacts, artifact{Type: artifactFile, Path: getServiceFilePath(s)})
+	}
+	if s.Timer != nil {
+		artifacts = append(artifacts, artifact{Type: artifactFile, Path: getTimerFilePath(s)})
+	}
+
+	return artifacts
+}
+
+func serviceResources(config entity.Config) []resource {
+	var resources []resource
+	for _, svc := range config.Services {
+		resources = append(resources, resource{
+			name: svc.Name,
+			meta: svc.Meta,
+			artifacts: func() []artifact {
+				return serviceArtifacts(svc)
+			},
+			when: svc,
+			apply: func() (bool, error) {
+				return initService(svc, config)
+			},
+			plan: func() ([]string, error) {
+				return planService(svc, config)
+			},
+		})
+	}
+
+	return resources
 }
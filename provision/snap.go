@@ -1,7 +1,6 @@
 package provision
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/femnad/fup/common"
@@ -16,9 +15,6 @@ func isSnapInstalled(snap entity.Snap) bool {
 }
 
 func installSnap(snap entity.Snap) error {
-	if isSnapInstalled(snap) {
-		return nil
-	}
 
 	internal.Logger.Debug().Str("name", snap.Name).Msg("Installing snap")
 	cmd := fmt.Sprintf("snap install %s", snap.Name)
@@ -36,9 +32,6 @@ func installSnap(snap entity.Snap) error {
 }
 
 func uninstallSnap(snap entity.Snap) error {
-	if !isSnapInstalled(snap) {
-		return nil
-	}
 
 	internal.Logger.Info().Str("name", snap.Name).Msg("Uninstalling snap")
 	cmd := fmt.Sprintf("snap remove %s", snap.Name)
@@ -52,22 +45,59 @@ func uninstallSnap(snap entity.Snap) error {
 	return nil
 }
 
-func snapInstall(config entity.Config) error {
+func ensureSnap(snap entity.Snap) (bool, error) {
+	if snap.Absent == !isSnapInstalled(snap) {
+		return false, nil
+	}
+
+	if snap.Absent {
+		return true, uninstallSnap(snap)
+	}
+
+	return true, installSnap(snap)
+}
+
+func planSnap(snap entity.Snap) []string {
+	installed := isSnapInstalled(snap)
+	if snap.Absent && installed {
+		return []string{"remove"}
+	} else if !snap.Absent && !installed {
+		return []string{"install"}
+	}
+
+	return nil
+}
+
+func snapAvailable() bool {
 	_, err := common.Which("snap")
 	if err != nil {
 		internal.Logger.Trace().Msg("Snap is not installed")
-		return nil
+		return false
 	}
 
-	var snapErr []error
+	return true
+}
+
+func snapResources(config entity.Config) []resource {
+	var resources []resource
 	for _, snap := range config.SnapPackages {
-		if snap.Absent {
-			err = uninstallSnap(snap)
-		} else {
-			err = installSnap(snap)
-		}
-		snapErr = append(snapErr, err)
+		resources = append(resources, resource{
+			name: snap.Name,
+			meta: snap.Meta,
+			apply: func() (bool, error) {
+				if !snapAvailable() {
+					return false, nil
+				}
+				return ensureSnap(snap)
+			},
+			plan: func() ([]string, error) {
+				if !snapAvailable() {
+					return nil, nil
+				}
+				return planSnap(snap), nil
+			},
+		})
 	}
 
-	return errors.Join(snapErr...)
+	return resources
 }
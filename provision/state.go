@@ -0,0 +1,258 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	marecmd "github.com/femnad/mare/cmd"
+
+	"github.com/femnad/fup/internal"
+)
+
+const (
+	stateFileName = "state.json"
+	stateFilePerm = 0o644
+	// Bump when fields are renamed or removed from the state file.
+	stateVersion = 1
+)
+
+type artifactType string
+
+const (
+	artifactDir     artifactType = "dir"
+	artifactFile    artifactType = "file"
+	artifactSymlink artifactType = "symlink"
+	// A systemd unit to disable and stop, with the unit name as the path.
+	artifactUnit artifactType = "unit"
+)
+
+// artifact is something created on disk by a resource, which can be removed once the resource is no longer declared.
+type artifact struct {
+	Type artifactType `json:"type"`
+	Path string       `json:"path"`
+	// Whether the artifact is managed with elevated privileges, only used for units.
+	System bool `json:"system,omitempty"`
+}
+
+type resourceState struct {
+	Provisioner string     `json:"provisioner"`
+	Name        string     `json:"name"`
+	Artifacts   []artifact `json:"artifacts"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// state records the artifacts created by each resource across runs.
+type state struct {
+	Version   int                      `json:"version"`
+	Resources map[string]resourceState `json:"resources"`
+	file      string
+}
+
+func stateFile() string {
+	return path.Join(internal.StateDir(), stateFileName)
+}
+
+func loadState() (*state, error) {
+	file := stateFile()
+	st := &state{Version: stateVersion, Resources: make(map[string]resourceState), file: file}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return st, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, st)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing state file %s: %v", file, err)
+	}
+	if st.Resources == nil {
+		st.Resources = make(map[string]resourceState)
+	}
+
+	return st, nil
+}
+
+func (s *state) save() error {
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+
+	dir, _ := path.Split(s.file)
+	err = os.MkdirAll(dir, dirMode)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.file, out, stateFilePerm)
+}
+
+func (s *state) record(r resource) {
+	if r.artifacts == nil {
+		return
+	}
+
+	var artifacts []artifact
+	for _, a := range r.artifacts() {
+		// Relative paths depend on the working dir so they can't be safely removed later.
+		if a.Type != artifactUnit && !path.IsAbs(a.Path) {
+			internal.Logger.Debug().Str("path", a.Path).Msg("Not recording artifact with relative path")
+			continue
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	// Unknown artifacts, such as for releases skipped due to a precheck, keep the previously recorded ones.
+	if len(artifacts) == 0 {
+		return
+	}
+
+	s.Resources[r.id()] = resourceState{
+		Provisioner: r.kind,
+		Name:        r.name,
+		Artifacts:   artifacts,
+		UpdatedAt:   time.Now().UTC(),
+	}
+}
+
+// undeclared returns the IDs of the recorded resources of the given kinds which are not among the declared ones.
+func (s *state) undeclared(kinds mapset.Set[string], declared mapset.Set[string]) []string {
+	var ids []string
+	for id, rs := range s.Resources {
+		if kinds.ContainsOne(rs.Provisioner) && !declared.ContainsOne(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func removeSymlink(link string) error {
+	info, err := os.Lstat(link)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		internal.Logger.Warn().Str("path", link).Msg("Not removing path as it's no longer a symlink")
+		return nil
+	}
+
+	return os.Remove(link)
+}
+
+func disableUnit(unit string, system bool) error {
+	disable := fmt.Sprintf("systemctl disable --now %s", unit)
+	reload := "systemctl daemon-reload"
+	if !system {
+		disable = fmt.Sprintf("systemctl --user disable --now %s", unit)
+		reload = "systemctl --user daemon-reload"
+	}
+
+	err := marecmd.RunErrOnly(marecmd.Input{Command: disable, Sudo: system})
+	if err != nil {
+		return err
+	}
+
+	return marecmd.RunErrOnly(marecmd.Input{Command: reload, Sudo: system})
+}
+
+func removeArtifact(a artifact) error {
+	internal.Logger.Debug().Str("type", string(a.Type)).Str("path", a.Path).Msg("Removing artifact")
+
+	switch a.Type {
+	case artifactDir:
+		return internal.EnsureDirAbsent(a.Path)
+	case artifactFile:
+		return internal.EnsureFileAbsent(a.Path)
+	case artifactSymlink:
+		return removeSymlink(a.Path)
+	case artifactUnit:
+		return disableUnit(a.Path, a.System)
+	default:
+		return fmt.Errorf("unknown artifact type %s", a.Type)
+	}
+}
+
+func (s *state) claimedPaths(except string) mapset.Set[string] {
+	paths := mapset.NewThreadUnsafeSet[string]()
+	for id, rs := range s.Resources {
+		if id == except {
+			continue
+		}
+		for _, a := range rs.Artifacts {
+			paths.Add(a.Path)
+		}
+	}
+
+	return paths
+}
+
+// prune removes the artifacts of the resource with the given ID and forgets about it if all artifacts are removed.
+// Artifacts also recorded for another resource, such as after renaming a resource, are kept.
+func (s *state) prune(id string) error {
+	rs := s.Resources[id]
+	claimed := s.claimedPaths(id)
+	for _, a := range rs.Artifacts {
+		if claimed.ContainsOne(a.Path) {
+			internal.Logger.Debug().Str("path", a.Path).Msg("Keeping artifact recorded for another resource")
+			continue
+		}
+
+		err := removeArtifact(a)
+		if err != nil {
+			return fmt.Errorf("error removing %s %s of %s: %v", a.Type, a.Path, id, err)
+		}
+	}
+
+	delete(s.Resources, id)
+	return nil
+}
+
+// brokenLinks reports the symlinks created by a resource which are missing or point to a missing target.
+func brokenLinks(rs resourceState) []string {
+	var broken []string
+	for _, a := range rs.Artifacts {
+		if a.Type != artifactSymlink {
+			continue
+		}
+
+		target, err := os.Readlink(a.Path)
+		if err != nil {
+			broken = append(broken, fmt.Sprintf("missing symlink %s", a.Path))
+			continue
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(a.Path), target)
+		}
+		if _, err = os.Stat(target); err != nil {
+			broken = append(broken, fmt.Sprintf("broken symlink %s -> %s", a.Path, target))
+		}
+	}
+
+	return broken
+}
+
+func planPrune(rs resourceState) []string {
+	var changes []string
+	for _, a := range rs.Artifacts {
+		if a.Type == artifactUnit {
+			changes = append(changes, fmt.Sprintf("disable %s", a.Path))
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("remove %s %s", a.Type, a.Path))
+	}
+
+	return changes
+}
@@ -0,0 +1,106 @@
+package provision
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/femnad/fup/internal"
+)
+
+func Test_stateRoundTrip(t *testing.T) {
+	t.Setenv(internal.StateHomeEnv, t.TempDir())
+
+	st, err := loadState()
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+
+	st.record(resource{kind: "template", name: "foo", artifacts: func() []artifact {
+		return []artifact{{Type: artifactFile, Path: "/tmp/foo"}, {Type: artifactFile, Path: "relative"}}
+	}})
+	err = st.save()
+	if err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	loaded, err := loadState()
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+
+	want := []artifact{{Type: artifactFile, Path: "/tmp/foo"}}
+	got := loaded.Resources["template:foo"].Artifacts
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadState() artifacts = %v, want %v", got, want)
+	}
+}
+
+func Test_statePrune(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "file")
+	link := path.Join(dir, "link")
+	releaseDir := path.Join(dir, "release")
+	shared := path.Join(dir, "shared")
+
+	for _, f := range []string{file, shared} {
+		if err := os.WriteFile(f, []byte("foo"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(releaseDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(releaseDir, link); err != nil {
+		t.Fatal(err)
+	}
+
+	st := &state{Resources: map[string]resourceState{
+		"release:foo": {Provisioner: "release", Name: "foo", Artifacts: []artifact{
+			{Type: artifactSymlink, Path: link},
+			{Type: artifactDir, Path: releaseDir},
+		}},
+		"template:bar": {Provisioner: "template", Name: "bar", Artifacts: []artifact{
+			{Type: artifactFile, Path: file},
+			{Type: artifactFile, Path: shared},
+		}},
+		"template:baz": {Provisioner: "template", Name: "baz", Artifacts: []artifact{
+			{Type: artifactFile, Path: shared},
+		}},
+		"go:qux": {Provisioner: "go", Name: "qux"},
+	}}
+
+	kinds := mapset.NewThreadUnsafeSet("release", "template")
+	declared := mapset.NewThreadUnsafeSet("template:baz")
+	undeclared := st.undeclared(kinds, declared)
+	wantUndeclared := []string{"release:foo", "template:bar"}
+	if !reflect.DeepEqual(undeclared, wantUndeclared) {
+		t.Fatalf("undeclared() = %v, want %v", undeclared, wantUndeclared)
+	}
+
+	for _, id := range undeclared {
+		if err := st.prune(id); err != nil {
+			t.Errorf("prune() error = %v", err)
+		}
+	}
+
+	for _, removed := range []string{file, link, releaseDir} {
+		if _, err := os.Lstat(removed); !os.IsNotExist(err) {
+			t.Errorf("prune() did not remove %s", removed)
+		}
+	}
+	if _, err := os.Stat(shared); err != nil {
+		t.Errorf("prune() removed %s which is still recorded for another resource", shared)
+	}
+
+	var remaining []string
+	for id := range st.Resources {
+		remaining = append(remaining, id)
+	}
+	if !mapset.NewThreadUnsafeSet(remaining...).Equal(mapset.NewThreadUnsafeSet("template:baz", "go:qux")) {
+		t.Errorf("prune() remaining resources = %v", remaining)
+	}
+}
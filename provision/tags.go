@@ -0,0 +1,58 @@
+package provision
+
+import (
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/femnad/fup/internal"
+)
+
+// tagFilter selects resources by their tags.
+type tagFilter struct {
+	// Only resources with at least one of these tags are selected, if not empty.
+	tags mapset.Set[string]
+	// Resources with any of these tags are not selected.
+	skip mapset.Set[string]
+}
+
+// tagSet returns the tags in the given values, which can also be comma separated.
+func tagSet(values []string) mapset.Set[string] {
+	tags := mapset.NewThreadUnsafeSet[string]()
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" {
+				tags.Add(tag)
+			}
+		}
+	}
+
+	return tags
+}
+
+func newTagFilter(tags, skipTags []string) tagFilter {
+	return tagFilter{tags: tagSet(tags), skip: tagSet(skipTags)}
+}
+
+func (f tagFilter) selects(r resource) bool {
+	if f.skip != nil && f.skip.ContainsAny(r.meta.Tags...) {
+		return false
+	}
+
+	return f.tags == nil || f.tags.IsEmpty() || f.tags.ContainsAny(r.meta.Tags...)
+}
+
+// filter returns the selected resources, keeping their order.
+func (f tagFilter) filter(resources []resource) []resource {
+	var selected []resource
+	for _, r := range resources {
+		if !f.selects(r) {
+			internal.Logger.Trace().Str("kind", r.kind).Str("name", r.name).Msg("Skipping due to tags")
+			continue
+		}
+		selected = append(selected, r)
+	}
+
+	return selected
+}
@@ -0,0 +1,64 @@
+package provision
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/femnad/fup/entity"
+)
+
+func taggedResource(name string, tags ...string) resource {
+	return resource{kind: "release", name: name, meta: entity.Meta{Tags: tags}}
+}
+
+func Test_tagFilter(t *testing.T) {
+	resources := []resource{
+		taggedResource("fzf", "dev"),
+		taggedResource("firefox", "gui", "heavy"),
+		taggedResource("emacs", "dev", "gui"),
+		taggedResource("untagged"),
+	}
+	tests := []struct {
+		name     string
+		tags     []string
+		skipTags []string
+		want     []string
+	}{
+		{
+			name: "No filter",
+			want: []string{"fzf", "firefox", "emacs", "untagged"},
+		},
+		{
+			name: "Tags",
+			tags: []string{"dev"},
+			want: []string{"fzf", "emacs"},
+		},
+		{
+			name: "Comma separated tags",
+			tags: []string{"dev,gui"},
+			want: []string{"fzf", "firefox", "emacs"},
+		},
+		{
+			name:     "Tags and skip tags",
+			tags:     []string{"gui"},
+			skipTags: []string{"heavy"},
+			want:     []string{"emacs"},
+		},
+		{
+			name:     "Skip tags only",
+			skipTags: []string{"dev"},
+			want:     []string{"firefox", "untagged"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range newTagFilter(tt.tags, tt.skipTags).filter(resources) {
+				got = append(got, r.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
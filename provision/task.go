@@ -1,46 +1,45 @@
 package provision
 
 import (
-	"errors"
-
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
 	"github.com/femnad/fup/precheck/unless"
-	"github.com/femnad/fup/precheck/when"
-)
-
-const (
-	hintBase = "Hint for running task"
 )
 
 func runTask(task entity.Task, cfg entity.Config) error {
-	name := task.Name()
+	internal.Logger.Debug().Str("name", task.Name()).Msg("Running task")
+	return task.Run(cfg)
+}
 
-	if !when.ShouldRun(task) {
-		if task.Hint != "" && !unless.ShouldSkip(task, cfg.Settings) {
-			internal.Logger.Warn().Str("name", name).Str("task", task.Hint).Msg(hintBase)
+func planTask(task entity.Task, cfg entity.Config) []string {
+	var changes []string
+	for _, step := range task.Steps {
+		if unless.ShouldSkip(step, cfg.Settings) {
+			continue
 		}
-		whenText := internal.PrettyLogStr(task.When)
-		internal.Logger.Trace().Str("name", name).Str("when", whenText).Msg("Skipping task")
-		return nil
+		changes = append(changes, "run step "+step.String())
 	}
 
-	if unless.ShouldSkip(task, cfg.Settings) {
-		internal.Logger.Trace().Str("name", name).Msg("Skipping task")
-		return nil
-	}
-
-	internal.Logger.Debug().Str("name", name).Msg("Running task")
-	return task.Run(cfg)
+	return changes
 }
 
-func runTasks(cfg entity.Config, tasks []entity.Task) error {
-	var taskErrs []error
-
+func taskResources(cfg entity.Config, tasks []entity.Task) []resource {
+	var resources []resource
 	for _, task := range tasks {
-		err := runTask(task, cfg)
-		taskErrs = append(taskErrs, err)
+		resources = append(resources, resource{
+			name:   task.Name(),
+			meta:   task.Meta,
+			hint:   task.Hint,
+			when:   task,
+			unless: task,
+			apply: func() (bool, error) {
+				return true, runTask(task, cfg)
+			},
+			plan: func() ([]string, error) {
+				return planTask(task, cfg), nil
+			},
+		})
 	}
 
-	return errors.Join(taskErrs...)
+	return resources
 }
@@ -2,7 +2,7 @@ package provision
 
 import (
 	"bytes"
-	"errors"
+	"fmt"
 	"net/url"
 	"os"
 	"path"
@@ -10,7 +10,6 @@ import (
 
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
-	"github.com/femnad/fup/precheck/when"
 	"github.com/femnad/fup/remote"
 )
 
@@ -18,14 +17,27 @@ const (
 	tmpDir = "/tmp"
 )
 
-func getTemplateDir(config entity.Config) (string, error) {
-	templateDir := internal.ExpandUser(config.Settings.TemplateDir)
+// templateOrigin returns where the template is declared, defaulting to the main config for templates which aren't
+// read from a config file.
+func templateOrigin(config entity.Config, tmpl entity.Template) entity.ConfigOrigin {
+	origin := tmpl.Origin
+	if origin.File == "" {
+		origin = entity.ConfigOrigin{File: config.File(), Remote: config.IsRemote()}
+	}
+	if origin.TemplateDir == "" {
+		origin.TemplateDir = config.Settings.TemplateDir
+	}
+
+	return origin
+}
+
+func getTemplateDir(origin entity.ConfigOrigin) (string, error) {
+	templateDir := internal.ExpandUser(origin.TemplateDir)
 	if path.IsAbs(templateDir) {
 		return templateDir, nil
 	}
 
-	configPath := config.File()
-	configDir, _ := path.Split(configPath)
+	configDir, _ := path.Split(origin.File)
 	if path.IsAbs(configDir) {
 		return path.Join(configDir, templateDir), nil
 	}
@@ -38,8 +50,8 @@ func getTemplateDir(config entity.Config) (string, error) {
 	return path.Join(wd, configDir, templateDir), nil
 }
 
-func getLocalTemplate(config entity.Config, tmplSrc string) ([]byte, error) {
-	templateDir, err := getTemplateDir(config)
+func getLocalTemplate(origin entity.ConfigOrigin, tmplSrc string) ([]byte, error) {
+	templateDir, err := getTemplateDir(origin)
 	if err != nil {
 		return nil, err
 	}
@@ -54,17 +66,18 @@ func getTemplateContent(config entity.Config, tmpl entity.Template) ([]byte, err
 	}
 
 	tmplSrc := tmpl.Src
-	if !config.IsRemote() {
-		return getLocalTemplate(config, tmplSrc)
+	origin := templateOrigin(config, tmpl)
+	if !origin.Remote {
+		return getLocalTemplate(origin, tmplSrc)
 	}
 
-	followedUrl, err := remote.FollowRedirects(config.File())
+	followedUrl, err := remote.FollowRedirects(origin.File)
 	if err != nil {
 		return nil, err
 	}
 
 	configBase, _ := path.Split(followedUrl)
-	_, relTmplDir := path.Split(config.Settings.TemplateDir)
+	_, relTmplDir := path.Split(origin.TemplateDir)
 	tmplUrl, err := url.JoinPath(configBase, relTmplDir, tmplSrc)
 	if err != nil {
 		return nil, err
@@ -73,27 +86,21 @@ func getTemplateContent(config entity.Config, tmpl entity.Template) ([]byte, err
 	return remote.ReadResponseBytes(tmplUrl)
 }
 
-func applyTemplate(tmpl entity.Template, config entity.Config) error {
-	if !when.ShouldRun(tmpl) {
-		return nil
-	}
-
-	internal.Logger.Trace().Str("destination", tmpl.Dest).Msg("Applying template")
-
+func renderTemplate(tmpl entity.Template, config entity.Config) (string, error) {
 	templateContent, err := getTemplateContent(config, tmpl)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	parsed, err := template.New("tmpl").Parse(string(templateContent))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	tmplBuffer := bytes.Buffer{}
 	err = parsed.Execute(&tmplBuffer, tmpl.Context)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	content := tmplBuffer.String()
@@ -101,35 +108,85 @@ func applyTemplate(tmpl entity.Template, config entity.Config) error {
 		content = internal.ExpandUserAll(content)
 	}
 
+	return content, nil
+}
+
+func applyTemplate(tmpl entity.Template, config entity.Config) (bool, error) {
+	internal.Logger.Trace().Str("destination", tmpl.Dest).Msg("Applying template")
+
+	content, err := renderTemplate(tmpl, config)
+	if err != nil {
+		return false, err
+	}
+
 	updated, err := internal.WriteContent(internal.ManagedFile{Path: tmpl.Dest, Content: content})
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if !updated {
-		return nil
+		return false, nil
 	}
 
 	for _, step := range tmpl.RunAfter {
 		err = step.Run(config)
 		if err != nil {
 			internal.Logger.Error().Err(err).Str("step", step.Name()).Msg("Error running step after template")
-			return err
+			return true, err
 		}
 	}
 
-	return nil
+	return true, nil
 }
 
-func applyTemplates(config entity.Config) error {
-	var tmplErr []error
-	for _, tmpl := range config.Templates {
-		err := applyTemplate(tmpl, config)
-		if err != nil {
-			internal.Logger.Error().Err(err).Str("destination", tmpl.Dest).Msg("Error applying template")
-		}
-		tmplErr = append(tmplErr, err)
+func planTemplate(tmpl entity.Template, config entity.Config) ([]string, error) {
+	content, err := renderTemplate(tmpl, config)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := internal.ContentChanged(internal.ManagedFile{Path: tmpl.Dest, Content: content})
+	if err != nil || !changed {
+		return nil, err
+	}
+
+	changes := []string{fmt.Sprintf("write %s", tmpl.Dest)}
+	for _, step := range tmpl.RunAfter {
+		changes = append(changes, "run step "+step.String())
 	}
 
-	return errors.Join(tmplErr...)
+	return changes, nil
+}
+
+func templateResources(config entity.Config) []resource {
+	var resources []resource
+	for _, tmpl := range config.Templates {
+		dest := internal.ExpandUser(tmpl.Dest)
+		var created bool
+		resources = append(resources, resource{
+			name: tmpl.Dest,
+			meta: tmpl.Meta,
+			// Only destinations created by fup are recorded, so that pruning doesn't remove files such as system
+			// configs which existed before. Destinations recorded on an earlier run are kept by the state.
+			artifacts: func() []artifact {
+				if !created {
+					return nil
+				}
+				return []artifact{{Type: artifactFile, Path: dest}}
+			},
+			when: tmpl,
+			apply: func() (bool, error) {
+				_, err := os.Lstat(dest)
+				existed := !os.IsNotExist(err)
+				updated, err := applyTemplate(tmpl, config)
+				created = !existed && updated
+				return updated, err
+			},
+			plan: func() ([]string, error) {
+				return planTemplate(tmpl, config)
+			},
+		})
+	}
+
+	return resources
 }
@@ -0,0 +1,36 @@
+package provision
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/internal"
+)
+
+func Test_templateResourcesArtifacts(t *testing.T) {
+	t.Setenv(internal.StateHomeEnv, t.TempDir())
+	dir := t.TempDir()
+	existing := path.Join(dir, "existing")
+	created := path.Join(dir, "created")
+	if err := os.WriteFile(existing, []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := entity.Config{Templates: []entity.Template{
+		{Content: "bar", Dest: existing},
+		{Content: "bar", Dest: created},
+	}}
+	want := [][]artifact{nil, {{Type: artifactFile, Path: created}}}
+
+	for i, r := range templateResources(config) {
+		if _, err := r.apply(); err != nil {
+			t.Fatalf("apply() error = %v", err)
+		}
+		if got := r.artifacts(); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("artifacts() of %s = %v, want %v", r.name, got, want[i])
+		}
+	}
+}
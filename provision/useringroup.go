@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os/user"
+	"sort"
 
 	marecmd "github.com/femnad/mare/cmd"
 
@@ -49,99 +50,180 @@ func groupAdd(group entity.Group) error {
 	return err
 }
 
-func ensureGroup(group entity.Group) error {
+func groupExists(group entity.Group) (bool, error) {
 	var unknownGroupError user.UnknownGroupError
 	_, err := user.LookupGroup(group.Name)
 	if err == nil {
-		return nil
+		return true, nil
 	} else if !errors.As(err, &unknownGroupError) {
-		return err
+		return false, err
 	}
 
-	return groupAdd(group)
+	return false, nil
 }
 
-func doEnsureUserInGroups(spec entity.UserGroupSpec) error {
-	userName := spec.Name
+func ensureGroup(group entity.Group) (bool, error) {
+	exists, err := groupExists(group)
+	if err != nil || exists {
+		return false, err
+	}
+
+	return true, groupAdd(group)
+}
+
+func lookupUser(userName string) (*user.User, error) {
+	var unknownUserError user.UnknownUserError
 	u, err := user.Lookup(userName)
-	if err != nil {
-		if spec.Ensure {
-			err = ensureUser(userName)
+	if err == nil {
+		return u, nil
+	} else if !errors.As(err, &unknownUserError) {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func missingGroups(u *user.User, spec entity.UserGroupSpec) ([]string, error) {
+	var userGroups []string
+	if u != nil {
+		groupIds, err := u.GroupIds()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, gid := range groupIds {
+			var group *user.Group
+			group, err = user.LookupGroupId(gid)
 			if err != nil {
-				return err
+				return nil, err
 			}
-		} else {
+			userGroups = append(userGroups, group.Name)
+		}
+	}
+
+	desiredGroups := mare.MapToString(spec.Groups, func(group entity.Group) string {
+		return group.Name
+	})
+	desired := internal.SetFromList[string](desiredGroups)
+	current := internal.SetFromList[string](userGroups)
+	missing := desired.Difference(current).ToSlice()
+	sort.Strings(missing)
+
+	return missing, nil
+}
+
+func doEnsureUserInGroups(spec entity.UserGroupSpec) (bool, error) {
+	var changed bool
+	userName := spec.Name
+	u, err := lookupUser(userName)
+	if err != nil {
+		return false, err
+	}
+
+	if u == nil {
+		if !spec.Ensure {
 			internal.Logger.Warn().Str("user", userName).Msg(
 				"User does not exist, skipping group modifications")
-			return nil
+			return false, nil
+		}
+
+		err = ensureUser(userName)
+		if err != nil {
+			return false, err
+		}
+		changed = true
+
+		u, err = user.Lookup(userName)
+		if err != nil {
+			return changed, err
 		}
 	}
 
-	groups := spec.Groups
-	for _, g := range groups {
+	for _, g := range spec.Groups {
 		if !g.Ensure {
 			continue
 		}
 
-		err = ensureGroup(g)
+		var added bool
+		added, err = ensureGroup(g)
 		if err != nil {
-			return err
+			return changed, err
 		}
+		changed = changed || added
 	}
 
-	groupIds, err := u.GroupIds()
+	missing, err := missingGroups(u, spec)
 	if err != nil {
-		return err
+		return changed, err
 	}
 
-	var userGroups []string
-	for _, gid := range groupIds {
-		var group *user.Group
-		group, err = user.LookupGroupId(gid)
+	for _, group := range missing {
+		err = addUserToGroup(userName, group)
 		if err != nil {
-			return err
+			return changed, err
 		}
-		userGroups = append(userGroups, group.Name)
+		changed = true
 	}
 
-	desiredGroups := mare.MapToString(groups, func(group entity.Group) string {
-		return group.Name
-	})
-	desired := internal.SetFromList[string](desiredGroups)
-	current := internal.SetFromList[string](userGroups)
-	missing := desired.Difference(current)
+	return changed, nil
+}
 
-	missing.Each(func(missingGroup string) bool {
-		err = addUserToGroup(userName, missingGroup)
-		if err != nil {
-			return true
-		}
-		return false
-	})
+func planUserInGroups(spec entity.UserGroupSpec) ([]string, error) {
+	var changes []string
+	userName := spec.Name
+	u, err := lookupUser(userName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
-}
+	if u == nil {
+		if !spec.Ensure {
+			return nil, nil
+		}
+		changes = append(changes, "create user")
+	}
+
+	for _, g := range spec.Groups {
+		if !g.Ensure {
+			continue
+		}
 
-func ensureUserInGroups(userGroupSpecs entity.UserInGroupSpec) error {
-	for _, spec := range userGroupSpecs {
-		err := doEnsureUserInGroups(spec)
+		var exists bool
+		exists, err = groupExists(g)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if !exists {
+			changes = append(changes, fmt.Sprintf("create group %s", g.Name))
 		}
 	}
 
-	return nil
+	missing, err := missingGroups(u, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range missing {
+		changes = append(changes, fmt.Sprintf("add to group %s", group))
+	}
+
+	return changes, nil
 }
 
-func userInGroup(config entity.Config) error {
-	err := ensureUserInGroups(config.UserInGroup)
-	if err != nil {
-		internal.Logger.Error().Err(err).Msg("Error ensuring user in groups")
-		return err
+func userInGroupResources(config entity.Config) []resource {
+	var resources []resource
+	for _, spec := range config.UserInGroup {
+		resources = append(resources, resource{
+			name: spec.Name,
+			meta: spec.Meta,
+			apply: func() (bool, error) {
+				return doEnsureUserInGroups(spec)
+			},
+			plan: func() ([]string, error) {
+				return planUserInGroups(spec)
+			},
+		})
 	}
 
-	return nil
+	return resources
 }
@@ -24,46 +24,20 @@ type idKeyPair struct {
 	Key string `yaml:"key"`
 }
 
-func ensureUserKeys(user string) error {
+func missingUserKeys(user string) (mapset.Set[string], error) {
 	url := fmt.Sprintf("https://api.github.com/users/%s/keys", user)
 	resp, err := remote.ReadResponseBody(url)
 	if err != nil {
 		internal.Logger.Error().Err(err).Str("url", url).Msg("Error fetching keys")
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	keyFile := internal.ExpandUser(authorizedKeysFile)
-	dir, _ := path.Split(keyFile)
-	if err = internal.EnsureDirExists(dir); err != nil {
-		return err
-	}
-
-	_, err = os.Stat(keyFile)
-	var fd *os.File
-	if os.IsNotExist(err) {
-		fd, err = os.OpenFile(keyFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, authorizedKeyfFilePerm)
-		if err != nil {
-			return err
-		}
-	} else if err == nil {
-		fd, err = os.OpenFile(keyFile, os.O_RDWR, authorizedKeyfFilePerm)
-		if err != nil {
-			return err
-		}
-	} else {
-		return err
-	}
-	defer fd.Close()
-
-	scanner := bufio.NewScanner(fd)
-	scanner.Split(bufio.ScanLines)
-
 	var userKeyPairs []idKeyPair
 	d := json.NewDecoder(resp.Body)
 	err = d.Decode(&userKeyPairs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	keySet := mapset.NewSet[string]()
@@ -71,6 +45,17 @@ func ensureUserKeys(user string) error {
 		keySet.Add(pair.Key)
 	}
 
+	fd, err := os.Open(internal.ExpandUser(authorizedKeysFile))
+	if os.IsNotExist(err) {
+		return keySet, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	scanner := bufio.NewScanner(fd)
+	scanner.Split(bufio.ScanLines)
+
 	for scanner.Scan() {
 		line := scanner.Text()
 		if keySet.Contains(line) {
@@ -78,6 +63,31 @@ func ensureUserKeys(user string) error {
 		}
 	}
 
+	return keySet, nil
+}
+
+func ensureUserKeys(user string) (bool, error) {
+	keySet, err := missingUserKeys(user)
+	if err != nil {
+		return false, err
+	}
+
+	if keySet.Cardinality() == 0 {
+		return false, nil
+	}
+
+	keyFile := internal.ExpandUser(authorizedKeysFile)
+	dir, _ := path.Split(keyFile)
+	if err = internal.EnsureDirExists(dir); err != nil {
+		return false, err
+	}
+
+	fd, err := os.OpenFile(keyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, authorizedKeyfFilePerm)
+	if err != nil {
+		return false, err
+	}
+	defer fd.Close()
+
 	keySet.Each(func(key string) bool {
 		line := fmt.Sprintf("%s\n", key)
 		_, err = fd.WriteString(line)
@@ -87,20 +97,36 @@ func ensureUserKeys(user string) error {
 		return false
 	})
 
-	return err
+	return true, err
+}
+
+func planUserKeys(user string) ([]string, error) {
+	keySet, err := missingUserKeys(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if keySet.Cardinality() == 0 {
+		return nil, nil
+	}
+
+	return []string{fmt.Sprintf("add %d key(s) to %s", keySet.Cardinality(), authorizedKeysFile)}, nil
 }
 
-func addGithubUserKeys(config entity.Config) error {
+func githubUserKeyResources(config entity.Config) []resource {
 	user := config.GithubUserKey.User
 	if user == "" {
 		return nil
 	}
 
-	err := ensureUserKeys(user)
-	if err != nil {
-		internal.Logger.Error().Stack().Err(err).Msg("Error ensuring Github user keys")
-		return err
-	}
-
-	return nil
+	return []resource{{
+		name: user,
+		meta: config.GithubUserKey.Meta,
+		apply: func() (bool, error) {
+			return ensureUserKeys(user)
+		},
+		plan: func() ([]string, error) {
+			return planUserKeys(user)
+		},
+	}}
 }
@@ -2,13 +2,13 @@ package provision
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/femnad/fup/common"
 	"github.com/femnad/fup/entity"
 	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/settings"
 	"github.com/femnad/mare/cmd"
 )
 
@@ -38,42 +38,80 @@ func getToolVersion(tool string) (string, error) {
 	return "", fmt.Errorf("unable to determine installed version for %s", tool)
 }
 
-func installTool(tool entity.UvTool) error {
-	version := tool.Version
-	if version == "" {
-		version = defaultVersion
+func getDesiredVersion(tool entity.UvTool, s settings.Settings) (string, error) {
+	version, err := tool.LookupVersion(s)
+	if err != nil || version == "" {
+		return defaultVersion, err
 	}
-	name := tool.Name
 
-	_, err := common.Which(name)
-	if err == nil {
-		if version == defaultVersion {
-			return nil
-		}
-	
-		var installedVersion string
-		installedVersion, err = getToolVersion(name)
-		if err != nil {
-			return err
-		}
+	return version, nil
+}
 
-		if installedVersion == version {
-			return nil
-		}
+func isToolUpToDate(tool entity.UvTool, s settings.Settings) (bool, error) {
+	version, err := getDesiredVersion(tool, s)
+	if err != nil {
+		return false, err
+	}
+	name := tool.Name()
+
+	_, err = common.Which(name)
+	if err != nil {
+		return false, nil
+	}
+
+	if version == defaultVersion {
+		return true, nil
+	}
+
+	installedVersion, err := getToolVersion(name)
+	if err != nil {
+		return false, err
 	}
 
+	return installedVersion == version, nil
+}
+
+func installTool(tool entity.UvTool, s settings.Settings) (bool, error) {
+	upToDate, err := isToolUpToDate(tool, s)
+	if err != nil {
+		return false, err
+	}
+	if upToDate {
+		return false, nil
+	}
+
+	name := tool.Name()
+	version, err := getDesiredVersion(tool, s)
+	if err != nil {
+		return false, err
+	}
 	internal.Logger.Debug().Str("tool", name).Str("version", version).Msg("Installing uv tool")
-	return cmd.RunErrOnly(cmd.Input{Command: fmt.Sprintf("uv tool install %s@%s", name, version)})
+	err = cmd.RunErrOnly(cmd.Input{Command: fmt.Sprintf("uv tool install %s@%s", name, version)})
+	return err == nil, err
 }
 
-func uvInstallTools(cfg entity.Config) error {
-	var errs []error
+func uvResources(cfg entity.Config) []resource {
+	var resources []resource
 	for _, tool := range cfg.UvTools {
-		err := installTool(tool)
-		if err != nil {
-			errs = append(errs, err)
-		}
+		resources = append(resources, resource{
+			name: tool.Name(),
+			meta: tool.Meta,
+			apply: func() (bool, error) {
+				return installTool(tool, cfg.Settings)
+			},
+			plan: func() ([]string, error) {
+				upToDate, err := isToolUpToDate(tool, cfg.Settings)
+				if err != nil || upToDate {
+					return nil, err
+				}
+				version, err := getDesiredVersion(tool, cfg.Settings)
+				if err != nil {
+					return nil, err
+				}
+				return []string{fmt.Sprintf("uv tool install %s@%s", tool.Name(), version)}, nil
+			},
+		})
 	}
 
-	return errors.Join(errs...)
+	return resources
 }
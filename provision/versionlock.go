@@ -0,0 +1,221 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"go.yaml.in/yaml/v4"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/remote"
+	"github.com/femnad/fup/settings"
+)
+
+const (
+	versionLockFileName = "fup.lock"
+	versionLockFilePerm = 0o644
+	// Bump when fields are renamed or removed from the lock file.
+	versionLockFormat = 1
+	versionLockHeader = "# Generated by fup lock, update with fup lock or apply --update.\n"
+)
+
+// LockedResource is the version a resource resolved to, along with the URL and checksum of its download if it has one.
+type LockedResource struct {
+	Checksum string `yaml:"checksum,omitempty"`
+	URL      string `yaml:"url,omitempty"`
+	Version  string `yaml:"version,omitempty"`
+}
+
+// VersionLock records the resolved versions of resources by their resource ID such as release:foo, so that applying a
+// config gets the same versions and downloads regardless of when version lookups are run.
+type VersionLock struct {
+	Format    int                       `yaml:"format"`
+	Resources map[string]LockedResource `yaml:"resources"`
+}
+
+// VersionLockFile returns the lock file of the config, which is next to the config file, or in the working directory
+// for remote configs.
+func VersionLockFile(config entity.Config) string {
+	if config.IsRemote() {
+		return versionLockFileName
+	}
+
+	dir, _ := path.Split(internal.ExpandUser(config.File()))
+	return path.Join(dir, versionLockFileName)
+}
+
+// ReadVersionLock reads the lock file of the config, returning nil if there isn't one.
+func ReadVersionLock(config entity.Config) (*VersionLock, error) {
+	file := VersionLockFile(config)
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var lock VersionLock
+	err = yaml.Unmarshal(data, &lock)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing lock file %s: %v", file, err)
+	}
+	if lock.Format != versionLockFormat {
+		return nil, fmt.Errorf("unsupported format %d in lock file %s", lock.Format, file)
+	}
+	if lock.Resources == nil {
+		lock.Resources = make(map[string]LockedResource)
+	}
+
+	return &lock, nil
+}
+
+// Write saves the lock into the given file.
+func (l VersionLock) Write(file string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, append([]byte(versionLockHeader), data...), versionLockFilePerm)
+}
+
+// lockRelease resolves the version of a release and downloads it for locking its checksum. The resolved version is
+// stored in the settings so that it's not looked up again.
+func lockRelease(release entity.Release, s settings.Settings) (LockedResource, error) {
+	version, err := release.LookupVersion(s)
+	if err != nil {
+		return LockedResource{}, err
+	}
+	if version != "" {
+		s.Internal.LockedVersions[release.LockID()] = version
+	}
+
+	cached, err := downloadRelease(release, s)
+	if err != nil {
+		return LockedResource{}, err
+	}
+
+	return LockedResource{
+		Checksum: remote.SHA256Checksum(cached.SHA256),
+		URL:      cached.URL,
+		Version:  version,
+	}, nil
+}
+
+// lockable is a package whose version can be looked up.
+type lockable interface {
+	LockID() string
+	LookupVersion(settings.Settings) (string, error)
+	Name() string
+}
+
+func lockablePackages(config entity.Config) []lockable {
+	var packages []lockable
+	for _, pkg := range config.Python {
+		packages = append(packages, pkg)
+	}
+	for _, pkg := range config.Go {
+		packages = append(packages, pkg)
+	}
+	for _, pkg := range config.Cargo {
+		packages = append(packages, pkg)
+	}
+	for _, tool := range config.UvTools {
+		packages = append(packages, tool)
+	}
+
+	return packages
+}
+
+// duplicateLockIDs returns the lock IDs shared by more than one release or package, which would overwrite each other's
+// lock entries.
+func duplicateLockIDs(releases []entity.Release, packages []lockable) mapset.Set[string] {
+	seen := mapset.NewThreadUnsafeSet[string]()
+	duplicates := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range releases {
+		if !seen.Add(release.LockID()) {
+			duplicates.Add(release.LockID())
+		}
+	}
+	for _, pkg := range packages {
+		if !seen.Add(pkg.LockID()) {
+			duplicates.Add(pkg.LockID())
+		}
+	}
+
+	return duplicates
+}
+
+// ResolveVersionLock looks up the versions of all resources in the config, regardless of when conditions and tags.
+// Releases are downloaded to lock their checksums.
+func ResolveVersionLock(config entity.Config) (VersionLock, error) {
+	lock := VersionLock{Format: versionLockFormat, Resources: make(map[string]LockedResource)}
+	s := config.Settings
+	s.Internal.GhAvailable = ghCliAvailable(s)
+	s.Internal.LockedVersions = make(map[string]string)
+
+	releases, err := configReleases(config)
+	packages := lockablePackages(config)
+	duplicates := duplicateLockIDs(releases, packages)
+	if duplicates.Cardinality() > 0 {
+		ids := duplicates.ToSlice()
+		slices.Sort(ids)
+		return lock, errors.Join(err, fmt.Errorf("resources sharing a name can't be locked, rename them to be unique: %s",
+			strings.Join(ids, ", ")))
+	}
+
+	for _, release := range releases {
+		internal.Logger.Debug().Str("name", release.Name()).Msg("Locking release")
+		locked, lockErr := lockRelease(release, s)
+		if lockErr != nil {
+			err = errors.Join(err, fmt.Errorf("error locking release %s: %v", release.Name(), lockErr))
+			continue
+		}
+		lock.Resources[release.LockID()] = locked
+	}
+
+	for _, pkg := range packages {
+		version, lookupErr := pkg.LookupVersion(s)
+		if lookupErr != nil {
+			err = errors.Join(err, fmt.Errorf("error locking package %s: %v", pkg.Name(), lookupErr))
+			continue
+		}
+		if version != "" {
+			lock.Resources[pkg.LockID()] = LockedResource{Version: version}
+		}
+	}
+
+	return lock, err
+}
+
+// UseVersionLock returns the config with the versions in the lock for resources which don't have a version set in the
+// config, and the locked checksums for verifying release downloads which don't declare one.
+func UseVersionLock(config entity.Config, lock VersionLock) entity.Config {
+	versions := make(map[string]string)
+	checksums := make(map[string]string)
+	for id, locked := range lock.Resources {
+		if locked.Version != "" {
+			versions[id] = locked.Version
+		}
+		if locked.URL == "" || locked.Checksum == "" {
+			continue
+		}
+		sum, err := remote.ParseChecksum(locked.Checksum)
+		if err != nil {
+			internal.Logger.Warn().Err(err).Str("id", id).Msg("Ignoring invalid checksum in lock file")
+			continue
+		}
+		checksums[locked.URL] = sum
+	}
+
+	config.Settings.Internal.LockedVersions = versions
+	config.Settings.Internal.LockedChecksums = checksums
+
+	return config
+}
@@ -0,0 +1,134 @@
+package provision
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/femnad/fup/entity"
+	"github.com/femnad/fup/remote"
+	"github.com/femnad/fup/settings"
+)
+
+func Test_versionLockRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	content := "release"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	config := entity.Config{
+		Filename: path.Join(t.TempDir(), "fup.yml"),
+		Go:       []entity.GoPkg{{Pkg: "qux", Version: "0.1.0"}},
+		Python:   []entity.PythonPkg{{Pkg: "bar", Version: "2.0.0"}},
+		Releases: []entity.Release{{Ref: "foo", Url: server.URL + "/${version}/foo.tar.gz"}},
+		Settings: settings.Settings{Versions: map[string]string{"foo": "1.0.0"}},
+	}
+
+	notFound, err := ReadVersionLock(config)
+	if err != nil || notFound != nil {
+		t.Fatalf("ReadVersionLock() = %v, %v, want no lock", notFound, err)
+	}
+
+	lock, err := ResolveVersionLock(config)
+	if err != nil {
+		t.Fatalf("ResolveVersionLock() error = %v", err)
+	}
+	if err = lock.Write(VersionLockFile(config)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	got, err := ReadVersionLock(config)
+	if err != nil {
+		t.Fatalf("ReadVersionLock() error = %v", err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	want := map[string]LockedResource{
+		"python:bar": {Version: "2.0.0"},
+		"go:qux":     {Version: "0.1.0"},
+		"release:foo": {
+			Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+			URL:      server.URL + "/1.0.0/foo.tar.gz",
+			Version:  "1.0.0",
+		},
+	}
+	if !reflect.DeepEqual(got.Resources, want) {
+		t.Errorf("ReadVersionLock() resources = %v, want %v", got.Resources, want)
+	}
+
+	// Downloads are verified against the locked checksums once the release changes upstream.
+	config.Settings.Versions = nil
+	locked := UseVersionLock(config, *got)
+	if _, err = downloadRelease(config.Releases[0], locked.Settings); err != nil {
+		t.Fatalf("downloadRelease() error = %v", err)
+	}
+	if err = remote.CleanCache(); err != nil {
+		t.Fatal(err)
+	}
+	content = "changed"
+	_, err = downloadRelease(config.Releases[0], locked.Settings)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("downloadRelease() error = %v, want checksum mismatch", err)
+	}
+}
+
+func Test_UseVersionLock(t *testing.T) {
+	sum := strings.Repeat("a", 64)
+	lock := VersionLock{Format: versionLockFormat, Resources: map[string]LockedResource{
+		"python:bar":  {Version: "2.0.0"},
+		"python:foo":  {Version: "4.0.0"},
+		"release:baz": {Checksum: "sha256:invalid", URL: "https://example.com/baz"},
+		"release:foo": {Checksum: "sha256:" + sum, URL: "https://example.com/foo", Version: "1.0.0"},
+	}}
+	config := entity.Config{Settings: settings.Settings{Versions: map[string]string{"bar": "3.0.0"}}}
+
+	got := UseVersionLock(config, lock).Settings
+	wantChecksums := map[string]string{"https://example.com/foo": sum}
+	if !reflect.DeepEqual(got.Internal.LockedChecksums, wantChecksums) {
+		t.Errorf("UseVersionLock() checksums = %v, want %v", got.Internal.LockedChecksums, wantChecksums)
+	}
+
+	tests := []struct {
+		name      string
+		versioned interface {
+			ConfiguredVersion(settings.Settings) string
+		}
+		want string
+	}{
+		{name: "Locked release", versioned: entity.Release{Ref: "foo"}, want: "1.0.0"},
+		{name: "Locked package with the same name", versioned: entity.PythonPkg{Pkg: "foo"}, want: "4.0.0"},
+		{name: "Settings override lock", versioned: entity.PythonPkg{Pkg: "bar"}, want: "3.0.0"},
+		{name: "Config overrides lock", versioned: entity.PythonPkg{Pkg: "foo", Version: "5.0.0"}, want: "5.0.0"},
+		{name: "Not locked", versioned: entity.GoPkg{Pkg: "foo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if version := tt.versioned.ConfiguredVersion(got); version != tt.want {
+				t.Errorf("ConfiguredVersion() = %q, want %q", version, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ResolveVersionLockDuplicateNames(t *testing.T) {
+	config := entity.Config{
+		Filename: path.Join(t.TempDir(), "fup.yml"),
+		Python:   []entity.PythonPkg{{Pkg: "foo", Version: "1.0.0"}, {Pkg: "foo", Version: "2.0.0"}},
+		Go:       []entity.GoPkg{{Pkg: "foo", Version: "1.0.0"}},
+	}
+
+	_, err := ResolveVersionLock(config)
+	if err == nil || !strings.Contains(err.Error(), "python:foo") {
+		t.Errorf("ResolveVersionLock() error = %v, want error for python:foo", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "go:foo") {
+		t.Errorf("ResolveVersionLock() error = %v, want no error for go:foo", err)
+	}
+}
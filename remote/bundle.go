@@ -0,0 +1,422 @@
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/femnad/fup/internal"
+)
+
+const (
+	bundleDirName       = "bundles"
+	bundleFormatVersion = 1
+	bundleManifestName  = "manifest.json"
+)
+
+// Headers kept in recorded responses, as they are used for following redirects, naming and caching downloads.
+var bundleHeaders = []string{"Content-Disposition", "Content-Type", etagKey, lastModifiedKey, "Location"}
+
+var (
+	bundleMu sync.RWMutex
+	// Wraps the configured transport to record responses for, or replay them from, a bundle.
+	wrapTransport func(http.RoundTripper) http.RoundTripper
+	// Set while recording a bundle so that all downloads go through the network.
+	cacheDirOverride string
+	usingBundle      bool
+)
+
+// recordedResponse is a response saved in a bundle, with its body in a blob named by its SHA256 sum.
+type recordedResponse struct {
+	URL    string            `json:"url"`
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	SHA256 string            `json:"sha256"`
+}
+
+type bundleManifest struct {
+	Format    int                `json:"format"`
+	CreatedAt time.Time          `json:"created_at"`
+	Versions  map[string]string  `json:"versions,omitempty"`
+	Responses []recordedResponse `json:"responses"`
+}
+
+// BundleRecorder records all responses received while it's active, for writing them into a bundle.
+type BundleRecorder struct {
+	dir       string
+	mu        sync.Mutex
+	responses map[string]recordedResponse
+}
+
+type recordingTransport struct {
+	base     http.RoundTripper
+	recorder *BundleRecorder
+}
+
+// NewBundleRecorder starts recording responses. Downloads are done with an empty cache while recording, so that
+// none of them are skipped.
+func NewBundleRecorder() (*BundleRecorder, error) {
+	dir, err := os.MkdirTemp("", "fup-bundle-*")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &BundleRecorder{dir: dir, responses: make(map[string]recordedResponse)}
+	bundleMu.Lock()
+	defer bundleMu.Unlock()
+	cacheDirOverride = path.Join(dir, "cache")
+	wrapTransport = func(base http.RoundTripper) http.RoundTripper {
+		return &recordingTransport{base: base, recorder: r}
+	}
+
+	return r, nil
+}
+
+func (r *BundleRecorder) blobDir() string {
+	return path.Join(r.dir, blobDirName)
+}
+
+// store saves the body into the recorder's blobs and returns its SHA256 sum.
+func (r *BundleRecorder) store(body io.Reader) (string, error) {
+	err := os.MkdirAll(r.blobDir(), cacheDirMode)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(r.blobDir(), ".response-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hw := &hashingWriter{w: tmp, hash: sha256.New()}
+	_, err = io.Copy(hw, body)
+	closeErr := tmp.Close()
+	if err != nil {
+		return "", err
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+
+	sum := hex.EncodeToString(hw.hash.Sum(nil))
+	return sum, os.Rename(tmp.Name(), path.Join(r.blobDir(), sum))
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Cached content may not be available where the bundle is applied, so complete responses are recorded.
+	if req.Header.Get(ifNoneMatchKey) != "" || req.Header.Get(ifModifiedKey) != "" {
+		req = req.Clone(req.Context())
+		req.Header.Del(ifNoneMatchKey)
+		req.Header.Del(ifModifiedKey)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+
+	sum, err := t.recorder.store(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error recording response from %s: %v", req.URL, err)
+	}
+
+	recorded := recordedResponse{URL: req.URL.String(), Status: resp.StatusCode, SHA256: sum,
+		Header: make(map[string]string)}
+	for _, key := range bundleHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			recorded.Header[key] = value
+		}
+	}
+
+	t.recorder.mu.Lock()
+	t.recorder.responses[recorded.URL] = recorded
+	t.recorder.mu.Unlock()
+
+	body, err := os.Open(path.Join(t.recorder.blobDir(), sum))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+func addTarFile(tw *tar.Writer, name string, size int64, content io.Reader) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Now(),
+		Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, content)
+	return err
+}
+
+func addTarBlob(tw *tar.Writer, file, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return addTarFile(tw, name, fi.Size(), f)
+}
+
+// Write saves the recorded responses, along with the given resolved versions, into a bundle file.
+func (r *BundleRecorder) Write(output string, versions map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	manifest := bundleManifest{Format: bundleFormatVersion, CreatedAt: time.Now().UTC(), Versions: versions}
+	blobs := make(map[string]bool)
+	for _, response := range r.responses {
+		manifest.Responses = append(manifest.Responses, response)
+		blobs[response.SHA256] = true
+	}
+	sort.Slice(manifest.Responses, func(i, j int) bool {
+		return manifest.Responses[i].URL < manifest.Responses[j].URL
+	})
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(internal.ExpandUser(output))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	err = addTarFile(tw, bundleManifestName, int64(len(data)), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	sums := make([]string, 0, len(blobs))
+	for sum := range blobs {
+		sums = append(sums, sum)
+	}
+	sort.Strings(sums)
+	for _, sum := range sums {
+		err = addTarBlob(tw, path.Join(r.blobDir(), sum), path.Join(blobDirName, sum))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// Close stops recording and removes the recorded responses.
+func (r *BundleRecorder) Close() error {
+	bundleMu.Lock()
+	cacheDirOverride = ""
+	wrapTransport = nil
+	bundleMu.Unlock()
+
+	return os.RemoveAll(r.dir)
+}
+
+// Bundle serves all requests from the responses recorded in a bundle file, so that no network access is needed.
+type Bundle struct {
+	dir       string
+	file      string
+	responses map[string]recordedResponse
+	// Versions resolved when creating the bundle, by resource name.
+	Versions map[string]string
+}
+
+type replayTransport struct {
+	bundle *Bundle
+}
+
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, ok := t.bundle.responses[req.URL.String()]
+	if !ok || req.Method != http.MethodGet {
+		return nil, fmt.Errorf("%s %s is not in bundle %s", req.Method, req.URL, t.bundle.file)
+	}
+
+	body, err := os.Open(path.Join(t.bundle.dir, blobDirName, recorded.SHA256))
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := body.Stat()
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	header := make(http.Header)
+	for key, value := range recorded.Header {
+		header.Set(key, value)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: fi.Size(),
+		Request:       req,
+	}, nil
+}
+
+// extractBlob writes a blob from the bundle, checking that its content matches its name.
+func extractBlob(tr io.Reader, dir, sum string) error {
+	if checkSHA256(sum) != nil {
+		return fmt.Errorf("invalid blob name %s", sum)
+	}
+
+	f, err := os.Create(path.Join(dir, sum))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hw := &hashingWriter{w: f, hash: sha256.New()}
+	_, err = io.Copy(hw, tr)
+	if err != nil {
+		return err
+	}
+
+	if actual := hex.EncodeToString(hw.hash.Sum(nil)); actual != sum {
+		return fmt.Errorf("blob %s is corrupt, its SHA256 sum is %s", sum, actual)
+	}
+
+	return f.Close()
+}
+
+func extractBundle(file, dir string) (bundleManifest, error) {
+	var manifest bundleManifest
+	f, err := os.Open(file)
+	if err != nil {
+		return manifest, err
+	}
+	defer f.Close()
+
+	blobDir := path.Join(dir, blobDirName)
+	err = os.MkdirAll(blobDir, cacheDirMode)
+	if err != nil {
+		return manifest, err
+	}
+
+	var foundManifest bool
+	tr := tar.NewReader(f)
+	for {
+		header, nextErr := tr.Next()
+		if nextErr == io.EOF {
+			break
+		} else if nextErr != nil {
+			return manifest, nextErr
+		}
+
+		switch {
+		case header.Name == bundleManifestName:
+			err = json.NewDecoder(tr).Decode(&manifest)
+			if err != nil {
+				return manifest, fmt.Errorf("error reading bundle manifest: %v", err)
+			}
+			foundManifest = true
+		case strings.HasPrefix(header.Name, blobDirName+"/"):
+			err = extractBlob(tr, blobDir, strings.TrimPrefix(header.Name, blobDirName+"/"))
+			if err != nil {
+				return manifest, err
+			}
+		default:
+			internal.Logger.Debug().Str("name", header.Name).Msg("Ignoring unknown file in bundle")
+		}
+	}
+
+	if !foundManifest {
+		return manifest, fmt.Errorf("no manifest found")
+	}
+	if manifest.Format != bundleFormatVersion {
+		return manifest, fmt.Errorf("unsupported bundle format %d", manifest.Format)
+	}
+
+	return manifest, nil
+}
+
+// OpenBundle extracts a bundle file and serves all requests from it until it's closed. Bundles are extracted in the
+// cache directory, so that any left behind by runs which didn't close them are removed by cleaning the cache.
+func OpenBundle(file string) (*Bundle, error) {
+	file = internal.ExpandUser(file)
+	bundlesDir := path.Join(CacheDir(), bundleDirName)
+	err := os.MkdirAll(bundlesDir, cacheDirMode)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(bundlesDir, "bundle-*")
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := extractBundle(file, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("error reading bundle %s: %v", file, err)
+	}
+
+	b := &Bundle{dir: dir, file: file, responses: make(map[string]recordedResponse), Versions: manifest.Versions}
+	for _, response := range manifest.Responses {
+		b.responses[response.URL] = response
+	}
+
+	bundleMu.Lock()
+	defer bundleMu.Unlock()
+	usingBundle = true
+	wrapTransport = func(http.RoundTripper) http.RoundTripper {
+		return replayTransport{bundle: b}
+	}
+
+	internal.Logger.Debug().Str("file", file).Int("responses", len(b.responses)).Time("created",
+		manifest.CreatedAt).Msg("Using bundle")
+	return b, nil
+}
+
+// Close stops serving requests from the bundle and removes its extracted content.
+func (b *Bundle) Close() error {
+	bundleMu.Lock()
+	usingBundle = false
+	wrapTransport = nil
+	bundleMu.Unlock()
+
+	return os.RemoveAll(b.dir)
+}
+
+// UsingBundle returns true if requests are served from a bundle instead of the network.
+func UsingBundle() bool {
+	bundleMu.RLock()
+	defer bundleMu.RUnlock()
+
+	return usingBundle
+}
@@ -0,0 +1,105 @@
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	setupCache(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/v1.0.0/foo.tar.gz", http.StatusFound)
+	})
+	mux.HandleFunc("/v1.0.0/foo.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "attachment; filename=foo.tar.gz")
+		w.Write([]byte("release"))
+	})
+	mux.HandleFunc("/key", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("key"))
+	})
+	server := httptest.NewServer(mux)
+
+	recorder, err := NewBundleRecorder()
+	if err != nil {
+		t.Fatalf("error starting recorder: %v", err)
+	}
+	latest, err := FollowRedirects(server.URL + "/latest")
+	if err != nil {
+		t.Fatalf("error following redirects: %v", err)
+	}
+	if _, err = CachedDownload(latest, ""); err != nil {
+		t.Fatalf("error downloading: %v", err)
+	}
+	if _, err = ReadResponseBytes(server.URL + "/key"); err != nil {
+		t.Fatalf("error reading key: %v", err)
+	}
+
+	bundleFile := path.Join(t.TempDir(), "bundle.tar")
+	err = recorder.Write(bundleFile, map[string]string{"foo": "1.0.0"})
+	if err != nil {
+		t.Fatalf("error writing bundle: %v", err)
+	}
+	if err = recorder.Close(); err != nil {
+		t.Fatalf("error closing recorder: %v", err)
+	}
+	server.Close()
+
+	// The local cache is empty so that all content is served from the bundle.
+	if err = CleanCache(); err != nil {
+		t.Fatalf("error cleaning cache: %v", err)
+	}
+	bundle, err := OpenBundle(bundleFile)
+	if err != nil {
+		t.Fatalf("error opening bundle: %v", err)
+	}
+	defer bundle.Close()
+
+	if !UsingBundle() {
+		t.Error("expected bundle to be in use")
+	}
+	if got := bundle.Versions["foo"]; got != "1.0.0" {
+		t.Errorf("got version %q, want %q", got, "1.0.0")
+	}
+
+	got, err := FollowRedirects(server.URL + "/latest")
+	if err != nil {
+		t.Fatalf("error following redirects from bundle: %v", err)
+	}
+	if got != latest {
+		t.Errorf("FollowRedirects() got = %v, want %v", got, latest)
+	}
+
+	file, err := CachedDownload(latest, "")
+	if err != nil {
+		t.Fatalf("error downloading from bundle: %v", err)
+	}
+	if content := readCached(t, file); content != "release" {
+		t.Errorf("got content %q, want %q", content, "release")
+	}
+	if file.ContentDisposition != "foo.tar.gz" {
+		t.Errorf("got content disposition %q, want %q", file.ContentDisposition, "foo.tar.gz")
+	}
+
+	if _, err = ReadResponseBytes(server.URL + "/missing"); err == nil {
+		t.Error("expected error for URL missing from bundle")
+	}
+}
+
+func TestOpenBundleInvalid(t *testing.T) {
+	file := path.Join(t.TempDir(), "bundle.tar")
+	if err := os.WriteFile(file, []byte("not a bundle"), 0o644); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	if _, err := OpenBundle(file); err == nil {
+		t.Error("expected error opening invalid bundle")
+	}
+	if UsingBundle() {
+		t.Error("expected no bundle to be in use")
+	}
+}
@@ -0,0 +1,375 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/femnad/fup/internal"
+)
+
+const (
+	blobDirName       = "blobs"
+	cacheDirName      = "downloads"
+	cacheDirMode      = 0o755
+	cacheFileMode     = 0o644
+	cacheHomeEnv      = "XDG_CACHE_HOME"
+	defaultCacheHome  = "~/.cache"
+	defaultCacheLimit = 2 << 30
+	entryDirName      = "entries"
+	etagKey           = "ETag"
+	ifModifiedKey     = "If-Modified-Since"
+	ifNoneMatchKey    = "If-None-Match"
+	lastModifiedKey   = "Last-Modified"
+	stateDirName      = "fup"
+)
+
+var (
+	cacheMu    sync.Mutex
+	cacheLimit int64 = defaultCacheLimit
+	// Blobs used by this process, which are not evicted as they may still be read.
+	pinnedBlobs = mapset.NewThreadUnsafeSet[string]()
+)
+
+// CacheEntry records a downloaded URL and the blob holding its content.
+type CacheEntry struct {
+	URL                string    `json:"url"`
+	SHA256             string    `json:"sha256"`
+	Size               int64     `json:"size"`
+	ETag               string    `json:"etag,omitempty"`
+	LastModified       string    `json:"last_modified,omitempty"`
+	ContentDisposition string    `json:"content_disposition,omitempty"`
+	FetchedAt          time.Time `json:"fetched_at"`
+	UsedAt             time.Time `json:"used_at"`
+}
+
+// CachedFile is a downloaded file in the cache, which must not be modified or removed.
+type CachedFile struct {
+	Path               string
+	ContentDisposition string
+	SHA256             string
+	URL                string
+}
+
+// SetCacheLimit sets the total size of the cached blobs above which least recently used ones are evicted.
+func SetCacheLimit(limit int64) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if limit > 0 {
+		cacheLimit = limit
+	}
+}
+
+func CacheDir() string {
+	bundleMu.RLock()
+	override := cacheDirOverride
+	bundleMu.RUnlock()
+	if override != "" {
+		return override
+	}
+
+	cacheHome := os.Getenv(cacheHomeEnv)
+	if cacheHome == "" {
+		cacheHome = defaultCacheHome
+	}
+
+	return path.Join(internal.ExpandUser(cacheHome), stateDirName, cacheDirName)
+}
+
+func blobPath(sum string) string {
+	return path.Join(CacheDir(), blobDirName, sum)
+}
+
+func entryPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return path.Join(CacheDir(), entryDirName, hex.EncodeToString(sum[:])+".json")
+}
+
+func readEntry(file string) (CacheEntry, error) {
+	var entry CacheEntry
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return entry, err
+	}
+
+	err = json.Unmarshal(data, &entry)
+	return entry, err
+}
+
+func writeEntry(entry CacheEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file := entryPath(entry.URL)
+	err = os.MkdirAll(path.Dir(file), cacheDirMode)
+	if err != nil {
+		return err
+	}
+
+	// Entries are replaced atomically as they can be read by concurrent downloads.
+	tmp := fmt.Sprintf("%s.%d.tmp", file, time.Now().UnixNano())
+	err = os.WriteFile(tmp, data, cacheFileMode)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+// CacheEntries lists the cached downloads, most recently used first.
+func CacheEntries() ([]CacheEntry, error) {
+	dir := path.Join(CacheDir(), entryDirName)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []CacheEntry
+	for _, f := range files {
+		if path.Ext(f.Name()) != ".json" {
+			continue
+		}
+		entry, readErr := readEntry(path.Join(dir, f.Name()))
+		if readErr != nil {
+			internal.Logger.Debug().Err(readErr).Str("file", f.Name()).Msg("Ignoring unreadable cache entry")
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a, b CacheEntry) int {
+		return b.UsedAt.Compare(a.UsedAt)
+	})
+	return entries, nil
+}
+
+// CleanCache removes all cached downloads.
+func CleanCache() error {
+	return os.RemoveAll(CacheDir())
+}
+
+func blobExists(sum string) bool {
+	_, err := os.Stat(blobPath(sum))
+	return err == nil
+}
+
+// useBlob marks a blob as used by this process and records the use in its entry.
+func useBlob(entry CacheEntry) CachedFile {
+	cacheMu.Lock()
+	pinnedBlobs.Add(entry.SHA256)
+	cacheMu.Unlock()
+
+	entry.UsedAt = time.Now().UTC()
+	err := writeEntry(entry)
+	if err != nil {
+		internal.Logger.Warn().Err(err).Str("url", entry.URL).Msg("Error updating cache entry")
+	}
+
+	return CachedFile{
+		Path:               blobPath(entry.SHA256),
+		ContentDisposition: entry.ContentDisposition,
+		SHA256:             entry.SHA256,
+		URL:                entry.URL,
+	}
+}
+
+type hashingWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	size int64
+}
+
+func (h *hashingWriter) Write(p []byte) (int, error) {
+	h.hash.Write(p)
+	h.size += int64(len(p))
+	return h.w.Write(p)
+}
+
+// storeBlob saves the body into the cache and returns its SHA256 sum and size.
+func storeBlob(body io.Reader) (string, int64, error) {
+	dir := path.Join(CacheDir(), blobDirName)
+	err := os.MkdirAll(dir, cacheDirMode)
+	if err != nil {
+		return "", 0, err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hw := &hashingWriter{w: tmp, hash: sha256.New()}
+	_, err = io.Copy(hw, body)
+	closeErr := tmp.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	if closeErr != nil {
+		return "", 0, closeErr
+	}
+
+	sum := hex.EncodeToString(hw.hash.Sum(nil))
+	err = os.Chmod(tmp.Name(), cacheFileMode)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Renaming is atomic, so concurrent downloads of the same content can't leave a partial blob.
+	return sum, hw.size, os.Rename(tmp.Name(), blobPath(sum))
+}
+
+// evict removes the least recently used blobs, and their entries, until the cache is within its size limit.
+func evict() error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	entries, err := CacheEntries()
+	if err != nil {
+		return err
+	}
+
+	// Entries for different URLs can share a blob, which is as recent as its most recently used entry.
+	var blobs []string
+	blobEntries := make(map[string][]CacheEntry)
+	blobSizes := make(map[string]int64)
+	var total int64
+	for _, entry := range entries {
+		if _, ok := blobEntries[entry.SHA256]; !ok {
+			blobs = append(blobs, entry.SHA256)
+			blobSizes[entry.SHA256] = entry.Size
+			total += entry.Size
+		}
+		blobEntries[entry.SHA256] = append(blobEntries[entry.SHA256], entry)
+	}
+
+	for i := len(blobs) - 1; i >= 0 && total > cacheLimit; i-- {
+		sum := blobs[i]
+		if pinnedBlobs.ContainsOne(sum) {
+			continue
+		}
+
+		internal.Logger.Debug().Str("sha256", sum).Int64("size", blobSizes[sum]).Msg("Evicting cached download")
+		err = os.Remove(blobPath(sum))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, entry := range blobEntries[sum] {
+			err = os.Remove(entryPath(entry.URL))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		total -= blobSizes[sum]
+	}
+
+	return nil
+}
+
+// CachedDownload returns the cached content of the URL, downloading it if it's not cached or the cached version is
+// no longer current according to its ETag or Last-Modified headers. If an expected SHA256 sum is given, a blob with
+// that sum is used without checking the URL, and any other content is rejected.
+func CachedDownload(url, sha256sum string) (CachedFile, error) {
+	file, err := cachedDownload(url, sha256sum)
+	if err != nil {
+		return file, err
+	}
+
+	if sha256sum != "" && file.SHA256 != sha256sum {
+		return CachedFile{}, fmt.Errorf("checksum mismatch for %s: expected SHA256 %s, got %s", url, sha256sum,
+			file.SHA256)
+	}
+
+	return file, nil
+}
+
+func cachedDownload(url, sha256sum string) (CachedFile, error) {
+	if url == "" {
+		return CachedFile{}, fmt.Errorf("download URL is empty")
+	}
+
+	entry, entryErr := readEntry(entryPath(url))
+	cached := entryErr == nil && blobExists(entry.SHA256)
+	if sha256sum != "" && blobExists(sha256sum) {
+		if !cached || entry.SHA256 != sha256sum {
+			entry = CacheEntry{URL: url, SHA256: sha256sum, FetchedAt: time.Now().UTC()}
+			if fi, err := os.Stat(blobPath(sha256sum)); err == nil {
+				entry.Size = fi.Size()
+			}
+		}
+		internal.Logger.Trace().Str("url", url).Msg("Using cached download with matching checksum")
+		return useBlob(entry), nil
+	}
+
+	req, err := newRequest(url)
+	if err != nil {
+		return CachedFile{}, err
+	}
+	// Without validators there's no way to tell if the cached content is current.
+	revalidate := cached && (entry.ETag != "" || entry.LastModified != "")
+	if revalidate {
+		if entry.ETag != "" {
+			req.Header.Set(ifNoneMatchKey, entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set(ifModifiedKey, entry.LastModified)
+		}
+	}
+
+	resp, err := Client().Do(req)
+	if err != nil {
+		if cached {
+			internal.Logger.Warn().Err(err).Str("url", url).Msg("Using cached download as the URL is unreachable")
+			return useBlob(entry), nil
+		}
+		return CachedFile{}, err
+	}
+	defer resp.Body.Close()
+
+	if revalidate && resp.StatusCode == http.StatusNotModified {
+		internal.Logger.Trace().Str("url", url).Msg("Using cached download")
+		return useBlob(entry), nil
+	}
+	if !isOK(resp.StatusCode) {
+		return CachedFile{}, fmt.Errorf("error reading response, got status %d from URL %s", resp.StatusCode, url)
+	}
+
+	sum, size, err := storeBlob(resp.Body)
+	if err != nil {
+		return CachedFile{}, fmt.Errorf("error caching download from %s: %v", url, err)
+	}
+
+	now := time.Now().UTC()
+	entry = CacheEntry{
+		URL:                url,
+		SHA256:             sum,
+		Size:               size,
+		ETag:               resp.Header.Get(etagKey),
+		LastModified:       resp.Header.Get(lastModifiedKey),
+		ContentDisposition: getAttachmentFilename(resp.Header),
+		FetchedAt:          now,
+	}
+	file := useBlob(entry)
+
+	err = evict()
+	if err != nil {
+		internal.Logger.Warn().Err(err).Msg("Error evicting cached downloads")
+	}
+
+	return file, nil
+}
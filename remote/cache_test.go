@@ -0,0 +1,182 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/femnad/fup/settings"
+)
+
+func setupCache(t *testing.T) {
+	t.Setenv(cacheHomeEnv, t.TempDir())
+	pinnedBlobs = mapset.NewThreadUnsafeSet[string]()
+	t.Cleanup(func() {
+		cacheLimit = defaultCacheLimit
+	})
+}
+
+func readCached(t *testing.T, file CachedFile) string {
+	content, err := os.ReadFile(file.Path)
+	if err != nil {
+		t.Fatalf("error reading cached file: %v", err)
+	}
+	return string(content)
+}
+
+func TestCachedDownloadRevalidates(t *testing.T) {
+	setupCache(t)
+
+	body := "v1"
+	etag := `"1"`
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get(ifNoneMatchKey) == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(etagKey, etag)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	for i, want := range []string{"v1", "v1"} {
+		file, err := CachedDownload(server.URL, "")
+		if err != nil {
+			t.Fatalf("download %d: unexpected error: %v", i, err)
+		}
+		if got := readCached(t, file); got != want {
+			t.Errorf("download %d: got %q, want %q", i, got, want)
+		}
+	}
+	if notModified != 1 {
+		t.Errorf("got %d not modified responses, want 1", notModified)
+	}
+
+	body = "v2"
+	etag = `"2"`
+	file, err := CachedDownload(server.URL, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readCached(t, file); got != "v2" {
+		t.Errorf("got %q after content change, want %q", got, "v2")
+	}
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+}
+
+func TestCachedDownloadChecksum(t *testing.T) {
+	setupCache(t)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte("content"))
+	checksum := hex.EncodeToString(sum[:])
+
+	_, err := CachedDownload(server.URL+"/a", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A different URL with the same declared checksum is served from the cache.
+	file, err := CachedDownload(server.URL+"/b", checksum)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readCached(t, file); got != "content" {
+		t.Errorf("got %q, want %q", got, "content")
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+}
+
+func TestCachedDownloadChecksumMismatch(t *testing.T) {
+	setupCache(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte("content"))
+	_, err := CachedDownload(server.URL, hex.EncodeToString(sum[:]))
+	if err == nil {
+		t.Fatal("expected checksum mismatch error")
+	}
+}
+
+func TestCachedDownloadOffline(t *testing.T) {
+	setupCache(t)
+	noRetries := 0
+	setupClient(t, settings.HTTPSettings{Retries: &noRetries})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	url := server.URL
+
+	_, err := CachedDownload(url, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.Close()
+
+	file, err := CachedDownload(url, "")
+	if err != nil {
+		t.Fatalf("expected cached download for unreachable URL, got error: %v", err)
+	}
+	if got := readCached(t, file); got != "content" {
+		t.Errorf("got %q, want %q", got, "content")
+	}
+}
+
+func TestCachedDownloadEvicts(t *testing.T) {
+	setupCache(t)
+	SetCacheLimit(10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + "-content"))
+	}))
+	defer server.Close()
+
+	for _, p := range []string{"/first", "/second"} {
+		_, err := CachedDownload(server.URL+p, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Blobs used by a finished download are no longer read.
+		pinnedBlobs.Clear()
+	}
+
+	entries, err := CacheEntries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].URL != server.URL+"/second" {
+		t.Fatalf("got entries %+v, want only %s/second", entries, server.URL)
+	}
+
+	err = CleanCache()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err = CacheEntries()
+	if err != nil || len(entries) != 0 {
+		t.Errorf("got entries %+v and error %v after clean, want none", entries, err)
+	}
+}
@@ -0,0 +1,118 @@
+package remote
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	sha256Length = 64
+	sha256Prefix = "sha256:"
+)
+
+// Matches BSD style checksum lines such as `SHA256 (foo.tar.gz) = <sum>`.
+var bsdChecksumRegex = regexp.MustCompile(`^SHA256 \((.+)\) = ([0-9a-fA-F]+)$`)
+
+func checkSHA256(sum string) error {
+	if len(sum) != sha256Length {
+		return fmt.Errorf("SHA256 sum %s should have %d hex digits, not %d", sum, sha256Length, len(sum))
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return fmt.Errorf("SHA256 sum %s is not hex encoded", sum)
+	}
+
+	return nil
+}
+
+// ParseChecksum returns the lowercase hex SHA256 sum of a checksum in the form sha256:<sum>.
+func ParseChecksum(checksum string) (string, error) {
+	sum, ok := strings.CutPrefix(checksum, sha256Prefix)
+	if !ok {
+		return "", fmt.Errorf("checksum %s should be in the form %s<sum>", checksum, sha256Prefix)
+	}
+
+	sum = strings.ToLower(sum)
+	return sum, checkSHA256(sum)
+}
+
+// SHA256Checksum returns the checksum for the given SHA256 sum, in the form ParseChecksum accepts.
+func SHA256Checksum(sum string) string {
+	return sha256Prefix + sum
+}
+
+// FileName returns the last path component of the URL.
+func FileName(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return path.Base(rawURL)
+	}
+
+	return path.Base(parsed.Path)
+}
+
+// findChecksum returns the sum for the given file name from the content of a checksums file in the format of sha256sum
+// or BSD style lines. A file with a single sum and no file names, such as foo.tar.gz.sha256, matches any name.
+func findChecksum(content, fileName string) (string, error) {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) == 1 {
+		if fields := strings.Fields(lines[0]); len(fields) == 1 {
+			sum := strings.ToLower(fields[0])
+			return sum, checkSHA256(sum)
+		}
+	}
+
+	for _, line := range lines {
+		var sum, name string
+		if match := bsdChecksumRegex.FindStringSubmatch(line); match != nil {
+			name, sum = match[1], match[2]
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			// A leading asterisk marks binary mode in sha256sum output.
+			sum, name = fields[0], strings.TrimPrefix(fields[1], "*")
+		}
+
+		if path.Base(name) == fileName {
+			sum = strings.ToLower(sum)
+			return sum, checkSHA256(sum)
+		}
+	}
+
+	return "", fmt.Errorf("no checksum found for %s", fileName)
+}
+
+// LookupChecksum fetches a checksums file and returns the SHA256 sum for the given file name. If verify is not nil,
+// the checksums file is only trusted if verify accepts its content.
+func LookupChecksum(checksumURL, fileName string, verify func([]byte) error) (string, error) {
+	body, err := ReadResponseBytes(checksumURL)
+	if err != nil {
+		return "", fmt.Errorf("error fetching checksums from %s: %v", checksumURL, err)
+	}
+
+	if verify != nil {
+		err = verify(body)
+		if err != nil {
+			return "", fmt.Errorf("error verifying checksums from %s: %v", checksumURL, err)
+		}
+	}
+
+	sum, err := findChecksum(string(body), fileName)
+	if err != nil {
+		return "", fmt.Errorf("error reading checksums from %s: %v", checksumURL, err)
+	}
+
+	return sum, nil
+}
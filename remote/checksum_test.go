@@ -0,0 +1,115 @@
+package remote
+
+import (
+	"testing"
+)
+
+const (
+	emptySum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	fooSum   = "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+)
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "Valid checksum",
+			checksum: "sha256:" + emptySum,
+			want:     emptySum,
+		},
+		{
+			name:     "Uppercase sum",
+			checksum: "sha256:E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855",
+			want:     emptySum,
+		},
+		{
+			name:     "Missing algorithm",
+			checksum: emptySum,
+			wantErr:  true,
+		},
+		{
+			name:     "Short sum",
+			checksum: "sha256:e3b0c442",
+			wantErr:  true,
+		},
+		{
+			name:     "Not hex",
+			checksum: "sha256:" + emptySum[:62] + "zz",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChecksum(tt.checksum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want && !tt.wantErr {
+				t.Errorf("ParseChecksum() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		fileName string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "sha256sum output",
+			content:  emptySum + "  bar.tar.gz\n" + fooSum + "  foo.tar.gz\n",
+			fileName: "foo.tar.gz",
+			want:     fooSum,
+		},
+		{
+			name:     "Binary mode and directory",
+			content:  fooSum + " *dist/foo.tar.gz\n",
+			fileName: "foo.tar.gz",
+			want:     fooSum,
+		},
+		{
+			name:     "BSD style",
+			content:  "SHA256 (bar.tar.gz) = " + emptySum + "\nSHA256 (foo.tar.gz) = " + fooSum + "\n",
+			fileName: "foo.tar.gz",
+			want:     fooSum,
+		},
+		{
+			name:     "Single sum",
+			content:  fooSum + "\n",
+			fileName: "foo.tar.gz",
+			want:     fooSum,
+		},
+		{
+			name:     "Missing file",
+			content:  emptySum + "  bar.tar.gz\n",
+			fileName: "foo.tar.gz",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findChecksum(tt.content, tt.fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("findChecksum() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileName(t *testing.T) {
+	got := FileName("https://example.com/releases/foo.tar.gz?download=1")
+	if got != "foo.tar.gz" {
+		t.Errorf("FileName() got = %v, want foo.tar.gz", got)
+	}
+}
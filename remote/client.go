@@ -0,0 +1,191 @@
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/femnad/fup/internal"
+	"github.com/femnad/fup/settings"
+)
+
+const (
+	defaultConnectTimeout  = 30 * time.Second
+	defaultResponseTimeout = 60 * time.Second
+	defaultRetries         = 3
+	maxRetryDelay          = 30 * time.Second
+	retryAfterKey          = "Retry-After"
+)
+
+var (
+	clientMu  sync.RWMutex
+	transport http.RoundTripper = newRetryTransport(
+		defaultTransport(defaultConnectTimeout, defaultResponseTimeout), nil, defaultRetries)
+	// Wait before the first retry, which doubles for each further retry.
+	retryBaseWait = time.Second
+)
+
+// retryTransport retries requests failing with connection errors or with 429 or 5xx responses, and adds the
+// configured headers for each host.
+type retryTransport struct {
+	base    http.RoundTripper
+	headers map[string]map[string]string
+	retries int
+}
+
+func newRetryTransport(base http.RoundTripper, headers map[string]map[string]string, retries int) *retryTransport {
+	return &retryTransport{base: base, headers: headers, retries: max(retries, 0)}
+}
+
+func defaultTransport(connectTimeout, responseTimeout time.Duration) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = connectTimeout
+	t.ResponseHeaderTimeout = responseTimeout
+
+	return t
+}
+
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// retryDelay returns the time to wait before the given retry, honoring the Retry-After header of the response if any.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get(retryAfterKey)); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxRetryDelay)
+		}
+	}
+
+	return min(retryBaseWait<<attempt, maxRetryDelay)
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if hostHeaders := t.headers[req.URL.Hostname()]; len(hostHeaders) > 0 {
+		req = req.Clone(req.Context())
+		for key, value := range hostHeaders {
+			req.Header.Set(key, os.ExpandEnv(value))
+		}
+	}
+
+	// Only requests without a body can be sent again.
+	retries := t.retries
+	if req.Body != nil && req.Body != http.NoBody {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		retryable := (err != nil && isRetryableError(err)) || (err == nil && isRetryableStatus(resp.StatusCode))
+		if !retryable || attempt >= retries {
+			return resp, err
+		}
+
+		delay := retryDelay(attempt, resp)
+		event := internal.Logger.Debug().Str("url", req.URL.String()).Int("attempt", attempt+1).Dur("delay", delay)
+		if err != nil {
+			event.Err(err).Msg("Retrying request after error")
+		} else {
+			event.Int("status", resp.StatusCode).Msg("Retrying request after response status")
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func caPool(bundle string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	pem, err := os.ReadFile(internal.ExpandUser(bundle))
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA bundle: %v", err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", bundle)
+	}
+
+	return pool, nil
+}
+
+// Configure sets up the client used for all requests from the HTTP settings.
+func Configure(s settings.HTTPSettings) error {
+	connectTimeout := defaultConnectTimeout
+	if s.ConnectTimeoutSeconds > 0 {
+		connectTimeout = time.Duration(s.ConnectTimeoutSeconds) * time.Second
+	}
+	responseTimeout := defaultResponseTimeout
+	if s.ResponseTimeoutSeconds > 0 {
+		responseTimeout = time.Duration(s.ResponseTimeoutSeconds) * time.Second
+	}
+	retries := defaultRetries
+	if s.Retries != nil {
+		retries = *s.Retries
+	}
+
+	base := defaultTransport(connectTimeout, responseTimeout)
+	if s.Proxy != "" {
+		proxyURL, err := url.Parse(s.Proxy)
+		if err != nil {
+			return fmt.Errorf("error parsing proxy URL %s: %v", s.Proxy, err)
+		}
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+	if s.CABundle != "" {
+		pool, err := caPool(s.CABundle)
+		if err != nil {
+			return err
+		}
+		base.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	transport = newRetryTransport(base, s.Headers, retries)
+	return nil
+}
+
+// Client returns a client using the configured transport, or serving responses from a bundle if one is in use.
+func Client() *http.Client {
+	clientMu.RLock()
+	t := transport
+	clientMu.RUnlock()
+
+	bundleMu.RLock()
+	defer bundleMu.RUnlock()
+	if wrapTransport != nil {
+		t = wrapTransport(t)
+	}
+
+	return &http.Client{Transport: t}
+}
@@ -0,0 +1,140 @@
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/femnad/fup/settings"
+)
+
+func setupClient(t *testing.T, s settings.HTTPSettings) {
+	retryBaseWait = time.Millisecond
+	if err := Configure(s); err != nil {
+		t.Fatalf("error configuring client: %v", err)
+	}
+	t.Cleanup(func() {
+		retryBaseWait = time.Second
+		if err := Configure(settings.HTTPSettings{}); err != nil {
+			t.Fatalf("error resetting client: %v", err)
+		}
+	})
+}
+
+func TestReadResponseBytesRetries(t *testing.T) {
+	one, zero := 1, 0
+	tests := []struct {
+		name         string
+		retries      *int
+		statuses     []int
+		wantErr      bool
+		wantRequests int
+	}{
+		{
+			name:         "Retry server errors",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusBadGateway},
+			wantRequests: 3,
+		},
+		{
+			name:         "Retry rate limiting",
+			statuses:     []int{http.StatusTooManyRequests},
+			wantRequests: 2,
+		},
+		{
+			name:         "Don't retry client errors",
+			statuses:     []int{http.StatusNotFound},
+			wantErr:      true,
+			wantRequests: 1,
+		},
+		{
+			name:         "Retries exhausted",
+			retries:      &one,
+			statuses:     []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			wantErr:      true,
+			wantRequests: 2,
+		},
+		{
+			name:         "Retries disabled",
+			retries:      &zero,
+			statuses:     []int{http.StatusInternalServerError},
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupClient(t, settings.HTTPSettings{Retries: tt.retries})
+
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= len(tt.statuses) {
+					if tt.statuses[requests-1] == http.StatusTooManyRequests {
+						w.Header().Set(retryAfterKey, "0")
+					}
+					w.WriteHeader(tt.statuses[requests-1])
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			got, err := ReadResponseBytes(server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadResponseBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != "ok" {
+				t.Errorf("ReadResponseBytes() got = %q, want %q", got, "ok")
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestReadResponseBytesHeaders(t *testing.T) {
+	t.Setenv("FUP_TEST_TOKEN", "secret")
+	setupClient(t, settings.HTTPSettings{Headers: map[string]map[string]string{
+		"127.0.0.1":   {"Authorization": "Bearer ${FUP_TEST_TOKEN}"},
+		"example.com": {"Authorization": "Bearer other"},
+	}})
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	_, err := ReadResponseBytes(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "Bearer secret" {
+		t.Errorf("got Authorization header %q, want %q", got, "Bearer secret")
+	}
+}
+
+func TestFollowRedirects(t *testing.T) {
+	setupClient(t, settings.HTTPSettings{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/tag/v1", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/tag/v1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "v1.2.3", http.StatusFound)
+	})
+	mux.HandleFunc("/tag/v1.2.3", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	got, err := FollowRedirects(server.URL + "/latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := server.URL + "/tag/v1.2.3"; got != want {
+		t.Errorf("FollowRedirects() got = %v, want %v", got, want)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"net/url"
 	"os"
 	"path"
-	"regexp"
 	"strings"
 
 	"github.com/femnad/fup/internal"
@@ -22,10 +21,14 @@ const (
 )
 
 var (
-	absoluteURLRegex = regexp.MustCompile("^http(s)?://.*")
-	okStatuses       = []int{http.StatusOK}
+	redirectStatuses = []int{http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect}
 )
 
+func isOK(status int) bool {
+	return status >= http.StatusOK && status < http.StatusMultipleChoices
+}
+
 type Response struct {
 	Body               io.ReadCloser
 	ContentDisposition string
@@ -55,22 +58,48 @@ func getAttachmentFilename(header http.Header) string {
 	return ""
 }
 
-func ReadResponseBody(url string) (Response, error) {
-	var response Response
-	cl := http.Client{}
+func newRequest(url string) (*http.Request, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return response, err
+		return nil, err
 	}
 	req.Header.Set(userAgentKey, userAgent)
 
-	resp, err := cl.Do(req)
+	return req, nil
+}
+
+// AuthError is the error for a response requiring authentication, with the challenge from its WWW-Authenticate header.
+type AuthError struct {
+	Challenge string
+	URL       string
+}
+
+func (e AuthError) Error() string {
+	return fmt.Sprintf("error reading response, got status %d from URL %s", http.StatusUnauthorized, e.URL)
+}
+
+func readResponseBody(url string, headers map[string]string) (Response, error) {
+	var response Response
+	req, err := newRequest(url)
+	if err != nil {
+		return response, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := Client().Do(req)
 	if err != nil {
 		return response, err
 	}
 
 	statusCode := resp.StatusCode
-	if !internal.Contains(okStatuses, statusCode) {
+	if statusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return response, AuthError{Challenge: resp.Header.Get("WWW-Authenticate"), URL: url}
+	}
+	if !isOK(statusCode) {
+		resp.Body.Close()
 		return response, fmt.Errorf("error reading response, got status %d from URL %s", statusCode, url)
 	}
 
@@ -80,16 +109,27 @@ func ReadResponseBody(url string) (Response, error) {
 	return response, nil
 }
 
-func ReadResponseBytes(url string) ([]byte, error) {
-	response, err := ReadResponseBody(url)
+func ReadResponseBody(url string) (Response, error) {
+	return readResponseBody(url, nil)
+}
+
+// ReadResponseBytesWithHeaders returns the content of the URL, sending the headers in addition to the configured ones.
+func ReadResponseBytesWithHeaders(url string, headers map[string]string) ([]byte, error) {
+	response, err := readResponseBody(url, headers)
 	if err != nil {
 		return nil, err
 	}
+	defer response.Body.Close()
 
 	return io.ReadAll(response.Body)
 }
 
-func Download(url, target string) error {
+func ReadResponseBytes(url string) ([]byte, error) {
+	return ReadResponseBytesWithHeaders(url, nil)
+}
+
+// Download saves the content of the URL to the target, verifying it against the SHA256 sum if it's not empty.
+func Download(url, target, sha256sum string) error {
 	if url == "" {
 		return fmt.Errorf("download URL is empty")
 	}
@@ -97,27 +137,30 @@ func Download(url, target string) error {
 		return fmt.Errorf("download target is empty")
 	}
 
-	resp, err := ReadResponseBody(url)
+	cached, err := CachedDownload(url, sha256sum)
 	if err != nil {
 		return err
 	}
 
-	dir, _ := path.Split(target)
-	if err = internal.EnsureDirExists(dir); err != nil {
+	in, err := os.Open(cached.Path)
+	if err != nil {
 		return err
 	}
+	defer in.Close()
 
-	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, os.FileMode(0o644))
-	if err != nil {
+	dir, _ := path.Split(target)
+	if err = internal.EnsureDirExists(dir); err != nil {
 		return err
 	}
 
-	_, err = io.Copy(out, resp.Body)
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(0o644))
 	if err != nil {
 		return err
 	}
+	defer out.Close()
 
-	return nil
+	_, err = io.Copy(out, in)
+	return err
 }
 
 func followRedirects(startURL string, count int) (string, error) {
@@ -130,33 +173,33 @@ func followRedirects(startURL string, count int) (string, error) {
 		return "", err
 	}
 
-	client := http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+	req, err := newRequest(startURL)
+	if err != nil {
+		return "", err
+	}
+
+	client := Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
 		return http.ErrUseLastResponse
-	}}
-	resp, err := client.Get(startURL)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	location := resp.Header.Get(locationKey)
-	if location == "" {
-		location = startURL
-	} else if !absoluteURLRegex.MatchString(location) {
-		location, err = url.JoinPath(parsed.Host, location)
-		if err != nil {
-			return "", err
-		}
+	if location == "" || !internal.Contains(redirectStatuses, resp.StatusCode) {
+		return startURL, nil
 	}
 
-	if resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusFound {
-		if !absoluteURLRegex.MatchString(location) {
-			location = fmt.Sprintf("https://%s", location)
-		}
-		return followRedirects(location, count+1)
+	// Locations can be relative to the requested URL.
+	next, err := parsed.Parse(location)
+	if err != nil {
+		return "", err
 	}
 
-	return location, nil
+	return followRedirects(next.String(), count+1)
 }
 
 func FollowRedirects(startURL string) (string, error) {
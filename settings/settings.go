@@ -5,36 +5,93 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"sort"
 
 	"github.com/femnad/fup/internal"
 )
 
 const (
+	archKey        = "arch"
 	cloneDirKey    = "clone_dir"
 	defaultBinPath = "~/bin"
-	releaseDirKey  = "release_dir"
+	megabyte       = 1 << 20
+	// Number of releases to download and extract concurrently unless overridden.
+	defaultReleaseWorkers = 4
+	goArchKey             = "goarch"
+	releaseDirKey         = "release_dir"
+	rustTargetKey         = "rust_target"
 )
 
 type FactMap map[string]map[string]string
 
 type InternalSettings struct {
 	GhAvailable bool
+	// SHA256 sums of release downloads by URL, from the version lock file.
+	LockedChecksums map[string]string
+	// Versions of releases and packages by their resource ID such as release:foo, from the version lock file.
+	LockedVersions map[string]string
+}
+
+// HTTPSettings configures the client used for all HTTP requests.
+type HTTPSettings struct {
+	// CA bundle to trust in addition to the system CAs.
+	CABundle              string `yaml:"ca_bundle,omitempty"`
+	ConnectTimeoutSeconds int    `yaml:"connect_timeout_seconds,omitempty"`
+	// Extra headers to send, by host. Values are expanded with environment variables.
+	Headers map[string]map[string]string `yaml:"headers,omitempty"`
+	// Proxy URL, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used if empty.
+	Proxy string `yaml:"proxy,omitempty"`
+	// Seconds to wait for response headers after sending a request.
+	ResponseTimeoutSeconds int `yaml:"response_timeout_seconds,omitempty"`
+	// Number of retries for connection errors and 429 or 5xx responses, 0 disables retries.
+	Retries *int `yaml:"retries,omitempty"`
+}
+
+// Merge returns the HTTP settings with the non-empty values of override taking precedence. Headers are merged by host.
+func (h HTTPSettings) Merge(override HTTPSettings) HTTPSettings {
+	headers := mergeMap(h.Headers, nil)
+	for host, hostHeaders := range override.Headers {
+		if headers == nil {
+			headers = make(map[string]map[string]string)
+		}
+		headers[host] = mergeMap(headers[host], hostHeaders)
+	}
+
+	merged := HTTPSettings{
+		CABundle:               mergeString(h.CABundle, override.CABundle),
+		ConnectTimeoutSeconds:  h.ConnectTimeoutSeconds,
+		Headers:                headers,
+		Proxy:                  mergeString(h.Proxy, override.Proxy),
+		ResponseTimeoutSeconds: h.ResponseTimeoutSeconds,
+		Retries:                mergeOptional(h.Retries, override.Retries),
+	}
+	if override.ConnectTimeoutSeconds > 0 {
+		merged.ConnectTimeoutSeconds = override.ConnectTimeoutSeconds
+	}
+	if override.ResponseTimeoutSeconds > 0 {
+		merged.ResponseTimeoutSeconds = override.ResponseTimeoutSeconds
+	}
+
+	return merged
 }
 
 type Settings struct {
-	BinDir        string            `yaml:"bin_dir,omitempty"`
-	CloneDir      string            `yaml:"clone_dir,omitempty"`
-	CloneEnv      map[string]string `yaml:"clone_env,omitempty"`
-	EnsureEnv     map[string]string `yaml:"ensure_env,omitempty"`
-	EnsurePaths   []string          `yaml:"ensure_paths,omitempty"`
-	HostFacts     FactMap           `yaml:"host_facts,omitempty"`
-	Internal      InternalSettings
-	ReleaseDir    string            `yaml:"release_dir,omitempty"`
-	TemplateDir   string            `yaml:"template_dir,omitempty"`
-	UseGHClient   bool              `yaml:"use_github_cli,omitempty"`
-	Versions      map[string]string `yaml:"versions,omitempty"`
-	VirtualEnvDir string            `yaml:"virtualenv_dir,omitempty"`
+	BinDir         string            `yaml:"bin_dir,omitempty"`
+	CacheSizeMB    int               `yaml:"cache_size_mb,omitempty"`
+	CloneDir       string            `yaml:"clone_dir,omitempty"`
+	CloneEnv       map[string]string `yaml:"clone_env,omitempty"`
+	EnsureEnv      map[string]string `yaml:"ensure_env,omitempty"`
+	EnsurePaths    []string          `yaml:"ensure_paths,omitempty"`
+	HostFacts      FactMap           `yaml:"host_facts,omitempty"`
+	HTTP           HTTPSettings      `yaml:"http,omitempty"`
+	Internal       InternalSettings  `yaml:"-"`
+	ReleaseDir     string            `yaml:"release_dir,omitempty"`
+	ReleaseWorkers int               `yaml:"release_workers,omitempty"`
+	TemplateDir    string            `yaml:"template_dir,omitempty"`
+	UseGHClient    *bool             `yaml:"use_github_cli,omitempty"`
+	Versions       map[string]string `yaml:"versions,omitempty"`
+	VirtualEnvDir  string            `yaml:"virtualenv_dir,omitempty"`
 }
 
 func (s Settings) GetBinPath() string {
@@ -45,6 +102,100 @@ func (s Settings) GetBinPath() string {
 	return defaultBinPath
 }
 
+// GetCacheSize returns the download cache size limit in bytes, or zero for the default limit.
+func (s Settings) GetCacheSize() int64 {
+	return int64(s.CacheSizeMB) * megabyte
+}
+
+func (s Settings) GetReleaseWorkers() int {
+	if s.ReleaseWorkers > 0 {
+		return s.ReleaseWorkers
+	}
+
+	return defaultReleaseWorkers
+}
+
+// GetUseGHClient returns whether to use the gh CLI for GitHub API requests, which is off unless enabled.
+func (s Settings) GetUseGHClient() bool {
+	return s.UseGHClient != nil && *s.UseGHClient
+}
+
+func mergeMap[V any](base, override map[string]V) map[string]V {
+	if len(base) == 0 && len(override) == 0 {
+		return base
+	}
+
+	merged := make(map[string]V, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// mergeOptional returns override if it's set, so that it can also override with a zero value.
+func mergeOptional[T any](base, override *T) *T {
+	if override != nil {
+		return override
+	}
+
+	return base
+}
+
+func mergeString(base, override string) string {
+	if override != "" {
+		return override
+	}
+
+	return base
+}
+
+// Merge returns the settings with the non-empty values of override taking precedence over the ones in s. Maps are
+// merged by key and ensure_paths are appended.
+func (s Settings) Merge(override Settings) Settings {
+	hostFacts := FactMap{}
+	for fact, facts := range s.HostFacts {
+		hostFacts[fact] = facts
+	}
+	for fact, facts := range override.HostFacts {
+		hostFacts[fact] = mergeMap(hostFacts[fact], facts)
+	}
+	if len(hostFacts) == 0 {
+		hostFacts = s.HostFacts
+	}
+
+	cacheSize := s.CacheSizeMB
+	if override.CacheSizeMB > 0 {
+		cacheSize = override.CacheSizeMB
+	}
+
+	releaseWorkers := s.ReleaseWorkers
+	if override.ReleaseWorkers > 0 {
+		releaseWorkers = override.ReleaseWorkers
+	}
+
+	return Settings{
+		BinDir:         mergeString(s.BinDir, override.BinDir),
+		CacheSizeMB:    cacheSize,
+		CloneDir:       mergeString(s.CloneDir, override.CloneDir),
+		CloneEnv:       mergeMap(s.CloneEnv, override.CloneEnv),
+		EnsureEnv:      mergeMap(s.EnsureEnv, override.EnsureEnv),
+		EnsurePaths:    slices.Concat(s.EnsurePaths, override.EnsurePaths),
+		HostFacts:      hostFacts,
+		HTTP:           s.HTTP.Merge(override.HTTP),
+		Internal:       s.Internal,
+		ReleaseDir:     mergeString(s.ReleaseDir, override.ReleaseDir),
+		ReleaseWorkers: releaseWorkers,
+		TemplateDir:    mergeString(s.TemplateDir, override.TemplateDir),
+		UseGHClient:    mergeOptional(s.UseGHClient, override.UseGHClient),
+		Versions:       mergeMap(s.Versions, override.Versions),
+		VirtualEnvDir:  mergeString(s.VirtualEnvDir, override.VirtualEnvDir),
+	}
+}
+
 func Expand(s string, lookup map[string]string) string {
 	var cur bytes.Buffer
 	var out bytes.Buffer
@@ -102,6 +253,9 @@ func Expand(s string, lookup map[string]string) string {
 			out.WriteRune(c)
 		}
 	}
+	if dollar {
+		out.WriteRune('$')
+	}
 
 	return out.String()
 }
@@ -146,6 +300,10 @@ func addHostFacts(lookup map[string]string, factMap FactMap) map[string]string {
 func ExpandStringWithLookup(settings Settings, s string, lookup map[string]string) string {
 	lookup[cloneDirKey] = settings.CloneDir
 	lookup[releaseDirKey] = settings.ReleaseDir
+	// Host facts can override the architecture names, e.g. for artifacts with unusual naming.
+	lookup[archKey] = internal.Arch()
+	lookup[goArchKey] = internal.GoArch()
+	lookup[rustTargetKey] = internal.RustTarget()
 	lookup = addHostFacts(lookup, settings.HostFacts)
 
 	expanded := Expand(s, lookup)
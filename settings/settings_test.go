@@ -3,6 +3,8 @@ package settings
 import (
 	"os"
 	"testing"
+
+	"github.com/femnad/fup/internal"
 )
 
 func TestExpandString(t *testing.T) {
@@ -100,6 +102,14 @@ func TestExpandString(t *testing.T) {
 			},
 			want: "echo 'foo \\'bar baz\\''",
 		},
+		{
+			name: "Keep trailing dollar signs",
+			args: args{
+				s:      "foo-${version}\\.tar\\.gz$",
+				lookup: map[string]string{"version": "1.0.0"},
+			},
+			want: "foo-1.0.0\\.tar\\.gz$",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -116,3 +126,54 @@ func TestExpandString(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandStringArch(t *testing.T) {
+	got := ExpandString(Settings{}, "foo-${arch}-${goarch}-${rust_target}")
+	want := "foo-" + internal.Arch() + "-" + internal.GoArch() + "-" + internal.RustTarget()
+	if got != want {
+		t.Errorf("ExpandString() got = %v, want %v", got, want)
+	}
+}
+
+func TestHTTPSettingsMergeRetries(t *testing.T) {
+	zero, three := 0, 3
+	tests := []struct {
+		name     string
+		base     *int
+		override *int
+		want     *int
+	}{
+		{name: "Unset", base: &three, want: &three},
+		{name: "Override with zero", base: &three, override: &zero, want: &zero},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTTPSettings{Retries: tt.base}.Merge(HTTPSettings{Retries: tt.override}).Retries
+			if got == nil || *got != *tt.want {
+				t.Errorf("Merge() retries = %v, want %d", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsMergeUseGHClient(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name     string
+		base     *bool
+		override *bool
+		want     bool
+	}{
+		{name: "Unset", base: &enabled, want: true},
+		{name: "Enable", base: &disabled, override: &enabled, want: true},
+		{name: "Disable", base: &enabled, override: &disabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Settings{UseGHClient: tt.base}.Merge(Settings{UseGHClient: tt.override}).GetUseGHClient()
+			if got != tt.want {
+				t.Errorf("Merge() use GitHub CLI = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}